@@ -0,0 +1,55 @@
+package cml
+
+import "errors"
+
+/*
+CombineMode decides how QueryAcross combines the estimates of several sketches
+*/
+type CombineMode int
+
+const (
+	// CombineMax takes the largest estimate, the bound for sketches fed overlapping traffic.
+	CombineMax CombineMode = iota
+	// CombineSum adds the estimates, the count for sketches fed disjoint traffic.
+	CombineSum
+)
+
+/*
+QueryAcross returns the count of `e` across sketches without merging them. The sketches may
+have different dimensions but must share exp and hashing scheme, so the key is hashed once.
+*/
+func QueryAcross(e []byte, mode CombineMode, sketches ...*Sketch) (float64, error) {
+	if mode != CombineMax && mode != CombineSum {
+		return 0, errors.New("unknown combine mode")
+	}
+	if len(sketches) == 0 {
+		return 0, errors.New("no sketches to query")
+	}
+	first := sketches[0]
+	for _, sk := range sketches[1:] {
+		if !sameExp(sk.exp, first.exp) {
+			return 0, &MismatchError{Field: "exp", Ours: first.exp, Theirs: sk.exp}
+		}
+		if sk.hashing != first.hashing {
+			return 0, &MismatchError{Field: "hashing", Ours: first.hashing, Theirs: sk.hashing}
+		}
+	}
+
+	for _, sk := range sketches {
+		if err := sk.CheckKey(e); err != nil {
+			return 0, err
+		}
+	}
+
+	h := first.hash(e)
+	var result float64
+	for _, sk := range sketches {
+		v := sk.estimate(h)
+		if mode == CombineSum {
+			result += v
+		} else if v > result {
+			result = v
+		}
+	}
+	return result, nil
+}
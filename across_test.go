@@ -0,0 +1,64 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestQueryAcross(t *testing.T) {
+	east, _ := NewSketch(1000, 4, 1.00026)
+	west, _ := NewSketch(1000, 4, 1.00026)
+	north, _ := NewSketch(3000, 6, 1.00026)
+	for i := 0; i < 100; i++ {
+		east.BulkUpdate([]byte(fmt.Sprint("east", i)), 50)
+		west.BulkUpdate([]byte(fmt.Sprint("west", i)), 50)
+	}
+	east.BulkUpdate([]byte("shared"), 1000)
+	west.BulkUpdate([]byte("shared"), 400)
+	north.BulkUpdate([]byte("shared"), 200)
+
+	merged := east.Clone()
+	if err := merged.Merge(west); err != nil {
+		t.Fatal(err)
+	}
+	got, err := QueryAcross([]byte("shared"), CombineMax, east, west)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := merged.Query([]byte("shared")); got > want || math.Abs(got-want) > want*0.05 {
+		t.Errorf("expected max close to the merged estimate %v, got %v", want, got)
+	}
+
+	got, err = QueryAcross([]byte("shared"), CombineSum, east, west, north)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := east.Query([]byte("shared")) + west.Query([]byte("shared")) + north.Query([]byte("shared"))
+	if got != want || math.Abs(got-1600) > 1600*0.1 {
+		t.Errorf("expected sum %v close to 1600, got %v", want, got)
+	}
+
+	got, _ = QueryAcross([]byte("east7"), CombineSum, east, west)
+	if math.Abs(got-merged.Query([]byte("east7"))) > 5 {
+		t.Errorf("expected the sum of disjoint traffic to match the merged estimate, got %v", got)
+	}
+}
+
+func TestQueryAcrossInvalid(t *testing.T) {
+	a, _ := NewSketch(100, 4, 1.00026)
+	b, _ := NewSketch(100, 4, 1.5)
+	c, _ := NewSketch(100, 4, 1.00026, WithHash128())
+	if _, err := QueryAcross([]byte("a"), CombineMax, a, b); err == nil {
+		t.Error("expected error for different exp")
+	}
+	if _, err := QueryAcross([]byte("a"), CombineMax, a, c); err == nil {
+		t.Error("expected error for different hashing")
+	}
+	if _, err := QueryAcross([]byte("a"), CombineSum); err == nil {
+		t.Error("expected error for no sketches")
+	}
+	if _, err := QueryAcross([]byte("a"), CombineMode(7), a); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,60 @@
+package cml
+
+import "math"
+
+/*
+ResetCount returns how many times the sketch has been halved since it was created
+(see WithSampleSize)
+*/
+func (cml *Sketch) ResetCount() uint64 {
+	return cml.resets
+}
+
+// halve replaces every register by the register whose value is nearest to
+// half of its value, rounding ties down so that single counts age out.
+func (cml *Sketch) halve() {
+	scale := cml.newScaler(0.5)
+	for _, row := range cml.store {
+		for j, c := range row {
+			row[j] = scale.register(c)
+		}
+	}
+	clear(cml.doorkeeper)
+	cml.recount()
+}
+
+// scaler maps registers to the register whose value is nearest to factor times
+// their value, memoizing the mapping so each distinct register is solved once.
+type scaler struct {
+	cml    *Sketch
+	factor float64
+	table  []uint16
+	known  []bool
+}
+
+func (cml *Sketch) newScaler(factor float64) *scaler {
+	return &scaler{
+		cml:    cml,
+		factor: factor,
+		table:  make([]uint16, math.MaxUint16+1),
+		known:  make([]bool, math.MaxUint16+1),
+	}
+}
+
+func (s *scaler) register(c uint16) uint16 {
+	if c == 0 || s.factor == 1 {
+		return c
+	}
+	if s.known[c] {
+		return s.table[c]
+	}
+	// A saturated register's value is unknown, so it is scaled as if it
+	// held the largest known value.
+	target := s.cml.value(min(c, math.MaxUint16-1)) * s.factor
+	r, _ := s.cml.register(target)
+	if r > 0 && target-s.cml.value(r-1) <= s.cml.value(r)-target {
+		r--
+	}
+	s.table[c], s.known[c] = r, true
+	return r
+}
@@ -0,0 +1,61 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSampleSizeAging(t *testing.T) {
+	const W = 10000
+	sk, err := NewSketch(10000, 4, 1.00026, WithSampleSize(W))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk.BulkUpdate([]byte("old"), 5000)
+	for i := 0; i < 3*W-5000; i++ {
+		sk.Update([]byte(fmt.Sprintf("noise-%d", i%2000)))
+	}
+	sk.BulkUpdate([]byte("new"), 1000)
+
+	old, recent := sk.Query([]byte("old")), sk.Query([]byte("new"))
+	if sk.ResetCount() < 3 {
+		t.Errorf("expected at least 3 resets, got %d", sk.ResetCount())
+	}
+	if old > 1000 {
+		t.Errorf("expected old key to decay below 1000, got %f", old)
+	}
+	if recent < old {
+		t.Errorf("expected recent key (%f) to dominate old key (%f)", recent, old)
+	}
+}
+
+func TestSampleSizeBulkCrossing(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithSampleSize(1000))
+	sk.BulkUpdate([]byte("a"), 900)
+	sk.BulkUpdate([]byte("a"), 200)
+
+	if sk.ResetCount() != 1 {
+		t.Fatalf("expected exactly one reset, got %d", sk.ResetCount())
+	}
+	if sk.sampled != 600 {
+		t.Errorf("expected 600 sampled after halving, got %d", sk.sampled)
+	}
+	if count := sk.Query([]byte("a")); count < 570 || count > 630 {
+		t.Errorf("expected ~600, got %f", count)
+	}
+
+	sk.BulkUpdate([]byte("b"), 2500)
+	if sk.ResetCount() != 6 {
+		t.Errorf("expected a reset per crossed boundary, got %d", sk.ResetCount())
+	}
+}
+
+func TestHalveSingleCounts(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	sk.Update([]byte("a"))
+	sk.halve()
+	if count := sk.Query([]byte("a")); count != 0 {
+		t.Errorf("expected single count to age out, got %f", count)
+	}
+}
@@ -0,0 +1,193 @@
+package cml
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+FullPolicy decides what AsyncWriter.Enqueue does when the buffer is full
+*/
+type FullPolicy int
+
+const (
+	// DropWhenFull discards the update and counts it as dropped.
+	DropWhenFull FullPolicy = iota
+	// BlockWhenFull waits until the buffer has room.
+	BlockWhenFull
+)
+
+// asyncBatchSize is the most updates the writer applies per lock acquisition.
+const asyncBatchSize = 256
+
+/*
+ErrClosed is returned when using an AsyncWriter after Close
+*/
+var ErrClosed = errors.New("async writer closed")
+
+type asyncUpdate struct {
+	key   []byte
+	freq  uint
+	flush chan struct{}
+}
+
+/*
+AsyncWriter owns a sketch and applies updates to it from a background goroutine,
+keeping the sketch's update cost off the caller's path
+*/
+type AsyncWriter struct {
+	mu sync.Mutex
+	sk *Sketch
+
+	policy  FullPolicy
+	updates chan asyncUpdate
+	dropped atomic.Uint64
+	done    chan struct{}
+
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+/*
+NewAsyncWriter returns a new AsyncWriter buffering up to buffer updates for sk
+*/
+func NewAsyncWriter(sk *Sketch, buffer int, policy FullPolicy) *AsyncWriter {
+	aw := &AsyncWriter{
+		sk:      sk,
+		policy:  policy,
+		updates: make(chan asyncUpdate, buffer),
+		done:    make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	for u := range aw.updates {
+		aw.mu.Lock()
+		aw.apply(u)
+	batch:
+		for i := 1; i < asyncBatchSize; i++ {
+			select {
+			case u, ok := <-aw.updates:
+				if !ok {
+					break batch
+				}
+				aw.apply(u)
+			default:
+				break batch
+			}
+		}
+		aw.mu.Unlock()
+	}
+}
+
+func (aw *AsyncWriter) apply(u asyncUpdate) {
+	if u.flush != nil {
+		close(u.flush)
+		return
+	}
+	aw.sk.BulkUpdate(u.key, u.freq)
+}
+
+/*
+Enqueue schedules increasing the count of `key` by freq and reports whether it was accepted.
+The key is copied. When the buffer is full the update is dropped or waits, depending on the policy.
+*/
+func (aw *AsyncWriter) Enqueue(key []byte, freq uint) bool {
+	aw.closeMu.RLock()
+	defer aw.closeMu.RUnlock()
+	if aw.closed {
+		return false
+	}
+	u := asyncUpdate{key: append([]byte(nil), key...), freq: freq}
+	if aw.policy == BlockWhenFull {
+		aw.updates <- u
+		return true
+	}
+	select {
+	case aw.updates <- u:
+		return true
+	default:
+		aw.dropped.Add(1)
+		return false
+	}
+}
+
+/*
+Dropped returns how many updates were dropped because the buffer was full
+*/
+func (aw *AsyncWriter) Dropped() uint64 {
+	return aw.dropped.Load()
+}
+
+/*
+Flush waits until every update enqueued before the call has been applied
+*/
+func (aw *AsyncWriter) Flush(ctx context.Context) error {
+	aw.closeMu.RLock()
+	if aw.closed {
+		aw.closeMu.RUnlock()
+		return ErrClosed
+	}
+	flushed := make(chan struct{})
+	select {
+	case aw.updates <- asyncUpdate{flush: flushed}:
+		aw.closeMu.RUnlock()
+	case <-ctx.Done():
+		aw.closeMu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+Close applies all outstanding updates and stops the background goroutine
+*/
+func (aw *AsyncWriter) Close() error {
+	aw.closeMu.Lock()
+	if aw.closed {
+		aw.closeMu.Unlock()
+		return ErrClosed
+	}
+	aw.closed = true
+	close(aw.updates)
+	aw.closeMu.Unlock()
+	<-aw.done
+	return nil
+}
+
+/*
+Query returns the count of `e` as of the updates applied so far
+*/
+func (aw *AsyncWriter) Query(e []byte) float64 {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.sk.Query(e)
+}
+
+/*
+MarshalBinary encodes the sketch as of the updates applied so far, e.g. for a Checkpointer
+*/
+func (aw *AsyncWriter) MarshalBinary() ([]byte, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.sk.MarshalBinary()
+}
+
+/*
+Do runs fn on the underlying sketch while no updates are being applied
+*/
+func (aw *AsyncWriter) Do(fn func(sk *Sketch)) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	fn(aw.sk)
+}
@@ -0,0 +1,80 @@
+package cml
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	sk, _ := NewSketch(10000, 4, 1.00026)
+	aw := NewAsyncWriter(sk, 64, BlockWhenFull)
+
+	var wg sync.WaitGroup
+	for p := 0; p < 8; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				aw.Enqueue([]byte(fmt.Sprintf("producer-%d", p)), 1)
+				aw.Enqueue([]byte("shared"), 1)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if err := aw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for p := 0; p < 8; p++ {
+		if count := aw.Query([]byte(fmt.Sprintf("producer-%d", p))); count < 950 || count > 1050 {
+			t.Errorf("expected ~1000 for producer %d, got %f", p, count)
+		}
+	}
+	if count := aw.Query([]byte("shared")); count < 7600 || count > 8400 {
+		t.Errorf("expected ~8000 for shared, got %f", count)
+	}
+	if aw.Dropped() != 0 {
+		t.Errorf("expected no drops when blocking, got %d", aw.Dropped())
+	}
+
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if aw.Enqueue([]byte("late"), 1) {
+		t.Error("expected Enqueue after Close to be rejected")
+	}
+	if err := aw.Flush(context.Background()); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	aw := NewAsyncWriter(sk, 1, DropWhenFull)
+
+	// Hold the sketch so the writer cannot drain more than one update.
+	accepted := 0
+	aw.Do(func(*Sketch) {
+		for i := 0; i < 100; i++ {
+			if aw.Enqueue([]byte("key"), 1) {
+				accepted++
+			}
+		}
+	})
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if accepted+int(aw.Dropped()) != 100 {
+		t.Errorf("expected accepted (%d) and dropped (%d) to add up to 100", accepted, aw.Dropped())
+	}
+	if aw.Dropped() < 90 {
+		t.Errorf("expected most updates to be dropped, got %d", aw.Dropped())
+	}
+	if count := aw.Query([]byte("key")); math.Abs(count-float64(accepted)) > 0.5 {
+		t.Errorf("expected %d, got %f", accepted, count)
+	}
+}
@@ -0,0 +1,135 @@
+package cml
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+MaxRangeBuckets caps the number of buckets a TimeBucketed range query sums
+*/
+const MaxRangeBuckets = 1 << 16
+
+// bucketMetadataKey is the metadata entry recording a TimeBucketed's bucket duration.
+const bucketMetadataKey = "cml.bucket"
+
+/*
+TimeBucketed counts (key, time bucket) pairs in a single sketch, so a key's count can be
+summed over any range of buckets. Unlike Rotating, old buckets do not age out.
+*/
+type TimeBucketed struct {
+	bucket time.Duration
+	sketch *Sketch
+}
+
+/*
+NewTimeBucketed returns a new TimeBucketed with buckets of the given duration, counting
+into a sketch created by NewSketch(w, d, exp, opts...)
+*/
+func NewTimeBucketed(bucket time.Duration, w uint, d uint, exp float64, opts ...Option) (*TimeBucketed, error) {
+	if bucket <= 0 {
+		return nil, errors.New("bucket needs to be > 0")
+	}
+	sk, err := NewSketch(w, d, exp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := sk.SetMetadata(bucketMetadataKey, bucket.String()); err != nil {
+		return nil, err
+	}
+	return &TimeBucketed{bucket: bucket, sketch: sk}, nil
+}
+
+/*
+Bucket returns the bucket duration
+*/
+func (tb *TimeBucketed) Bucket() time.Duration {
+	return tb.bucket
+}
+
+/*
+Sketch returns the underlying sketch
+*/
+func (tb *TimeBucketed) Sketch() *Sketch {
+	return tb.sketch
+}
+
+/*
+Update increases the count of `e` in the bucket containing t by one
+*/
+func (tb *TimeBucketed) Update(e []byte, t time.Time) bool {
+	sk := tb.sketch
+	if sk.writable() != nil || sk.CheckKey(e) != nil {
+		return false
+	}
+	sk.updateResult(bucketHash(sk.hash(e), tb.index(t)), 1)
+	return true
+}
+
+/*
+QueryRange returns the estimated count of `e` summed over the buckets from the one containing
+from to the one containing to, both included. Ranges of more than MaxRangeBuckets buckets are
+cut to the last MaxRangeBuckets.
+*/
+func (tb *TimeBucketed) QueryRange(e []byte, from, to time.Time) float64 {
+	sk := tb.sketch
+	if sk.checks {
+		sk.beginRead()
+		defer sk.endRead()
+	}
+	if sk.CheckKey(e) != nil {
+		return 0
+	}
+	first, last := tb.index(from), tb.index(to)
+	if last < first {
+		return 0
+	}
+	if last-first >= MaxRangeBuckets {
+		first = last - MaxRangeBuckets + 1
+	}
+	h := sk.hash(e)
+	var sum float64
+	for i := first; i <= last; i++ {
+		sum += sk.estimate(bucketHash(h, i))
+	}
+	return sum
+}
+
+// index returns the number of the bucket containing t, counting from the Unix epoch.
+func (tb *TimeBucketed) index(t time.Time) int64 {
+	ns, b := t.UnixNano(), int64(tb.bucket)
+	i := ns / b
+	if ns%b < 0 {
+		i--
+	}
+	return i
+}
+
+// bucketHash derives the hash of a key in a bucket from the key's hash.
+func bucketHash(h keyHash, i int64) keyHash {
+	return combineHash(h, keyHash{lo: uint64(i), hi: uint64(i)})
+}
+
+/*
+MarshalBinary encodes the underlying sketch, whose metadata records the bucket duration
+*/
+func (tb *TimeBucketed) MarshalBinary() ([]byte, error) {
+	return tb.sketch.MarshalBinary()
+}
+
+/*
+UnmarshalBinary restores a TimeBucketed encoded by MarshalBinary
+*/
+func (tb *TimeBucketed) UnmarshalBinary(b []byte) error {
+	sk := &Sketch{}
+	if err := sk.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	bucket, err := time.ParseDuration(sk.metadata[bucketMetadataKey])
+	if err != nil || bucket <= 0 {
+		return errors.New("sketch has no valid bucket duration")
+	}
+	tb.bucket = bucket
+	tb.sketch = sk
+	return nil
+}
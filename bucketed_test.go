@@ -0,0 +1,69 @@
+package cml
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBucketed(t *testing.T) {
+	tb, err := NewTimeBucketed(time.Minute, 10000, 4, 1.00026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	key := []byte("GET /index.html")
+	// 100 hits in each of minutes 10 to 14.
+	for m := 10; m < 15; m++ {
+		for i := 0; i < 100; i++ {
+			tb.Update(key, start.Add(time.Duration(m)*time.Minute+time.Duration(i)*time.Second/2))
+		}
+	}
+
+	minute := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	for _, tc := range []struct {
+		from, to int
+		expected float64
+	}{
+		{10, 14, 500},
+		{0, 60, 500},
+		{12, 12, 100},
+		{13, 20, 200},
+		{0, 9, 0},
+		{15, 60, 0},
+	} {
+		got := tb.QueryRange(key, minute(tc.from), minute(tc.to))
+		if got < tc.expected*0.9 || got > tc.expected*1.1 {
+			t.Errorf("minutes %d-%d: expected about %f, got %f", tc.from, tc.to, tc.expected, got)
+		}
+	}
+	if got := tb.QueryRange(key, minute(14), minute(10)); got != 0 {
+		t.Errorf("expected an empty range to count nothing, got %f", got)
+	}
+	if got := tb.QueryRange(key, time.Unix(0, 0), minute(60)); got < 450 {
+		t.Errorf("expected a capped range to still cover the recent buckets, got %f", got)
+	}
+
+	data, err := tb.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &TimeBucketed{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Bucket() != time.Minute || restored.Sketch().Metadata()[bucketMetadataKey] != "1m0s" {
+		t.Errorf("expected the bucket duration in the metadata, got %v", restored.Sketch().Metadata())
+	}
+	if a, b := tb.QueryRange(key, minute(0), minute(60)), restored.QueryRange(key, minute(0), minute(60)); a != b {
+		t.Errorf("expected %f after a round trip, got %f", a, b)
+	}
+
+	plain, _ := NewSketch(100, 2, 1.00026)
+	data, _ = plain.MarshalBinary()
+	if err := restored.UnmarshalBinary(data); err == nil {
+		t.Error("expected error for a sketch without a bucket duration")
+	}
+	if _, err := NewTimeBucketed(0, 100, 2, 1.00026); err == nil {
+		t.Error("expected error for a zero bucket")
+	}
+}
@@ -0,0 +1,117 @@
+package cml
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+)
+
+/*
+KeyCount is a key with its estimated count
+*/
+type KeyCount struct {
+	Key   []byte
+	Count float64
+}
+
+/*
+CountsAbove returns the candidates whose estimated count exceeds threshold, sorted by
+descending count. Candidates are screened on their raw registers first, so only the
+survivors are decoded and kept; the returned keys alias the candidates. Keys refused by the
+sketch's key validation are skipped.
+*/
+func (cml *Sketch) CountsAbove(candidates [][]byte, threshold float64) []KeyCount {
+	// The doorkeeper can add one occurrence on top of the registers.
+	floor, ok := cml.register(threshold - cml.doorkeptMax())
+	if !ok {
+		return nil
+	}
+	var result []KeyCount
+	for _, key := range candidates {
+		if cml.CheckKey(key) != nil {
+			continue
+		}
+		h := cml.hash(key)
+		if !cml.allRegistersAtLeast(h, floor) {
+			continue
+		}
+		if count := cml.estimate(h); count > threshold {
+			result = append(result, KeyCount{Key: key, Count: count})
+		}
+	}
+	sortKeyCounts(result)
+	return result
+}
+
+/*
+TopAmongCandidates returns the k candidates with the highest estimated counts, sorted by
+descending count with ties broken by key bytes. It returns nil for k <= 0; the returned keys
+alias the candidates. Keys refused by the sketch's key validation are skipped.
+*/
+func (cml *Sketch) TopAmongCandidates(candidates [][]byte, k int) []KeyCount {
+	if k <= 0 {
+		return nil
+	}
+	top := make(keyCountHeap, 0, min(k, len(candidates)))
+	for _, key := range candidates {
+		if cml.CheckKey(key) != nil {
+			continue
+		}
+		kc := KeyCount{Key: key, Count: cml.estimate(cml.hash(key))}
+		if len(top) < k {
+			heap.Push(&top, kc)
+		} else if keyCountBefore(kc, top[0]) {
+			top[0] = kc
+			heap.Fix(&top, 0)
+		}
+	}
+	result := []KeyCount(top)
+	sortKeyCounts(result)
+	return result
+}
+
+// keyCountHeap is a min-heap whose root is the KeyCount that sorts last.
+type keyCountHeap []KeyCount
+
+func (h keyCountHeap) Len() int           { return len(h) }
+func (h keyCountHeap) Less(i, j int) bool { return keyCountBefore(h[j], h[i]) }
+func (h keyCountHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *keyCountHeap) Push(x any)        { *h = append(*h, x.(KeyCount)) }
+func (h *keyCountHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// allRegistersAtLeast reports whether every register probed for the hashed key
+// is at least floor, stopping at the first one that is not.
+func (cml *Sketch) allRegistersAtLeast(h keyHash, floor uint16) bool {
+	for i := range cml.d {
+		if cml.at(i, cml.column(h, int(i))) < floor {
+			return false
+		}
+	}
+	return true
+}
+
+// doorkeptMax returns the most the doorkeeper adds to any estimate, 0 or 1.
+func (cml *Sketch) doorkeptMax() float64 {
+	if len(cml.doorkeeper) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// sortKeyCounts sorts by descending count, breaking ties by key bytes.
+func sortKeyCounts(kcs []KeyCount) {
+	sort.Slice(kcs, func(i, j int) bool { return keyCountBefore(kcs[i], kcs[j]) })
+}
+
+// keyCountBefore reports whether a sorts before b: by descending count, then key bytes.
+func keyCountBefore(a, b KeyCount) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return bytes.Compare(a.Key, b.Key) < 0
+}
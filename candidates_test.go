@@ -0,0 +1,87 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountsAbove(t *testing.T) {
+	sk, _ := NewSketch(100000, 4, 1.00026)
+	candidates := make([][]byte, 100000)
+	for i := range candidates {
+		candidates[i] = []byte(fmt.Sprintf("url-%d", i))
+		sk.BulkUpdate(candidates[i], uint(i%7))
+	}
+	for i, freq := range []uint{5000, 2000, 1000, 700, 120} {
+		sk.BulkUpdate(candidates[i*1000], freq)
+	}
+
+	result := sk.CountsAbove(candidates, 100)
+	var expected []KeyCount
+	for _, key := range candidates {
+		if count := sk.Query(key); count > 100 {
+			expected = append(expected, KeyCount{key, count})
+		}
+	}
+	sortKeyCounts(expected)
+
+	if len(result) != 5 || len(result) != len(expected) {
+		t.Fatalf("expected 5 keys, got %d (brute force %d)", len(result), len(expected))
+	}
+	for i := range result {
+		if string(result[i].Key) != string(expected[i].Key) || result[i].Count != expected[i].Count {
+			t.Errorf("expected %s=%f at %d, got %s=%f", expected[i].Key, expected[i].Count, i, result[i].Key, result[i].Count)
+		}
+		if i > 0 && result[i].Count > result[i-1].Count {
+			t.Errorf("expected descending counts at %d", i)
+		}
+	}
+
+	if result := sk.CountsAbove(candidates, 1e12); len(result) != 0 {
+		t.Errorf("expected no keys above an unreachable threshold, got %d", len(result))
+	}
+}
+
+func TestCountsAboveDoorkeeper(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<16))
+	key := []byte("once")
+	sk.Update(key)
+	result := sk.CountsAbove([][]byte{key}, 0.5)
+	if len(result) != 1 || result[0].Count != sk.Query(key) {
+		t.Errorf("expected the key seen once with count %f, got %v", sk.Query(key), result)
+	}
+}
+
+func TestTopAmongCandidates(t *testing.T) {
+	sk, _ := NewSketch(10000, 4, 1.00026)
+	candidates := make([][]byte, 3000)
+	for i := range candidates {
+		candidates[i] = []byte(fmt.Sprintf("url-%d", i))
+		sk.BulkUpdate(candidates[i], uint(i%50))
+	}
+
+	all := make([]KeyCount, len(candidates))
+	for i, key := range candidates {
+		all[i] = KeyCount{Key: key, Count: sk.Query(key)}
+	}
+	sortKeyCounts(all)
+
+	for _, k := range []int{1, 10, 100, 3000, 5000} {
+		result := sk.TopAmongCandidates(candidates, k)
+		expected := all[:min(k, len(all))]
+		if len(result) != len(expected) {
+			t.Errorf("k=%d: expected %d results, got %d", k, len(expected), len(result))
+			continue
+		}
+		for i := range expected {
+			if string(result[i].Key) != string(expected[i].Key) || result[i].Count != expected[i].Count {
+				t.Errorf("k=%d: expected %s=%v at %d, got %s=%v", k, expected[i].Key, expected[i].Count, i, result[i].Key, result[i].Count)
+				break
+			}
+		}
+	}
+
+	if result := sk.TopAmongCandidates(candidates, 0); result != nil {
+		t.Errorf("expected nil for k=0, got %v", result)
+	}
+}
@@ -0,0 +1,157 @@
+package cml
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// checkpointBackoff is the first delay before retrying a failed checkpoint; it
+// doubles with every further failure up to the checkpoint interval.
+const checkpointBackoff = time.Second
+
+/*
+ErrCheckpointerClosed is returned when closing a Checkpointer again
+*/
+var ErrCheckpointerClosed = errors.New("checkpointer closed")
+
+/*
+CheckpointSink receives the encoding of a checkpoint, e.g. to upload it
+*/
+type CheckpointSink func(ctx context.Context, r io.Reader) error
+
+/*
+CheckpointStatus describes a Checkpointer's checkpoints so far
+*/
+type CheckpointStatus struct {
+	// LastSuccess is when the last checkpoint was written, and LastBytes its size.
+	LastSuccess time.Time
+	LastBytes   int
+	Checkpoints uint64
+	Failures    uint64
+	// LastError is the error of the last checkpoint, nil if it succeeded.
+	LastError error
+}
+
+/*
+Checkpointer periodically encodes a sketch and writes the encoding to a sink from a
+background goroutine. Each checkpoint is a single MarshalBinary call, so it is consistent
+as long as src is not updated during the call: pass an AsyncWriter, which encodes between
+updates, or a Sketch that is not updated concurrently.
+*/
+type Checkpointer struct {
+	src      encoding.BinaryMarshaler
+	interval time.Duration
+	sink     CheckpointSink
+	onError  func(error)
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+
+	mu     sync.Mutex
+	status CheckpointStatus
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+/*
+NewCheckpointer returns a new Checkpointer writing src to sink every interval. A failed
+checkpoint is passed to onError, which may be nil, and retried after a backoff growing from
+one second up to the interval.
+*/
+func NewCheckpointer(src encoding.BinaryMarshaler, interval time.Duration, sink CheckpointSink, onError func(error)) (*Checkpointer, error) {
+	return newCheckpointer(src, interval, sink, onError, time.Now, time.After)
+}
+
+// newCheckpointer is NewCheckpointer with the clock replaced, e.g. with a fake one in tests.
+func newCheckpointer(src encoding.BinaryMarshaler, interval time.Duration, sink CheckpointSink, onError func(error),
+	now func() time.Time, after func(time.Duration) <-chan time.Time) (*Checkpointer, error) {
+	if src == nil || sink == nil {
+		return nil, errors.New("checkpoint source and sink must not be nil")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval needs to be > 0")
+	}
+	c := &Checkpointer{
+		src:      src,
+		interval: interval,
+		sink:     sink,
+		onError:  onError,
+		now:      now,
+		after:    after,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *Checkpointer) run() {
+	defer close(c.done)
+	wait, backoff := c.interval, checkpointBackoff
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.after(wait):
+		}
+		if err := c.Checkpoint(context.Background()); err != nil {
+			if c.onError != nil {
+				c.onError(err)
+			}
+			wait, backoff = min(backoff, c.interval), 2*backoff
+		} else {
+			wait, backoff = c.interval, checkpointBackoff
+		}
+	}
+}
+
+/*
+Checkpoint writes a checkpoint now, outside the schedule
+*/
+func (c *Checkpointer) Checkpoint(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := c.src.MarshalBinary()
+	if err == nil {
+		err = c.sink(ctx, bytes.NewReader(data))
+	}
+	c.status.LastError = err
+	if err != nil {
+		c.status.Failures++
+		return err
+	}
+	c.status.LastSuccess = c.now()
+	c.status.LastBytes = len(data)
+	c.status.Checkpoints++
+	return nil
+}
+
+/*
+Status returns the outcome of the checkpoints so far
+*/
+func (c *Checkpointer) Status() CheckpointStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+/*
+Close stops the schedule and writes a final checkpoint, returning its error. It returns
+ErrCheckpointerClosed if called again.
+*/
+func (c *Checkpointer) Close() error {
+	err := ErrCheckpointerClosed
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+		err = c.Checkpoint(context.Background())
+	})
+	return err
+}
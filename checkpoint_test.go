@@ -0,0 +1,160 @@
+package cml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTimer hands out a timer channel per wait, fired by the test.
+type fakeTimer struct {
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func newFakeTimer() *fakeTimer {
+	return &fakeTimer{waits: make(chan time.Duration, 100), fire: make(chan time.Time)}
+}
+
+func (f *fakeTimer) After(d time.Duration) <-chan time.Time {
+	f.waits <- d
+	return f.fire
+}
+
+// memorySink records checkpoints, failing while fail is set.
+type memorySink struct {
+	mu    sync.Mutex
+	fail  bool
+	blobs [][]byte
+	wrote chan struct{}
+}
+
+func (s *memorySink) write(ctx context.Context, r io.Reader) error {
+	defer func() { s.wrote <- struct{}{} }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("upload failed")
+	}
+	b, err := io.ReadAll(r)
+	s.blobs = append(s.blobs, b)
+	return err
+}
+
+func TestCheckpointer(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	aw := NewAsyncWriter(sk, 64, BlockWhenFull)
+	defer aw.Close()
+	timer := newFakeTimer()
+	sink := &memorySink{wrote: make(chan struct{}, 100)}
+	var errs []error
+	c, err := newCheckpointer(aw, time.Minute, sink.write, func(err error) { errs = append(errs, err) },
+		func() time.Time { return time.Unix(1000, 0) }, timer.After)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates keep arriving while checkpoints are taken.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				aw.Enqueue([]byte(fmt.Sprint(i%100)), 1)
+			}
+		}
+	}()
+
+	tick := func() {
+		timer.fire <- time.Time{}
+		<-sink.wrote
+	}
+	for i := 0; i < 3; i++ {
+		if d := <-timer.waits; d != time.Minute {
+			t.Errorf("expected to wait the interval, got %v", d)
+		}
+		tick()
+	}
+
+	// Failures are reported and retried with a growing backoff.
+	sink.mu.Lock()
+	sink.fail = true
+	sink.mu.Unlock()
+	for _, expected := range []time.Duration{time.Minute, time.Second, 2 * time.Second} {
+		if d := <-timer.waits; d != expected {
+			t.Errorf("expected to wait %v, got %v", expected, d)
+		}
+		tick()
+	}
+	sink.mu.Lock()
+	sink.fail = false
+	sink.mu.Unlock()
+	if d := <-timer.waits; d != 4*time.Second {
+		t.Errorf("expected to wait 4s, got %v", d)
+	}
+	tick()
+	if d := <-timer.waits; d != time.Minute {
+		t.Errorf("expected a success to restore the interval, got %v", d)
+	}
+	close(stop)
+	wg.Wait()
+	aw.Flush(context.Background())
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != ErrCheckpointerClosed {
+		t.Errorf("expected ErrCheckpointerClosed, got %v", err)
+	}
+
+	status := c.Status()
+	if len(errs) != 3 || status.Failures != 3 || status.Checkpoints != 5 || status.LastError != nil {
+		t.Errorf("expected 3 failures and 5 checkpoints, got %+v and errors %v", status, errs)
+	}
+	if !status.LastSuccess.Equal(time.Unix(1000, 0)) || status.LastBytes != len(sink.blobs[4]) {
+		t.Errorf("unexpected last success %+v", status)
+	}
+
+	// Every checkpoint is a consistent sketch that only grows, and the final one has
+	// every update.
+	final := aw.Query([]byte("7"))
+	var prev *Sketch
+	for i, blob := range sink.blobs {
+		cp := &Sketch{}
+		if err := cp.UnmarshalBinary(blob); err != nil {
+			t.Fatalf("checkpoint %d: %v", i, err)
+		}
+		if prev != nil {
+			for j := 0; j < 100; j++ {
+				key := []byte(fmt.Sprint(j))
+				if cp.Query(key) < prev.Query(key) {
+					t.Errorf("checkpoint %d: expected counts to grow", i)
+				}
+			}
+		}
+		prev = cp
+	}
+	if got := prev.Query([]byte("7")); got != final {
+		t.Errorf("expected the final checkpoint to hold %f, got %f", final, got)
+	}
+}
+
+func TestCheckpointerInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 2, 1.00026)
+	sink := func(context.Context, io.Reader) error { return nil }
+	if _, err := NewCheckpointer(sk, 0, sink, nil); err == nil {
+		t.Error("expected error for a zero interval")
+	}
+	if _, err := NewCheckpointer(sk, time.Minute, nil, nil); err == nil {
+		t.Error("expected error for a nil sink")
+	}
+}
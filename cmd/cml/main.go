@@ -0,0 +1,250 @@
+/*
+Command cml builds, queries and merges Count-Min-Log Sketch files.
+
+Usage:
+
+	cml build [-capacity n] [-error e] [-maxcount n] [-weighted] -o out [input]
+	cml query sketch key...
+	cml merge -o out sketch...
+	cml stats sketch
+
+build reads newline-delimited keys, or key<TAB>count lines with -weighted, from input or
+standard input, skipping empty lines.
+Sketch files hold the sketch's MarshalBinary encoding. The exit status is 0 on success, 1 for
+bad or incompatible data and 2 for usage errors.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+// Exit statuses.
+const (
+	exitOK    = 0
+	exitData  = 1
+	exitUsage = 2
+)
+
+// maxLineSize caps the length of an input line of build.
+const maxLineSize = 1 << 20
+
+// errUsage marks errors in the command line rather than the data.
+var errUsage = errors.New("usage error")
+
+func main() {
+	os.Exit(Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+/*
+Run runs the command with the given arguments, without the program name, and returns its
+exit status
+*/
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: cml build|query|merge|stats [arguments]")
+		return exitUsage
+	}
+	var err error
+	switch args[0] {
+	case "build":
+		err = Build(args[1:], stdin, stderr)
+	case "query":
+		err = Query(args[1:], stdout, stderr)
+	case "merge":
+		err = Merge(args[1:], stderr)
+	case "stats":
+		err = Stats(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "cml: unknown command %q\n", args[0])
+		return exitUsage
+	}
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "cml %s: %v\n", args[0], err)
+		}
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "cml %s: %v\n", args[0], err)
+	return exitData
+}
+
+// usage wraps err as a usage error.
+func usage(err error) error {
+	return fmt.Errorf("%w: %w", errUsage, err)
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("cml "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+/*
+Build runs the build command
+*/
+func Build(args []string, stdin io.Reader, stderr io.Writer) error {
+	fs := newFlagSet("build", stderr)
+	capacity := fs.Uint64("capacity", 1000000, "expected number of distinct keys")
+	e := fs.Float64("error", 0.01, "relative error of the estimates")
+	maxCount := fs.Uint64("maxcount", 0, "largest count to represent, 0 for the default range")
+	weighted := fs.Bool("weighted", false, "read key<TAB>count lines")
+	out := fs.String("o", "", "output sketch file")
+	if err := fs.Parse(args); err != nil {
+		return usage(err)
+	}
+	if *out == "" || fs.NArg() > 1 {
+		return usage(errors.New("need -o and at most one input file"))
+	}
+
+	var (
+		sk  *cml.Sketch
+		err error
+	)
+	if *maxCount > 0 {
+		sk, err = cml.NewForCapacityAndMaxCount(*capacity, *e, *maxCount)
+	} else {
+		sk, err = cml.NewForCapacity16(*capacity, *e)
+	}
+	if err != nil {
+		return usage(err)
+	}
+
+	in := stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if *weighted {
+		_, err = sk.LoadWeightedFromReaderSize(in, maxLineSize)
+	} else {
+		_, err = sk.LoadFromReaderSize(in, maxLineSize)
+	}
+	if err != nil {
+		return err
+	}
+	return writeSketch(*out, sk)
+}
+
+/*
+Query runs the query command, printing each key and its estimate separated by a tab
+*/
+func Query(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("query", stderr)
+	if err := fs.Parse(args); err != nil {
+		return usage(err)
+	}
+	if fs.NArg() < 2 {
+		return usage(errors.New("need a sketch file and at least one key"))
+	}
+	sk, err := readSketch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, key := range fs.Args()[1:] {
+		fmt.Fprintf(stdout, "%s\t%g\n", key, sk.Query([]byte(key)))
+	}
+	return nil
+}
+
+/*
+Merge runs the merge command, streaming each input into the result
+*/
+func Merge(args []string, stderr io.Writer) error {
+	fs := newFlagSet("merge", stderr)
+	out := fs.String("o", "", "output sketch file")
+	if err := fs.Parse(args); err != nil {
+		return usage(err)
+	}
+	if *out == "" || fs.NArg() == 0 {
+		return usage(errors.New("need -o and at least one input file"))
+	}
+
+	var sk *cml.Sketch
+	for _, path := range fs.Args() {
+		if err := mergeFile(&sk, path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return writeSketch(*out, sk)
+}
+
+// mergeFile merges the sketch file at path into *sk, first creating it like
+// the file's sketch if it is nil.
+func mergeFile(sk **cml.Sketch, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if *sk == nil {
+		hdr := make([]byte, cml.FormatSpec().HeaderSize)
+		if _, err := io.ReadFull(f, hdr); err != nil {
+			return err
+		}
+		if *sk, err = cml.NewLikeSerialized(hdr); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	_, err = (*sk).MergeFrom(bufio.NewReader(f))
+	return err
+}
+
+/*
+Stats runs the stats command
+*/
+func Stats(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("stats", stderr)
+	if err := fs.Parse(args); err != nil {
+		return usage(err)
+	}
+	if fs.NArg() != 1 {
+		return usage(errors.New("need one sketch file"))
+	}
+	sk, err := readSketch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s := sk.Stats()
+	fmt.Fprintf(stdout, "width\t%d\ndepth\t%d\nexp\t%g\nfill\t%.2f%%\nsaturated\t%d\nbytes\t%d\n",
+		s.Width, s.Depth, s.Exp, s.FillRatePct, s.SaturatedRegisters, s.StoreBytes)
+	return nil
+}
+
+func readSketch(path string) (*cml.Sketch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sk := &cml.Sketch{}
+	if _, err := sk.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sk, nil
+}
+
+func writeSketch(path string, sk *cml.Sketch) error {
+	data, err := sk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
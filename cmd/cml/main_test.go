@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// estimates parses the output of query.
+func estimates(t *testing.T, out string) map[string]float64 {
+	t.Helper()
+	m := map[string]float64{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		key, v, _ := strings.Cut(line, "\t")
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			t.Fatalf("unexpected query output %q", line)
+		}
+		m[key] = f
+	}
+	return m
+}
+
+func TestBuildQueryMergeStats(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "keys.tsv")
+	os.WriteFile(input, []byte("apple\t100\nbanana\t2\n\ncherry pie\t7\n"), 0o644)
+	a, b, merged := filepath.Join(dir, "a.cml"), filepath.Join(dir, "b.cml"), filepath.Join(dir, "merged.cml")
+
+	if code, _, stderr := run(t, "", "build", "-capacity", "1000", "-weighted", "-o", a, input); code != exitOK {
+		t.Fatalf("build failed with %d: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "apple\n\ndate\n", "build", "-capacity", "1000", "-o", b); code != exitOK {
+		t.Fatalf("build from stdin failed with %d: %s", code, stderr)
+	}
+
+	code, out, _ := run(t, "", "query", a, "apple", "banana", "cherry pie", "durian")
+	if code != exitOK {
+		t.Fatalf("query failed with %d", code)
+	}
+	got := estimates(t, out)
+	for key, expected := range map[string]float64{"apple": 100, "banana": 2, "cherry pie": 7, "durian": 0, "": 0} {
+		if got[key] < expected*0.9 || got[key] > expected*1.1+0.5 {
+			t.Errorf("expected about %f for %s, got %f", expected, key, got[key])
+		}
+	}
+
+	if code, _, stderr := run(t, "", "merge", "-o", merged, a, b); code != exitOK {
+		t.Fatalf("merge failed with %d: %s", code, stderr)
+	}
+	_, out, _ = run(t, "", "query", merged, "apple", "date", "")
+	got = estimates(t, out)
+	if got["apple"] < 90 || got["date"] != 1 || got[""] != 0 {
+		t.Errorf("expected the merged counts, got %v", got)
+	}
+
+	code, out, _ = run(t, "", "stats", merged)
+	if code != exitOK || !strings.Contains(out, "width\t") || !strings.Contains(out, "saturated\t0") {
+		t.Errorf("unexpected stats output %q", out)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	small, large := filepath.Join(dir, "small.cml"), filepath.Join(dir, "large.cml")
+	run(t, "a\n", "build", "-capacity", "100", "-o", small)
+	run(t, "a\n", "build", "-capacity", "10000000", "-error", "0.001", "-o", large)
+	garbage := filepath.Join(dir, "garbage.cml")
+	os.WriteFile(garbage, []byte("not a sketch"), 0o644)
+	// A valid header declaring 2^40 registers per row, and nothing else.
+	hdr, _ := os.ReadFile(small)
+	hdr = hdr[:cml.FormatSpec().HeaderSize]
+	binary.LittleEndian.PutUint64(hdr[8:], 1<<40)
+	huge := filepath.Join(dir, "huge.cml")
+	os.WriteFile(huge, hdr, 0o644)
+
+	for _, tc := range []struct {
+		stdin string
+		args  []string
+		code  int
+	}{
+		{"", nil, exitUsage},
+		{"", []string{"frobnicate"}, exitUsage},
+		{"", []string{"build", "a.txt"}, exitUsage},
+		{"", []string{"build", "-bogus", "-o", small}, exitUsage},
+		{"", []string{"build", "-error", "2", "-o", filepath.Join(dir, "x")}, exitUsage},
+		{"", []string{"query", small}, exitUsage},
+		{"", []string{"merge", small}, exitUsage},
+		{"", []string{"stats"}, exitUsage},
+		{"a\tlots\n", []string{"build", "-weighted", "-o", filepath.Join(dir, "x")}, exitData},
+		{"a\n", []string{"build", "-weighted", "-o", filepath.Join(dir, "x")}, exitData},
+		{"", []string{"query", garbage, "a"}, exitData},
+		{"", []string{"query", filepath.Join(dir, "missing"), "a"}, exitData},
+		{"", []string{"merge", "-o", filepath.Join(dir, "x"), small, large}, exitData},
+		{"", []string{"merge", "-o", filepath.Join(dir, "x"), huge, small}, exitData},
+	} {
+		code, _, stderr := run(t, tc.stdin, tc.args...)
+		if code != tc.code {
+			t.Errorf("%v: expected exit %d, got %d (%s)", tc.args, tc.code, code, stderr)
+		}
+	}
+
+	_, _, stderr := run(t, "", "merge", "-o", filepath.Join(dir, "x"), small, large)
+	if !strings.Contains(stderr, "large.cml") || !strings.Contains(stderr, "mismatch") {
+		t.Errorf("expected the incompatible file to be named, got %q", stderr)
+	}
+}
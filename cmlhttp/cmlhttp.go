@@ -0,0 +1,205 @@
+/*
+Package cmlhttp exposes a Count-Min-Log Sketch over HTTP for operational tooling.
+
+Routes:
+
+	POST /update  count a key: a JSON body {"key": "...", "freq": n} (freq defaults to 1), or an
+	              application/octet-stream body holding the raw key with freq as a query parameter
+	GET  /query   estimate ?key=...: JSON, or a little-endian float64 for Accept: application/octet-stream
+	GET  /stats   the sketch's Stats as JSON
+	POST /merge   merge the MarshalBinary encoding in the body; 409 if the sketches are incompatible,
+	              413 if the body exceeds 1 GiB
+	GET  /dump    the sketch's MarshalBinary encoding
+*/
+package cmlhttp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+// maxKeySize caps the body of an update.
+const maxKeySize = 1 << 20
+
+// maxMergeSize caps the body of a merge. It is a variable for the tests.
+var maxMergeSize int64 = 1 << 30
+
+const (
+	contentJSON   = "application/json"
+	contentBinary = "application/octet-stream"
+)
+
+type handler struct {
+	mu sync.Mutex
+	sk cml.Sketcher
+}
+
+type updateRequest struct {
+	Key  string `json:"key"`
+	Freq *uint  `json:"freq,omitempty"`
+}
+
+type queryResponse struct {
+	Key      string  `json:"key"`
+	Estimate float64 `json:"estimate"`
+}
+
+/*
+NewHandler returns a handler serving sk, serializing all access to it. /stats and /merge need
+a *cml.Sketch, or another sketch with Stats and Merge methods, and answer 501 otherwise.
+*/
+func NewHandler(sk cml.Sketcher) http.Handler {
+	h := &handler{sk: sk}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /update", h.update)
+	mux.HandleFunc("GET /query", h.query)
+	mux.HandleFunc("GET /stats", h.stats)
+	mux.HandleFunc("POST /merge", h.merge)
+	mux.HandleFunc("GET /dump", h.dump)
+	return mux
+}
+
+func (h *handler) update(w http.ResponseWriter, r *http.Request) {
+	var (
+		key  []byte
+		freq uint = 1
+	)
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case contentJSON, "":
+		var req updateRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxKeySize)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		key = []byte(req.Key)
+		if req.Freq != nil {
+			freq = *req.Freq
+		}
+	case contentBinary:
+		var err error
+		if key, err = io.ReadAll(io.LimitReader(r.Body, maxKeySize)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f := r.URL.Query().Get("freq"); f != "" {
+			n, err := strconv.ParseUint(f, 10, 0)
+			if err != nil {
+				http.Error(w, "invalid freq", http.StatusBadRequest)
+				return
+			}
+			freq = uint(n)
+		}
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	h.mu.Lock()
+	ok := h.sk.InsertN(key, freq)
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "update refused", http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) query(w http.ResponseWriter, r *http.Request) {
+	key, ok := r.URL.Query()["key"]
+	if !ok {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	estimate := h.sk.Estimate([]byte(key[0]))
+	h.mu.Unlock()
+
+	if mediaType(r.Header.Get("Accept")) == contentBinary {
+		w.Header().Set("Content-Type", contentBinary)
+		w.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(estimate)))
+		return
+	}
+	writeJSON(w, queryResponse{Key: key[0], Estimate: estimate})
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.sk.(interface{ Stats() cml.SketchStats })
+	if !ok {
+		http.Error(w, "sketch has no stats", http.StatusNotImplemented)
+		return
+	}
+	h.mu.Lock()
+	stats := s.Stats()
+	h.mu.Unlock()
+	writeJSON(w, stats)
+}
+
+func (h *handler) merge(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.sk.(interface{ Merge(*cml.Sketch) error })
+	if !ok {
+		http.Error(w, "sketch does not merge", http.StatusNotImplemented)
+		return
+	}
+	other := &cml.Sketch{}
+	if _, err := other.ReadFrom(http.MaxBytesReader(w, r.Body, maxMergeSize)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "invalid sketch: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	h.mu.Lock()
+	err := m.Merge(other)
+	h.mu.Unlock()
+	var mismatch *cml.MismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) dump(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	data, err := h.sk.MarshalBinary()
+	h.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentBinary)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", contentJSON)
+	json.NewEncoder(w).Encode(v)
+}
+
+// mediaType returns the media type of a Content-Type or the first one of an Accept header.
+func mediaType(header string) string {
+	for i := 0; i < len(header); i++ {
+		if header[i] == ',' {
+			header = header[:i]
+			break
+		}
+	}
+	t, _, _ := mime.ParseMediaType(header)
+	return t
+}
@@ -0,0 +1,170 @@
+package cmlhttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+func newServer(t *testing.T) (*cml.Sketch, *httptest.Server) {
+	sk, _ := cml.NewSketch(1000, 4, 1.00026, cml.WithRejectEmptyKeys())
+	srv := httptest.NewServer(NewHandler(sk))
+	t.Cleanup(srv.Close)
+	return sk, srv
+}
+
+func do(t *testing.T, method, url, contentType, accept string, body io.Reader) *http.Response {
+	req, _ := http.NewRequest(method, url, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestUpdateAndQuery(t *testing.T) {
+	_, srv := newServer(t)
+
+	for _, tc := range []struct {
+		url, contentType, body string
+		status                 int
+	}{
+		{"/update", contentJSON, `{"key": "a", "freq": 100}`, http.StatusNoContent},
+		{"/update", "", `{"key": "a"}`, http.StatusNoContent},
+		{"/update?freq=10", contentBinary, "b", http.StatusNoContent},
+		{"/update", contentBinary, "b", http.StatusNoContent},
+		{"/update", contentJSON, `{"key": ""}`, http.StatusUnprocessableEntity},
+		{"/update", contentJSON, `{"key":`, http.StatusBadRequest},
+		{"/update?freq=x", contentBinary, "b", http.StatusBadRequest},
+		{"/update", "text/plain", "a", http.StatusUnsupportedMediaType},
+	} {
+		resp := do(t, "POST", srv.URL+tc.url, tc.contentType, "", strings.NewReader(tc.body))
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %q: expected %d, got %d", tc.url, tc.body, tc.status, resp.StatusCode)
+		}
+	}
+
+	resp := do(t, "GET", srv.URL+"/query?key=a", "", "", nil)
+	var q queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&q); err != nil {
+		t.Fatal(err)
+	}
+	if q.Key != "a" || q.Estimate < 95 || q.Estimate > 107 {
+		t.Errorf("expected about 101 for a, got %+v", q)
+	}
+
+	resp = do(t, "GET", srv.URL+"/query?key=b", "", contentBinary, nil)
+	b, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != contentBinary || len(b) != 8 {
+		t.Fatalf("expected an 8-byte binary estimate, got %x", b)
+	}
+	if est := math.Float64frombits(binary.LittleEndian.Uint64(b)); est < 10 || est > 12 {
+		t.Errorf("expected about 11 for b, got %f", est)
+	}
+
+	if resp := do(t, "GET", srv.URL+"/query", "", "", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing key, got %d", resp.StatusCode)
+	}
+	if resp := do(t, "GET", srv.URL+"/update", "", "", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	sk, srv := newServer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				resp, err := http.Post(srv.URL+"/update", contentBinary, strings.NewReader("k"))
+				if err == nil {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := sk.Stats().TotalUpdates; got != 400 {
+		t.Errorf("expected 400 updates, got %d", got)
+	}
+}
+
+func TestStatsAndDump(t *testing.T) {
+	sk, srv := newServer(t)
+	sk.BulkUpdate([]byte("a"), 1000)
+
+	resp := do(t, "GET", srv.URL+"/stats", "", "", nil)
+	var stats cml.SketchStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats != sk.Stats() {
+		t.Errorf("expected %+v, got %+v", sk.Stats(), stats)
+	}
+
+	resp = do(t, "GET", srv.URL+"/dump", "", "", nil)
+	data, _ := io.ReadAll(resp.Body)
+	expected, _ := sk.MarshalBinary()
+	if resp.Header.Get("Content-Type") != contentBinary || !bytes.Equal(data, expected) {
+		t.Error("expected the dump to be the sketch's encoding")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	sk, srv := newServer(t)
+
+	other, _ := cml.NewSketch(1000, 4, 1.00026)
+	other.BulkUpdate([]byte("merged"), 500)
+	data, _ := other.MarshalBinary()
+	if resp := do(t, "POST", srv.URL+"/merge", contentBinary, "", bytes.NewReader(data)); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+	if got := sk.Query([]byte("merged")); got < 450 {
+		t.Errorf("expected the merged counts, got %f", got)
+	}
+
+	narrow, _ := cml.NewSketch(500, 4, 1.00026)
+	data, _ = narrow.MarshalBinary()
+	if resp := do(t, "POST", srv.URL+"/merge", contentBinary, "", bytes.NewReader(data)); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for an incompatible sketch, got %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/merge", contentBinary, "", strings.NewReader("garbage")); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid sketch, got %d", resp.StatusCode)
+	}
+
+	defer func(n int64) { maxMergeSize = n }(maxMergeSize)
+	maxMergeSize = int64(len(data) - 1)
+	if resp := do(t, "POST", srv.URL+"/merge", contentBinary, "", bytes.NewReader(data)); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized body, got %d", resp.StatusCode)
+	}
+	maxMergeSize = int64(len(data))
+	if resp := do(t, "POST", srv.URL+"/merge", contentBinary, "", bytes.NewReader(data)); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected a body at the limit to be read, got %d", resp.StatusCode)
+	}
+
+	r, _ := cml.NewRotating(2, time.Minute, 100, 2, 1.00026)
+	rsrv := httptest.NewServer(NewHandler(r))
+	defer rsrv.Close()
+	if resp := do(t, "POST", rsrv.URL+"/merge", contentBinary, "", bytes.NewReader(data)); resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501 for a sketch that does not merge, got %d", resp.StatusCode)
+	}
+}
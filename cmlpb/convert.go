@@ -0,0 +1,63 @@
+/*
+Package cmlpb provides a protobuf representation of Count-Min-Log Sketches.
+
+The generated code is checked in, so building does not require protoc.
+*/
+package cmlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative sketch.proto
+
+import (
+	"errors"
+	"math"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+/*
+ToProto returns sk as a protobuf message
+*/
+func ToProto(sk *cml.Sketch) *Sketch {
+	dto := sk.MarshalStructured()
+	return &Sketch{
+		Width:        dto.W,
+		Depth:        dto.D,
+		Exp:          dto.Exp,
+		RegisterBits: uint32(dto.RegisterBits),
+		Hash:         uint32(dto.Hash),
+		Flags:        uint32(dto.Flags),
+		Store:        dto.Store,
+		Total:        &dto.Total,
+		Seed:         dto.Seed,
+		Metadata:     dto.Metadata,
+		Doorkeeper:   dto.Doorkeeper,
+	}
+}
+
+/*
+FromProto returns the sketch described by pb, validated like UnmarshalBinary
+*/
+func FromProto(pb *Sketch) (*cml.Sketch, error) {
+	if pb.GetRegisterBits() > math.MaxUint8 {
+		return nil, errors.New("invalid register_bits")
+	}
+	if pb.GetHash() > math.MaxUint8 {
+		return nil, errors.New("invalid hash")
+	}
+	if pb.GetFlags() > math.MaxUint8 {
+		return nil, errors.New("invalid flags")
+	}
+	return cml.FromStructured(cml.SketchDTO{
+		W:            pb.GetWidth(),
+		D:            pb.GetDepth(),
+		Exp:          pb.GetExp(),
+		RegisterBits: uint8(pb.GetRegisterBits()),
+		Hash:         uint8(pb.GetHash()),
+		Flags:        uint8(pb.GetFlags()),
+		Store:        pb.GetStore(),
+		Total:        pb.GetTotal(),
+		Seed:         pb.Seed,
+		Metadata:     pb.GetMetadata(),
+		Doorkeeper:   pb.GetDoorkeeper(),
+	})
+}
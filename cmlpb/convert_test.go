@@ -0,0 +1,98 @@
+package cmlpb
+
+import (
+	"bytes"
+	"testing"
+
+	cml "github.com/seiflotfy/count-min-log"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	sk, _ := cml.NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 100)
+
+	data, err := proto.Marshal(ToProto(sk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb := &Sketch{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.GetWidth() != 100 || pb.GetDepth() != 3 || pb.GetRegisterBits() != 16 {
+		t.Errorf("expected 100x3 uint16 sketch, got %dx%d with %d bits", pb.GetWidth(), pb.GetDepth(), pb.GetRegisterBits())
+	}
+	restored, err := FromProto(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := sk.MarshalBinary()
+	if got, _ := restored.MarshalBinary(); !bytes.Equal(got, expected) {
+		t.Error("expected identical sketch after round trip")
+	}
+}
+
+func TestProtoTotalAndSeed(t *testing.T) {
+	sk, _ := cml.NewSketch(100, 3, 1.00026, cml.WithSeed(42))
+	sk.BulkUpdate([]byte("a"), 100)
+	data, _ := proto.Marshal(ToProto(sk))
+	pb := &Sketch{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.GetTotal() != 100 || pb.Seed == nil || pb.GetSeed() != 42 {
+		t.Fatalf("expected total 100 and seed 42, got %d and %v", pb.GetTotal(), pb.Seed)
+	}
+	restored, err := FromProto(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Stats().TotalUpdates != 100 {
+		t.Errorf("expected the total to be restored, got %d", restored.Stats().TotalUpdates)
+	}
+
+	unseeded, _ := cml.NewSketch(100, 3, 1.00026)
+	if pb := ToProto(unseeded); pb.Seed != nil {
+		t.Errorf("expected no seed for an unseeded sketch, got %d", pb.GetSeed())
+	}
+}
+
+func TestProtoDoorkeeperAndMetadata(t *testing.T) {
+	sk, _ := cml.NewSketch(100, 3, 1.00026, cml.WithDoorkeeper(1<<10))
+	sk.SetMetadata("tenant", "a")
+	sk.Update([]byte("once"))
+
+	data, _ := proto.Marshal(ToProto(sk))
+	pb := &Sketch{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := FromProto(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := sk.MarshalBinary()
+	if got, _ := restored.MarshalBinary(); !bytes.Equal(got, expected) {
+		t.Error("expected the doorkeeper and metadata to survive the round trip")
+	}
+	if got := restored.Query([]byte("once")); got != 1 {
+		t.Errorf("expected the doorkept key to estimate 1, got %f", got)
+	}
+}
+
+func TestFromProtoInvalid(t *testing.T) {
+	sk, _ := cml.NewSketch(100, 3, 1.00026)
+	for _, bits := range []uint32{8, 32, 256 + 16} {
+		pb := ToProto(sk)
+		pb.RegisterBits = bits
+		if _, err := FromProto(pb); err == nil {
+			t.Errorf("expected error for %d register bits", bits)
+		}
+	}
+	pb := ToProto(sk)
+	pb.Store = pb.Store[:10]
+	if _, err := FromProto(pb); err == nil {
+		t.Error("expected error for truncated store")
+	}
+}
@@ -0,0 +1,236 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sketch.proto
+
+package cmlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Sketch is a Count-Min-Log Sketch.
+type Sketch struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of registers per row.
+	Width uint64 `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	// Number of rows.
+	Depth uint64 `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	// Base of the logarithmic counters.
+	Exp float64 `protobuf:"fixed64,3,opt,name=exp,proto3" json:"exp,omitempty"`
+	// Width of a register in bits.
+	RegisterBits uint32 `protobuf:"varint,4,opt,name=register_bits,json=registerBits,proto3" json:"register_bits,omitempty"`
+	// Registers as little-endian values of register_bits bits in the order of the binary
+	// encoding: row by row, or column by column if flags has the banded bit set.
+	Store []byte `protobuf:"bytes,5,opt,name=store,proto3" json:"store,omitempty"`
+	// Hashing scheme, as in the binary encoding.
+	Hash uint32 `protobuf:"varint,6,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Mode flags, as in the binary encoding.
+	Flags uint32 `protobuf:"varint,7,opt,name=flags,proto3" json:"flags,omitempty"`
+	// Number of updates counted, for the sketch's statistics.
+	Total *uint64 `protobuf:"varint,8,opt,name=total,proto3,oneof" json:"total,omitempty"`
+	// Seed of the random increments, if one was set.
+	Seed *uint64 `protobuf:"varint,9,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	// Metadata entries, as in the binary encoding.
+	Metadata map[string]string `protobuf:"bytes,10,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Doorkeeper bits as 64-bit words, empty without a doorkeeper.
+	Doorkeeper    []uint64 `protobuf:"fixed64,11,rep,packed,name=doorkeeper,proto3" json:"doorkeeper,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sketch) Reset() {
+	*x = Sketch{}
+	mi := &file_sketch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sketch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sketch) ProtoMessage() {}
+
+func (x *Sketch) ProtoReflect() protoreflect.Message {
+	mi := &file_sketch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sketch.ProtoReflect.Descriptor instead.
+func (*Sketch) Descriptor() ([]byte, []int) {
+	return file_sketch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sketch) GetWidth() uint64 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Sketch) GetDepth() uint64 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *Sketch) GetExp() float64 {
+	if x != nil {
+		return x.Exp
+	}
+	return 0
+}
+
+func (x *Sketch) GetRegisterBits() uint32 {
+	if x != nil {
+		return x.RegisterBits
+	}
+	return 0
+}
+
+func (x *Sketch) GetStore() []byte {
+	if x != nil {
+		return x.Store
+	}
+	return nil
+}
+
+func (x *Sketch) GetHash() uint32 {
+	if x != nil {
+		return x.Hash
+	}
+	return 0
+}
+
+func (x *Sketch) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *Sketch) GetTotal() uint64 {
+	if x != nil && x.Total != nil {
+		return *x.Total
+	}
+	return 0
+}
+
+func (x *Sketch) GetSeed() uint64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+func (x *Sketch) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Sketch) GetDoorkeeper() []uint64 {
+	if x != nil {
+		return x.Doorkeeper
+	}
+	return nil
+}
+
+var File_sketch_proto protoreflect.FileDescriptor
+
+const file_sketch_proto_rawDesc = "" +
+	"\n" +
+	"\fsketch.proto\x12\x03cml\"\x86\x03\n" +
+	"\x06Sketch\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x04R\x05width\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x04R\x05depth\x12\x10\n" +
+	"\x03exp\x18\x03 \x01(\x01R\x03exp\x12#\n" +
+	"\rregister_bits\x18\x04 \x01(\rR\fregisterBits\x12\x14\n" +
+	"\x05store\x18\x05 \x01(\fR\x05store\x12\x12\n" +
+	"\x04hash\x18\x06 \x01(\rR\x04hash\x12\x14\n" +
+	"\x05flags\x18\a \x01(\rR\x05flags\x12\x19\n" +
+	"\x05total\x18\b \x01(\x04H\x00R\x05total\x88\x01\x01\x12\x17\n" +
+	"\x04seed\x18\t \x01(\x04H\x01R\x04seed\x88\x01\x01\x125\n" +
+	"\bmetadata\x18\n" +
+	" \x03(\v2\x19.cml.Sketch.MetadataEntryR\bmetadata\x12\x1e\n" +
+	"\n" +
+	"doorkeeper\x18\v \x03(\x06R\n" +
+	"doorkeeper\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_totalB\a\n" +
+	"\x05_seedB*Z(github.com/seiflotfy/count-min-log/cmlpbb\x06proto3"
+
+var (
+	file_sketch_proto_rawDescOnce sync.Once
+	file_sketch_proto_rawDescData []byte
+)
+
+func file_sketch_proto_rawDescGZIP() []byte {
+	file_sketch_proto_rawDescOnce.Do(func() {
+		file_sketch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sketch_proto_rawDesc), len(file_sketch_proto_rawDesc)))
+	})
+	return file_sketch_proto_rawDescData
+}
+
+var file_sketch_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_sketch_proto_goTypes = []any{
+	(*Sketch)(nil), // 0: cml.Sketch
+	nil,            // 1: cml.Sketch.MetadataEntry
+}
+var file_sketch_proto_depIdxs = []int32{
+	1, // 0: cml.Sketch.metadata:type_name -> cml.Sketch.MetadataEntry
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_sketch_proto_init() }
+func file_sketch_proto_init() {
+	if File_sketch_proto != nil {
+		return
+	}
+	file_sketch_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sketch_proto_rawDesc), len(file_sketch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_sketch_proto_goTypes,
+		DependencyIndexes: file_sketch_proto_depIdxs,
+		MessageInfos:      file_sketch_proto_msgTypes,
+	}.Build()
+	File_sketch_proto = out.File
+	file_sketch_proto_goTypes = nil
+	file_sketch_proto_depIdxs = nil
+}
@@ -0,0 +1,126 @@
+/*
+Package cmltest is an accuracy harness for Count-Min-Log Sketches: it generates streams with
+known frequencies, feeds them to a sketch and reports the observed error. It is an ordinary
+package so the same evaluation can run in users' own CI against their chosen parameters.
+*/
+package cmltest
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+/*
+Stream is a sequence of key occurrences together with the true count of every key
+*/
+type Stream struct {
+	Events [][]byte
+	Counts map[string]uint64
+}
+
+/*
+GenerateZipf returns a stream of n occurrences of keys drawn from a Zipf distribution with
+exponent s > 1 over n distinct keys, reproducibly for a given seed
+*/
+func GenerateZipf(n int, s float64, seed uint64) Stream {
+	r := rand.New(rand.NewPCG(seed, seed))
+	z := rand.NewZipf(r, s, 1, uint64(max(n, 1)-1))
+	return generate(n, z.Uint64)
+}
+
+/*
+GenerateUniform returns a stream of n occurrences of keys drawn uniformly from n/16 distinct
+keys (at least one), reproducibly for a given seed
+*/
+func GenerateUniform(n int, seed uint64) Stream {
+	r := rand.New(rand.NewPCG(seed, seed))
+	keys := uint64(max(n/16, 1))
+	return generate(n, func() uint64 { return r.Uint64N(keys) })
+}
+
+func generate(n int, next func() uint64) Stream {
+	s := Stream{
+		Events: make([][]byte, n),
+		Counts: make(map[string]uint64),
+	}
+	for i := range s.Events {
+		key := strconv.FormatUint(next(), 10)
+		s.Events[i] = []byte(key)
+		s.Counts[key]++
+	}
+	return s
+}
+
+/*
+Report summarizes the error of a sketch's estimates over the distinct keys of a stream.
+Relative errors are |estimate-count|/count.
+*/
+type Report struct {
+	Keys           int
+	MeanRelError   float64
+	MedianRelError float64
+	P99RelError    float64
+	// Over, Under and Exact count the keys estimated above, below and at their count.
+	Over, Under, Exact int
+	// FillRatePct and SaturatedRegisters describe the sketch at the end of the run; they
+	// are zero for sketches that do not report Stats.
+	FillRatePct        float64
+	SaturatedRegisters uint64
+}
+
+/*
+Evaluate inserts every event of stream into sk and reports the error of its estimates
+*/
+func Evaluate(sk cml.Sketcher, stream Stream) Report {
+	for _, e := range stream.Events {
+		sk.Insert(e)
+	}
+
+	keys := make([]string, 0, len(stream.Counts))
+	for k := range stream.Counts {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	r := Report{Keys: len(keys)}
+	errs := make([]float64, 0, len(keys))
+	var sum float64
+	for _, k := range keys {
+		count := float64(stream.Counts[k])
+		est := sk.Estimate([]byte(k))
+		switch {
+		case est > count:
+			r.Over++
+		case est < count:
+			r.Under++
+		default:
+			r.Exact++
+		}
+		rel := math.Abs(est-count) / count
+		errs = append(errs, rel)
+		sum += rel
+	}
+	if len(errs) > 0 {
+		slices.Sort(errs)
+		r.MeanRelError = sum / float64(len(errs))
+		r.MedianRelError = quantile(errs, 0.5)
+		r.P99RelError = quantile(errs, 0.99)
+	}
+
+	if s, ok := sk.(interface{ Stats() cml.SketchStats }); ok {
+		stats := s.Stats()
+		r.FillRatePct = stats.FillRatePct
+		r.SaturatedRegisters = stats.SaturatedRegisters
+	}
+	return r
+}
+
+// quantile returns the q-quantile of sorted by the nearest-rank method.
+func quantile(sorted []float64, q float64) float64 {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
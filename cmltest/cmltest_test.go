@@ -0,0 +1,54 @@
+package cmltest
+
+import (
+	"reflect"
+	"testing"
+
+	cml "github.com/seiflotfy/count-min-log"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	for name, gen := range map[string]func(seed uint64) Stream{
+		"zipf":    func(seed uint64) Stream { return GenerateZipf(10000, 1.2, seed) },
+		"uniform": func(seed uint64) Stream { return GenerateUniform(10000, seed) },
+	} {
+		a, b, c := gen(1), gen(1), gen(2)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: expected the same stream for the same seed", name)
+		}
+		if reflect.DeepEqual(a, c) {
+			t.Errorf("%s: expected different streams for different seeds", name)
+		}
+		if len(a.Events) != 10000 {
+			t.Errorf("%s: expected 10000 events, got %d", name, len(a.Events))
+		}
+		var total uint64
+		for _, n := range a.Counts {
+			total += n
+		}
+		if total != 10000 {
+			t.Errorf("%s: expected counts to add up to 10000, got %d", name, total)
+		}
+	}
+}
+
+func TestEvaluateDeterministic(t *testing.T) {
+	stream := GenerateZipf(50000, 1.1, 42)
+	run := func() Report {
+		sk, _ := cml.NewSketch(1000, 4, 1.00026, cml.WithDeterministic(true))
+		return Evaluate(sk, stream)
+	}
+	a, b := run(), run()
+	if a != b {
+		t.Errorf("expected the same report for the same stream, got %+v and %+v", a, b)
+	}
+	if a.Keys != len(stream.Counts) || a.Over+a.Under+a.Exact != a.Keys {
+		t.Errorf("expected every key to be evaluated, got %+v", a)
+	}
+	if a.FillRatePct == 0 {
+		t.Error("expected the fill rate to be reported")
+	}
+	if a.MedianRelError > a.P99RelError || a.MedianRelError < 0 {
+		t.Errorf("expected ordered error percentiles, got %+v", a)
+	}
+}
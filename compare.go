@@ -0,0 +1,69 @@
+package cml
+
+import (
+	"cmp"
+	"math"
+)
+
+/*
+CompareFrequency returns -1, 0 or +1 if the estimated count of `a` is lower than, equal to
+or higher than that of `b`. Registers decode monotonically, so without a doorkeeper the raw
+registers are compared without decoding them.
+*/
+func (cml *Sketch) CompareFrequency(a, b []byte) int {
+	if len(cml.doorkeeper) != 0 {
+		return cmp.Compare(cml.Query(a), cml.Query(b))
+	}
+	return cmp.Compare(cml.keyRegister(a), cml.keyRegister(b))
+}
+
+/*
+Report describes how far two sketches compared by ApproxEqual are apart
+*/
+type Report struct {
+	// Mismatch is set when the sketches differ in width, depth or hashing and no cells were compared.
+	Mismatch error
+	// Cells is the number of registers compared.
+	Cells uint64
+	// Exceeding is the number of cells whose relative deviation exceeds the tolerance.
+	Exceeding uint64
+	// MaxRelDeviation is the largest relative deviation |a-b|/max(a,b) of any cell.
+	MaxRelDeviation float64
+}
+
+/*
+ApproxEqual reports whether a and b answer queries the same within relTol by comparing the
+decoded value of every register. Unlike a byte comparison this accepts sketches built through
+different paths, such as with different bases, as long as they share width, depth and hashing.
+*/
+func ApproxEqual(a, b *Sketch, relTol float64) (bool, Report) {
+	var r Report
+	switch {
+	case a.w != b.w:
+		r.Mismatch = &MismatchError{Field: "width", Ours: a.w, Theirs: b.w}
+	case a.d != b.d:
+		r.Mismatch = &MismatchError{Field: "depth", Ours: a.d, Theirs: b.d}
+	case a.hashing != b.hashing:
+		r.Mismatch = &MismatchError{Field: "hashing", Ours: a.hashing, Theirs: b.hashing}
+	}
+	if r.Mismatch != nil {
+		return false, r
+	}
+	for i := range a.d {
+		for j := range a.w {
+			va, vb := a.value(a.at(i, j)), b.value(b.at(i, j))
+			r.Cells++
+			if va == vb {
+				continue
+			}
+			dev := math.Abs(va-vb) / math.Max(va, vb)
+			if dev > r.MaxRelDeviation {
+				r.MaxRelDeviation = dev
+			}
+			if dev > relTol {
+				r.Exceeding++
+			}
+		}
+	}
+	return r.Exceeding == 0, r
+}
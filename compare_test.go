@@ -0,0 +1,86 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareFrequency(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 500; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%50))
+	}
+
+	for i := 0; i < 5000; i++ {
+		a := []byte(fmt.Sprint(i % 700))
+		b := []byte(fmt.Sprint((i * 7919) % 700))
+		qa, qb := sk.Query(a), sk.Query(b)
+		expected := 0
+		if qa < qb {
+			expected = -1
+		} else if qa > qb {
+			expected = 1
+		}
+		if got := sk.CompareFrequency(a, b); got != expected {
+			t.Errorf("expected %d comparing %s (%f) and %s (%f), got %d", expected, a, qa, b, qb, got)
+		}
+	}
+
+	if got := sk.CompareFrequency([]byte("unseen"), []byte("unseen too")); got != 0 {
+		t.Errorf("expected unseen keys to tie, got %d", got)
+	}
+
+	a, b := []byte("1"), []byte("2")
+	if allocs := testing.AllocsPerRun(100, func() { sk.CompareFrequency(a, b) }); allocs != 0 {
+		t.Errorf("expected no allocations, got %f", allocs)
+	}
+}
+
+func TestCompareFrequencyDoorkeeper(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<16))
+	once := []byte("once")
+	sk.Update(once)
+	if got := sk.CompareFrequency(once, []byte("unseen")); got != 1 {
+		t.Errorf("expected a key seen once to beat an unseen one, got %d", got)
+	}
+	twice := []byte("twice")
+	sk.Update(twice)
+	sk.Update(twice)
+	if got := sk.CompareFrequency(once, twice); got != -1 {
+		t.Errorf("expected a key seen once to trail one seen twice, got %d", got)
+	}
+}
+
+func TestApproxEqual(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 2000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%100+1000))
+	}
+
+	if ok, r := ApproxEqual(sk, sk.Clone(), 0); !ok || r.MaxRelDeviation != 0 || r.Cells != 4000 {
+		t.Errorf("expected identical sketches to be equal, got %+v", r)
+	}
+
+	// A step of the coarser base spans a relative error of about exp-1, plus the absolute
+	// rounding of one count.
+	rebased, _ := Rebase(sk, 1.05)
+	if ok, r := ApproxEqual(sk, rebased, 0.05+1e-3); !ok {
+		t.Errorf("expected the rebased sketch to be equal within its quantization, got %+v", r)
+	} else if r.MaxRelDeviation == 0 {
+		t.Error("expected the rebased sketch to deviate")
+	}
+
+	other := sk.Clone()
+	for i := 0; i < 2000; i++ {
+		other.BulkUpdate([]byte(fmt.Sprint("other", i)), 5000)
+	}
+	ok, r := ApproxEqual(sk, other, 0.05)
+	if ok || r.Exceeding == 0 || r.MaxRelDeviation <= 0.05 || r.Mismatch != nil {
+		t.Errorf("expected a different sketch to fail with a report, got %+v", r)
+	}
+
+	narrow, _ := NewSketch(500, 4, 1.00026)
+	if ok, r := ApproxEqual(sk, narrow, 1); ok || r.Mismatch == nil {
+		t.Errorf("expected a dimension mismatch, got %+v", r)
+	}
+}
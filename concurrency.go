@@ -0,0 +1,46 @@
+package cml
+
+import "sync/atomic"
+
+// writing marks the in-use counter while an update runs; readers count up
+// from zero.
+const writing = -1
+
+/*
+WithConcurrencyChecks makes Update, BulkUpdate and Query panic when they detect an update
+overlapping another call on the same sketch, like the runtime does for concurrent map writes.
+A Sketch is not safe for concurrent use; the checks help find where it is shared by mistake.
+They cost an atomic operation per call, so they are meant for development and tests.
+*/
+func WithConcurrencyChecks(on bool) Option {
+	return func(cml *Sketch) error {
+		cml.checks = on
+		return nil
+	}
+}
+
+func (cml *Sketch) beginWrite() {
+	if !atomic.CompareAndSwapInt32(&cml.inUse, 0, writing) {
+		panic("cml: concurrent update of a Sketch; use a SafeSketch, Limiter or AsyncWriter")
+	}
+}
+
+func (cml *Sketch) endWrite() {
+	atomic.StoreInt32(&cml.inUse, 0)
+}
+
+func (cml *Sketch) beginRead() {
+	for {
+		n := atomic.LoadInt32(&cml.inUse)
+		if n == writing {
+			panic("cml: Sketch queried during an update; use a SafeSketch, Limiter or AsyncWriter")
+		}
+		if atomic.CompareAndSwapInt32(&cml.inUse, n, n+1) {
+			return
+		}
+	}
+}
+
+func (cml *Sketch) endRead() {
+	atomic.AddInt32(&cml.inUse, -1)
+}
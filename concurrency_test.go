@@ -0,0 +1,74 @@
+package cml
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrencyChecks(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithConcurrencyChecks(true))
+	panics := make(chan any, 2)
+	var (
+		wg   sync.WaitGroup
+		done atomic.Bool
+	)
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			defer func() {
+				done.Store(true)
+				panics <- recover()
+			}()
+			// Long updates leave room for the scheduler to interleave the
+			// reader even on a single CPU.
+			for i := 0; i < 100 && !done.Load(); i++ {
+				if g == 0 {
+					sk.BulkUpdate([]byte("a"), 1<<20)
+					continue
+				}
+				for j := 0; j < 100000 && !done.Load(); j++ {
+					sk.Query([]byte("a"))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(panics)
+
+	var caught bool
+	for p := range panics {
+		if p != nil {
+			caught = true
+		}
+	}
+	if !caught {
+		t.Error("expected overlapping calls to panic")
+	}
+}
+
+func TestConcurrencyChecksSequential(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithConcurrencyChecks(true))
+	for i := 0; i < 1000; i++ {
+		sk.Update([]byte(fmt.Sprint(i)))
+		sk.Query([]byte(fmt.Sprint(i)))
+		sk.BulkUpdateSaturating([]byte(fmt.Sprint(i)), 2)
+	}
+	if sk.inUse != 0 {
+		t.Errorf("expected the sketch to be idle, got %d", sk.inUse)
+	}
+}
+
+func benchmarkChecks(b *testing.B, on bool) {
+	sk, _ := NewSketch(100000, 4, 1.00026, WithConcurrencyChecks(on))
+	key := []byte("key")
+	for i := 0; i < b.N; i++ {
+		sk.Update(key)
+		sk.Query(key)
+	}
+}
+
+func BenchmarkConcurrencyChecksOff(b *testing.B) { benchmarkChecks(b, false) }
+func BenchmarkConcurrencyChecksOn(b *testing.B)  { benchmarkChecks(b, true) }
@@ -0,0 +1,52 @@
+package cml
+
+/*
+RowTrace is what one row of the sketch said about a key
+*/
+type RowTrace struct {
+	Row      int
+	Column   uint
+	Register uint16
+	Value    float64
+}
+
+/*
+QueryTrace explains how Query arrived at a key's estimate
+*/
+type QueryTrace struct {
+	Rows []RowTrace
+	Min  uint16
+	// Doorkept is set when the doorkeeper holds an occurrence of the key, which the estimate adds.
+	Doorkept bool
+	// Refused is the key validation error when the sketch refuses the key; Rows is then empty.
+	Refused  error
+	Estimate float64
+}
+
+/*
+DebugQuery returns the registers `e` maps to in every row, probed exactly like Query does.
+The estimate is Query's, including the doorkeeper's occurrence, and 0 for refused keys.
+*/
+func (cml *Sketch) DebugQuery(e []byte) QueryTrace {
+	if err := cml.CheckKey(e); err != nil {
+		return QueryTrace{Refused: err}
+	}
+	h := cml.hash(e)
+	trace := QueryTrace{
+		Rows:     make([]RowTrace, cml.d),
+		Min:      cml.minRegister(h),
+		Doorkept: cml.doorkept(h) != 0,
+	}
+	for i := range int(cml.d) {
+		col := cml.column(h, i)
+		c := cml.at(uint(i), col)
+		trace.Rows[i] = RowTrace{
+			Row:      i,
+			Column:   col,
+			Register: c,
+			Value:    cml.value(c),
+		}
+	}
+	trace.Estimate = cml.estimate(h)
+	return trace
+}
@@ -0,0 +1,65 @@
+package cml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDebugQuery(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	for i := 0; i < 1000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%10))
+	}
+
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprint(i))
+		trace := sk.DebugQuery(key)
+		if trace.Estimate != sk.Query(key) {
+			t.Errorf("expected estimate %f, got %f", sk.Query(key), trace.Estimate)
+		}
+		if len(trace.Rows) != 4 {
+			t.Fatalf("expected 4 rows, got %d", len(trace.Rows))
+		}
+		lowest := uint16(math.MaxUint16)
+		for _, row := range trace.Rows {
+			if row.Column >= sk.w {
+				t.Errorf("expected column below %d, got %d", sk.w, row.Column)
+			}
+			if row.Register != sk.store[row.Row][row.Column] {
+				t.Errorf("expected register %d, got %d", sk.store[row.Row][row.Column], row.Register)
+			}
+			if row.Register < lowest {
+				lowest = row.Register
+			}
+		}
+		if trace.Min != lowest {
+			t.Errorf("expected min %d, got %d", lowest, trace.Min)
+		}
+	}
+
+	key := []byte("saturated")
+	trace := sk.DebugQuery(key)
+	sk.store[2][trace.Rows[2].Column] = math.MaxUint16
+	if trace = sk.DebugQuery(key); trace.Rows[2].Register != math.MaxUint16 {
+		t.Errorf("expected saturated register in row 2, got %d", trace.Rows[2].Register)
+	}
+}
+
+func TestDebugQueryDoorkeeperAndRefused(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026, WithDoorkeeper(1<<16), WithRejectEmptyKeys())
+	key := []byte("once")
+	sk.Update(key)
+	if trace := sk.DebugQuery(key); !trace.Doorkept || trace.Estimate != sk.Query(key) || trace.Estimate != 1 {
+		t.Errorf("expected a doorkept estimate of %f, got %+v", sk.Query(key), trace)
+	}
+	if trace := sk.DebugQuery([]byte("unseen")); trace.Doorkept || trace.Estimate != 0 {
+		t.Errorf("expected no doorkept occurrence for an unseen key, got %+v", trace)
+	}
+
+	trace := sk.DebugQuery(nil)
+	if !errors.Is(trace.Refused, ErrEmptyKey) || trace.Estimate != 0 || len(trace.Rows) != 0 {
+		t.Errorf("expected a refused empty key, got %+v", trace)
+	}
+}
@@ -0,0 +1,51 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNewForCapacityAndDepth(t *testing.T) {
+	auto, _ := NewForCapacity16(1000000, 0.05)
+	k, err := OptimalDepth(1000000, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auto.d != k {
+		t.Errorf("expected optimal depth %d, got %d", auto.d, k)
+	}
+
+	sketches := map[uint]*Sketch{k: auto}
+	for _, d := range []uint{1, 4} {
+		sk, err := NewForCapacityAndDepth(1000000, 0.05, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sk.d != d {
+			t.Errorf("expected depth %d, got %d", d, sk.d)
+		}
+		if total, expected := float64(sk.w*sk.d), float64(auto.w*auto.d); math.Abs(total-expected) > float64(d+auto.d) {
+			t.Errorf("d=%d: expected ~%f registers, got %f", d, expected, total)
+		}
+		sketches[d] = sk
+	}
+
+	errs := make(map[uint]float64)
+	for d, sk := range sketches {
+		for i := 0; i < 2000000; i++ {
+			sk.Update([]byte(fmt.Sprint(i)))
+		}
+		for i := 0; i < 10000; i++ {
+			errs[d] += sk.Query([]byte(fmt.Sprint(i))) - 1
+		}
+	}
+	t.Logf("total over-estimation by depth: %v", errs)
+	if errs[4] >= errs[1] || errs[k] >= errs[1] {
+		t.Errorf("expected more rows to reduce the error, got %v", errs)
+	}
+
+	if _, err := NewForCapacityAndDepth(1000000, 0.05, 0); err == nil {
+		t.Error("expected error for d=0")
+	}
+}
@@ -0,0 +1,65 @@
+package cml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDeterministic(t *testing.T) {
+	// Differently seeded, so the sketches' generators are in different states.
+	a, _ := NewSketch(10000, 4, 1.00026, WithDeterministic(true), WithSeed(1))
+	b, _ := NewSketch(10000, 4, 1.00026, WithDeterministic(true), WithSeed(2))
+	for _, sk := range []*Sketch{a, b} {
+		for i := 0; i < 20000; i++ {
+			sk.BulkUpdate([]byte(fmt.Sprint(i%3000)), uint(i%7+1))
+		}
+	}
+	da, _ := a.MarshalBinary()
+	db, _ := b.MarshalBinary()
+	if !bytes.Equal(da, db) {
+		t.Error("expected sketches fed the same stream to be byte-identical")
+	}
+
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(da); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.deterministic {
+		t.Error("expected the mode to survive a round trip")
+	}
+	if other, _ := FromStructured(a.MarshalStructured()); other == nil || !other.deterministic {
+		t.Error("expected the mode to survive a structured round trip")
+	}
+
+	random, _ := NewSketch(10000, 4, 1.00026)
+	if err := a.Merge(random); err == nil {
+		t.Error("expected merging deterministic and random sketches to fail")
+	}
+	if _, err := a.MergeFrom(bytes.NewReader(db)); err != nil {
+		t.Errorf("expected deterministic sketches to merge, got %v", err)
+	}
+	rd, _ := random.MarshalBinary()
+	if _, err := a.MergeFrom(bytes.NewReader(rd)); err == nil {
+		t.Error("expected streaming a random sketch into a deterministic one to fail")
+	}
+}
+
+func TestDeterministicAccuracy(t *testing.T) {
+	meanError := func(sk *Sketch) float64 {
+		feedZipf(sk, 10000, 100000, 1.0)
+		var sum float64
+		for r := 1; r <= 1000; r++ {
+			want := math.Round(100000 / float64(r))
+			sum += math.Abs(sk.Query([]byte(fmt.Sprintf("zipf-%d", r)))-want) / want
+		}
+		return sum / 1000
+	}
+	det, _ := NewSketch(100000, 4, 1.00026, WithDeterministic(true))
+	random, _ := NewSketch(100000, 4, 1.00026)
+	d, r := meanError(det), meanError(random)
+	if d > 0.02 || d > 2*r+0.005 {
+		t.Errorf("expected deterministic mean relative error comparable to %f, got %f", r, d)
+	}
+}
@@ -0,0 +1,114 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// maxDoorkeeperWords is the largest doorkeeper the encoding's 3-byte word count describes.
+const maxDoorkeeperWords = 1<<24 - 1
+
+// doorkeeperProbes is the number of bits the doorkeeper sets per key.
+const doorkeeperProbes = 3
+
+/*
+WithDoorkeeper puts a Bloom filter of the given number of bits, rounded up to a multiple of
+64, in front of the sketch. A key's first occurrence only enters the filter and the registers
+count from the second one on, so keys seen once, often most of them, no longer fill the
+sketch. Query adds the occurrence back for keys the filter holds, which overestimates a key
+the filter wrongly holds by one. Reset and aging clear the filter; it is encoded with the
+sketch, and sketches merge only with sketches whose filter has the same size.
+*/
+func WithDoorkeeper(bits uint) Option {
+	return func(cml *Sketch) error {
+		if bits == 0 || bits > 64*maxDoorkeeperWords {
+			return errors.New("doorkeeper bits need to be between 1 and 2^30-64")
+		}
+		cml.doorkeeper = make([]uint64, (bits+63)/64)
+		return nil
+	}
+}
+
+// doorkeeperBits returns the positions of the hashed key's bits in a doorkeeper of n bits.
+func doorkeeperBits(h keyHash, n uint64) [doorkeeperProbes]uint64 {
+	a := fmix64(h.lo ^ bits.RotateLeft64(h.hi, 32) ^ 0x5bd1e9955bd1e995)
+	b := fmix64(a) | 1
+	var pos [doorkeeperProbes]uint64
+	for i := range pos {
+		pos[i] = (a + uint64(i)*b) % n
+	}
+	return pos
+}
+
+// doorkeep enters the hashed key into the doorkeeper and reports whether it was
+// new to it, in which case the doorkeeper takes the occurrence.
+func (cml *Sketch) doorkeep(h keyHash) bool {
+	if len(cml.doorkeeper) == 0 {
+		return false
+	}
+	added := false
+	for _, p := range doorkeeperBits(h, 64*uint64(len(cml.doorkeeper))) {
+		if w, bit := &cml.doorkeeper[p/64], uint64(1)<<(p%64); *w&bit == 0 {
+			*w |= bit
+			added = true
+		}
+	}
+	return added
+}
+
+// forgetDoorkept clears the hashed key's bits in the doorkeeper, dropping the
+// occurrence it holds for the key and for any key sharing one of the bits.
+func (cml *Sketch) forgetDoorkept(h keyHash) {
+	if len(cml.doorkeeper) == 0 {
+		return
+	}
+	for _, p := range doorkeeperBits(h, 64*uint64(len(cml.doorkeeper))) {
+		cml.doorkeeper[p/64] &^= 1 << (p % 64)
+	}
+}
+
+// doorkept returns the occurrence the doorkeeper holds for the hashed key, 0 or 1.
+func (cml *Sketch) doorkept(h keyHash) float64 {
+	if len(cml.doorkeeper) == 0 {
+		return 0
+	}
+	for _, p := range doorkeeperBits(h, 64*uint64(len(cml.doorkeeper))) {
+		if cml.doorkeeper[p/64]&(1<<(p%64)) == 0 {
+			return 0
+		}
+	}
+	return 1
+}
+
+// estimate returns the estimated count of the hashed key.
+func (cml *Sketch) estimate(h keyHash) float64 {
+	return cml.value(cml.minRegister(h)) + cml.doorkept(h)
+}
+
+// doorkeeperHeader returns the number of doorkeeper words an encoding's header
+// declares, checking the size field is only set along with flagDoorkeeper.
+func doorkeeperHeader(hdr []byte) (int, error) {
+	n := int(hdr[5]) | int(hdr[6])<<8 | int(hdr[7])<<16
+	if (hdr[2]&flagDoorkeeper != 0) != (n > 0) {
+		return 0, errors.New("sketch doorkeeper size does not match its flag")
+	}
+	return n, nil
+}
+
+// decodeDoorkeeper decodes the doorkeeper block the header hdr declares at the
+// start of b and returns it and the rest of b.
+func decodeDoorkeeper(hdr, b []byte, order binary.ByteOrder) ([]uint64, []byte, error) {
+	n, err := doorkeeperHeader(hdr)
+	if err != nil || n == 0 {
+		return nil, b, err
+	}
+	if len(b)/8 < n {
+		return nil, nil, errors.New("sketch doorkeeper truncated")
+	}
+	dk := make([]uint64, n)
+	for i := range dk {
+		dk[i] = order.Uint64(b[8*i:])
+	}
+	return dk, b[8*n:], nil
+}
@@ -0,0 +1,112 @@
+package cml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDoorkeeper(t *testing.T) {
+	plain, _ := NewSketch(1000, 4, 1.00026)
+	sk, err := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sk.Stats().DoorkeeperBytes; got != 1<<13 {
+		t.Errorf("expected 8192 doorkeeper bytes, got %d", got)
+	}
+
+	// 3000 keys seen once and 50 keys seen 100 times.
+	for i := 0; i < 3000; i++ {
+		key := []byte(fmt.Sprint("once", i))
+		plain.Update(key)
+		sk.Update(key)
+	}
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprint("often", i))
+		for j := 0; j < 100; j++ {
+			plain.Update(key)
+			sk.Update(key)
+		}
+	}
+
+	if fill, plainFill := sk.Stats().FillRatePct, plain.Stats().FillRatePct; fill > 10 || plainFill < 50 {
+		t.Errorf("expected one-hit keys to stay out of the registers, got fill %f%% against %f%%", fill, plainFill)
+	}
+	for i := 0; i < 50; i++ {
+		if got := sk.Query([]byte(fmt.Sprint("often", i))); got < 90 || got > 111 {
+			t.Errorf("expected about 100, got %f", got)
+		}
+	}
+	if got := sk.Query([]byte("once7")); got < 1 {
+		t.Errorf("expected a one-hit key to count 1, got %f", got)
+	}
+	if got := sk.Query([]byte("never seen")); got != 0 {
+		t.Errorf("expected 0 for an unseen key, got %f", got)
+	}
+
+	sk.Reset()
+	if got := sk.Query([]byte("once7")); got != 0 {
+		t.Errorf("expected Reset to clear the doorkeeper, got %f", got)
+	}
+
+	if _, err := NewSketch(100, 2, 1.00026, WithDoorkeeper(0)); err == nil {
+		t.Error("expected error for a doorkeeper of 0 bits")
+	}
+}
+
+func TestDoorkeeperEncoding(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026, WithDoorkeeper(1000))
+	sk.SetMetadata("tenant", "acme")
+	for i := 0; i < 200; i++ {
+		sk.Update([]byte(fmt.Sprint(i % 150)))
+	}
+
+	data, _ := sk.MarshalBinary()
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 150; i++ {
+		key := []byte(fmt.Sprint(i))
+		if a, b := sk.Query(key), restored.Query(key); a != b {
+			t.Errorf("expected %f after a round trip, got %f", a, b)
+		}
+	}
+	if again, _ := restored.MarshalBinary(); !bytes.Equal(again, data) {
+		t.Error("expected the doorkeeper to round-trip")
+	}
+
+	like, err := NewLikeSerialized(data[:headerSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := like.MergeFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got := like.Query([]byte("149")); got != 1 {
+		t.Errorf("expected MergeFrom to merge the doorkeeper, got %f", got)
+	}
+
+	plain, _ := NewSketch(100, 3, 1.00026)
+	if err := plain.Merge(sk); err == nil {
+		t.Error("expected sketches with different doorkeepers not to merge")
+	}
+	if _, err := plain.MergeFrom(bytes.NewReader(data)); err == nil {
+		t.Error("expected MergeFrom to reject a different doorkeeper")
+	}
+	if err := restored.UnmarshalBinary(data[:len(data)-600-1]); err == nil {
+		t.Error("expected error for a truncated doorkeeper")
+	}
+}
+
+func TestDoorkeeperAging(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<12), WithSampleSize(100))
+	sk.Update([]byte("once"))
+	for i := 0; i < 200; i++ {
+		sk.Update([]byte("hot"))
+	}
+	if got := sk.Query([]byte("once")); got != 0 {
+		t.Errorf("expected aging to clear the doorkeeper, got %f", got)
+	}
+}
@@ -0,0 +1,48 @@
+package cml
+
+import (
+	"math"
+	"sort"
+)
+
+// maxEntropyFill is the fraction of non-zero registers above which collisions
+// make the entropy estimate meaningless.
+const maxEntropyFill = 0.9
+
+/*
+EstimateEntropy estimates the Shannon entropy, in bits, of the key distribution of the stream.
+
+Each row's decoded registers are normalized by the row's total (the L1 estimate) and
+the entropy they imply is corrected for the keys that collided in the same register,
+assuming the colliding keys share its value evenly. The median over rows is returned.
+ok is false for an empty sketch or when more than 90% of the registers are set.
+*/
+func (cml *Sketch) EstimateEntropy() (bits float64, ok bool) {
+	if !cml.initialized() {
+		return 0, false
+	}
+	estimates := make([]float64, 0, cml.d)
+	for _, row := range cml.logicalRows() {
+		var total, sum, occupied float64
+		for _, c := range row {
+			if c == 0 {
+				continue
+			}
+			v := cml.value(c)
+			total += v
+			sum += v * math.Log2(v)
+			occupied++
+		}
+		fill := occupied / float64(cml.w)
+		if total == 0 || fill > maxEntropyFill {
+			return 0, false
+		}
+		// Expected number of keys sharing an occupied register, derived from
+		// the linear counting estimate of the number of distinct keys.
+		load := -math.Log1p(-fill)
+		perRegister := load / fill
+		estimates = append(estimates, math.Log2(total)-sum/total+math.Log2(perRegister))
+	}
+	sort.Float64s(estimates)
+	return estimates[len(estimates)/2], true
+}
@@ -0,0 +1,56 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		name  string
+		w     uint
+		feed  func(sk *Sketch)
+		bits  float64
+		delta float64
+	}{
+		{"uniform", 100000, func(sk *Sketch) {
+			for i := 0; i < 1000; i++ {
+				sk.BulkUpdate([]byte(fmt.Sprint(i)), 100)
+			}
+		}, math.Log2(1000), 0.1},
+		{"uniform with collisions", 1500, func(sk *Sketch) {
+			for i := 0; i < 1000; i++ {
+				sk.BulkUpdate([]byte(fmt.Sprint(i)), 100)
+			}
+		}, math.Log2(1000), 0.3},
+		{"dominant key", 100000, func(sk *Sketch) {
+			sk.BulkUpdate([]byte("dominant"), 100000)
+		}, 0, 0.01},
+	}
+	for _, tt := range tests {
+		sk, _ := NewSketch(tt.w, 4, 1.00026)
+		tt.feed(sk)
+		bits, ok := sk.EstimateEntropy()
+		if !ok {
+			t.Errorf("%s: expected estimate", tt.name)
+			continue
+		}
+		if math.Abs(bits-tt.bits) > tt.delta {
+			t.Errorf("%s: expected %f bits, got %f", tt.name, tt.bits, bits)
+		}
+	}
+}
+
+func TestEstimateEntropyUnreliable(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	if _, ok := sk.EstimateEntropy(); ok {
+		t.Error("expected empty sketch to be rejected")
+	}
+	for i := 0; i < 10000; i++ {
+		sk.Update([]byte(fmt.Sprint(i)))
+	}
+	if _, ok := sk.EstimateEntropy(); ok {
+		t.Error("expected full sketch to be rejected")
+	}
+}
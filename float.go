@@ -0,0 +1,207 @@
+package cml
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// floatRegisterBits marks the encoding of a FloatSketch in the fourth header
+// byte, which Sketch encodings leave zero.
+const floatRegisterBits = 32
+
+var (
+	_ encoding.BinaryMarshaler   = (*FloatSketch)(nil)
+	_ encoding.BinaryUnmarshaler = (*FloatSketch)(nil)
+)
+
+/*
+FloatSketch is an experimental Count-Min-Log Sketch with float32 registers. A register holds the
+logarithm of its count exactly rather than rounded to a whole step, and updates raise it by the
+fraction the increments are worth, deterministically. This removes most of the quantization
+error of coarse exps at twice the memory of a Sketch.
+
+The float32 registers bound the counts single increments reach: an increment of one is rounded
+ever more coarsely as a count grows and is lost once it falls below half a register's precision,
+at a count of about 1.7 million at exp 1.08 and 4 million at exp 1.00026. Larger counts need
+larger increments; BulkUpdate reports an update it loses this way.
+*/
+type FloatSketch struct {
+	w      uint
+	d      uint
+	exp    float64
+	logExp float64
+
+	store   [][]float32
+	hashing hashing
+}
+
+/*
+NewFloatSketch returns a new Count-Min-Log Sketch with float32 registers
+*/
+func NewFloatSketch(w uint, d uint, exp float64) (*FloatSketch, error) {
+	if w == 0 || d == 0 {
+		return nil, errors.New("w and d must be non-zero")
+	}
+	if !(exp > 1) || math.IsInf(exp, 1) {
+		return nil, errors.New("exp needs to be > 1")
+	}
+	registers := make([]float32, w*d)
+	store := make([][]float32, d)
+	for i := range store {
+		store[i] = registers[uint(i)*w : uint(i+1)*w : uint(i+1)*w]
+	}
+	return &FloatSketch{
+		w:      w,
+		d:      d,
+		exp:    exp,
+		logExp: math.Log1p(exp - 1),
+		store:  store,
+	}, nil
+}
+
+/*
+Update increases the count of `e` by one
+*/
+func (fs *FloatSketch) Update(e []byte) bool {
+	return fs.BulkUpdate(e, 1)
+}
+
+/*
+BulkUpdate increases the count of `e` by freq, raising the smallest of its registers to the
+register worth freq more and every other register below that to the same. It returns false
+if freq is too small for the registers to tell apart from the current count.
+*/
+func (fs *FloatSketch) BulkUpdate(e []byte, freq uint) bool {
+	if len(fs.store) == 0 {
+		return false
+	}
+	h := fs.hashing.hash(e)
+	m := fs.minRegister(h)
+	c := fs.register(fs.value(m) + float64(freq))
+	if c == m && freq > 0 {
+		return false
+	}
+	for i, row := range fs.store {
+		if j := fs.hashing.column(h, i, fs.w); row[j] < c {
+			row[j] = c
+		}
+	}
+	return true
+}
+
+/*
+Query returns the count of `e`
+*/
+func (fs *FloatSketch) Query(e []byte) float64 {
+	if len(fs.store) == 0 {
+		return 0
+	}
+	return fs.value(fs.minRegister(fs.hashing.hash(e)))
+}
+
+func (fs *FloatSketch) minRegister(h keyHash) float32 {
+	c := float32(math.MaxFloat32)
+	for i, row := range fs.store {
+		if r := row[fs.hashing.column(h, i, fs.w)]; r < c {
+			c = r
+		}
+	}
+	return c
+}
+
+// value is Sketch.value for a fractional register.
+func (fs *FloatSketch) value(c float32) float64 {
+	return math.Expm1(float64(c)*fs.logExp) / (fs.exp - 1)
+}
+
+// register inverts value.
+func (fs *FloatSketch) register(v float64) float32 {
+	return float32(math.Log1p(v*(fs.exp-1)) / fs.logExp)
+}
+
+/*
+Merge combines other into the sketch by taking the element-wise maximum of the registers
+*/
+func (fs *FloatSketch) Merge(other *FloatSketch) error {
+	if fs.w != other.w {
+		return &MismatchError{Field: "width", Ours: fs.w, Theirs: other.w}
+	}
+	if fs.d != other.d {
+		return &MismatchError{Field: "depth", Ours: fs.d, Theirs: other.d}
+	}
+	if !sameExp(fs.exp, other.exp) {
+		return &MismatchError{Field: "exp", Ours: fs.exp, Theirs: other.exp}
+	}
+	for i, row := range other.store {
+		for j, c := range row {
+			fs.store[i][j] = max(fs.store[i][j], c)
+		}
+	}
+	return nil
+}
+
+/*
+MarshalBinary encodes the sketch's parameters and registers.
+
+The encoding is the 32-byte header of a Sketch with 32 as its fourth byte, followed by the
+registers row by row as float32 bits, all little-endian.
+*/
+func (fs *FloatSketch) MarshalBinary() ([]byte, error) {
+	b := make([]byte, headerSize, headerSize+4*fs.w*fs.d)
+	b[0] = encodingVersion
+	b[1] = byte(fs.hashing)
+	b[3] = floatRegisterBits
+	binary.LittleEndian.PutUint64(b[8:], uint64(fs.w))
+	binary.LittleEndian.PutUint64(b[16:], uint64(fs.d))
+	binary.LittleEndian.PutUint64(b[24:], math.Float64bits(fs.exp))
+	for _, row := range fs.store {
+		for _, c := range row {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(c))
+		}
+	}
+	return b, nil
+}
+
+/*
+UnmarshalBinary restores a sketch encoded by MarshalBinary, replacing its parameters and registers
+*/
+func (fs *FloatSketch) UnmarshalBinary(b []byte) error {
+	if len(b) < headerSize {
+		return errors.New("sketch data too short")
+	}
+	if b[0] != encodingVersion {
+		return errors.New("unsupported sketch encoding version")
+	}
+	if b[3] != floatRegisterBits {
+		return errors.New("not a float sketch encoding")
+	}
+	if b[1] != byte(hashFarm64) || b[2] != 0 {
+		return errors.New("unsupported float sketch hashing or flags")
+	}
+	w, d := binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[16:])
+	if w == 0 || d == 0 {
+		return errors.New("sketch dimensions must be non-zero")
+	}
+	if n := uint64(len(b) - headerSize); n%4 != 0 || w > n/4/d || w*d != n/4 {
+		return errors.New("sketch data size does not match its dimensions")
+	}
+	decoded, err := NewFloatSketch(uint(w), uint(d), math.Float64frombits(binary.LittleEndian.Uint64(b[24:])))
+	if err != nil {
+		return err
+	}
+	data := b[headerSize:]
+	for _, row := range decoded.store {
+		for j := range row {
+			c := math.Float32frombits(binary.LittleEndian.Uint32(data))
+			if !(c >= 0) || math.IsInf(float64(c), 1) {
+				return errors.New("invalid float sketch register")
+			}
+			row[j] = c
+			data = data[4:]
+		}
+	}
+	*fs = *decoded
+	return nil
+}
@@ -0,0 +1,98 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFloatSketchAccuracy(t *testing.T) {
+	// Equal memory: float32 registers take twice the bytes of a Sketch's.
+	fs, _ := NewFloatSketch(10000, 4, 1.08)
+	sk, _ := NewSketch(20000, 4, 1.08)
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprint(i))
+		for j := 0; j < 50+i%450; j++ {
+			fs.Update(key)
+			sk.Update(key)
+		}
+	}
+
+	var floatErr, intErr float64
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprint(i))
+		want := float64(50 + i%450)
+		floatErr += math.Abs(fs.Query(key)-want) / want
+		intErr += math.Abs(sk.Query(key)-want) / want
+	}
+	floatErr /= 1000
+	intErr /= 1000
+	if floatErr > 0.01 || floatErr > intErr/4 {
+		t.Errorf("expected float registers to be markedly more accurate than %f, got %f", intErr, floatErr)
+	}
+}
+
+func TestFloatSketchMergeAndMarshal(t *testing.T) {
+	a, _ := NewFloatSketch(1000, 4, 1.08)
+	b, _ := NewFloatSketch(1000, 4, 1.08)
+	a.BulkUpdate([]byte("a"), 300)
+	b.BulkUpdate([]byte("b"), 200)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Query([]byte("b")); math.Abs(got-200) > 0.01 {
+		t.Errorf("expected 200 after merge, got %v", got)
+	}
+	other, _ := NewFloatSketch(1000, 3, 1.08)
+	if err := a.Merge(other); err == nil {
+		t.Error("expected error for different dimensions")
+	}
+
+	data, _ := a.MarshalBinary()
+	restored := &FloatSketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Query([]byte("a")) != a.Query([]byte("a")) {
+		t.Error("expected the same estimate after a round trip")
+	}
+	if err := (&Sketch{}).UnmarshalBinary(data); err == nil {
+		t.Error("expected a Sketch to reject a FloatSketch encoding")
+	}
+	sk, _ := NewSketch(1000, 4, 1.08)
+	data, _ = sk.MarshalBinary()
+	if err := restored.UnmarshalBinary(data); err == nil {
+		t.Error("expected a FloatSketch to reject a Sketch encoding")
+	}
+	if (&FloatSketch{}).Query([]byte("a")) != 0 || (&FloatSketch{}).Update([]byte("a")) {
+		t.Error("expected a zero FloatSketch to be empty")
+	}
+}
+
+func TestFloatSketchFineExp(t *testing.T) {
+	fs, _ := NewFloatSketch(1000, 4, 1.00026)
+	key := []byte("key")
+	for i := 0; i < 100000; i++ {
+		fs.Update(key)
+	}
+	if got := fs.Query(key); math.Abs(got-100000) > 100 {
+		t.Errorf("expected 100000, got %f", got)
+	}
+
+	// At 5 million an increment of one is below the float32 registers' precision.
+	hot := []byte("hot")
+	fs.BulkUpdate(hot, 5000000)
+	before := fs.Query(hot)
+	if fs.Update(hot) {
+		t.Error("expected Update to report an increment the registers cannot hold")
+	}
+	if got := fs.Query(hot); got != before {
+		t.Errorf("expected a lost update to leave %f, got %f", before, got)
+	}
+	if !fs.BulkUpdate(hot, 100000) {
+		t.Error("expected a larger increment to be held")
+	}
+	if got := fs.Query(hot); math.Abs(got-5100000) > 5100 {
+		t.Errorf("expected 5100000, got %f", got)
+	}
+}
@@ -0,0 +1,58 @@
+package cml
+
+import "math"
+
+/*
+ForgetKey lowers every register probed for `e` to at most floor, usually 0, and clears the
+key's doorkeeper bits, so the key's estimate drops to the count floor stands for. A sketch
+cannot tell keys apart, so this also lowers the estimates of keys sharing any of those
+registers or bits; estimates only ever go down.
+
+Forgetting is not written to the write-ahead log, and merging in a sketch that still counts
+the key brings its estimate back.
+*/
+func (cml *Sketch) ForgetKey(e []byte, floor uint16) {
+	if cml.writable() != nil || cml.CheckKey(e) != nil {
+		return
+	}
+	if cml.checks {
+		cml.beginWrite()
+		defer cml.endWrite()
+	}
+	h := cml.hash(e)
+	for i := range cml.d {
+		if col := cml.column(h, int(i)); cml.at(i, col) > floor {
+			r := cml.cell(i, col)
+			cml.track(*r, floor)
+			*r = floor
+		}
+	}
+	cml.forgetDoorkept(h)
+}
+
+/*
+Trim zeroes every register whose count is below minValue and returns how many it cleared.
+Keys counted only in such registers then estimate 0, an under-estimation that suits archives
+which only care about heavy keys. Runs of zeroes compress well, so trimming before compressing
+an encoded sketch shrinks it considerably.
+*/
+func (cml *Sketch) Trim(minValue float64) uint64 {
+	if cml.readOnly {
+		return 0
+	}
+	floor, ok := cml.register(minValue)
+	if !ok {
+		floor = math.MaxUint16
+	}
+	var cleared uint64
+	for _, row := range cml.store {
+		for j, c := range row {
+			if c != 0 && c < floor {
+				row[j] = 0
+				cleared++
+			}
+		}
+	}
+	cml.occupied -= cleared
+	return cleared
+}
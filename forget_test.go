@@ -0,0 +1,129 @@
+package cml
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"testing"
+)
+
+func TestForgetKey(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 2000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%20+1))
+	}
+	heavy := []byte("heavy")
+	sk.BulkUpdate(heavy, 100000)
+	before := make([]float64, 2000)
+	for i := range before {
+		before[i] = sk.Query([]byte(fmt.Sprint(i)))
+	}
+	original := sk.Clone()
+
+	sk.ForgetKey(heavy, 0)
+	if got := sk.Query(heavy); got != 0 {
+		t.Errorf("expected a forgotten key to estimate 0, got %v", got)
+	}
+	for i := range before {
+		if got := sk.Query([]byte(fmt.Sprint(i))); got > before[i] {
+			t.Errorf("expected %d not to increase, got %v from %v", i, got, before[i])
+		}
+	}
+	if occupied := scanOccupied(sk); sk.Stats().FillRatePct != 100*float64(occupied)/4000 {
+		t.Errorf("expected the fill rate to follow the cleared registers, got %v", sk.Stats().FillRatePct)
+	}
+
+	floor := sk.Clone()
+	floor.Merge(original)
+	floor.ForgetKey(heavy, 10)
+	if got := floor.Query(heavy); got != floor.value(10) {
+		t.Errorf("expected the floor's count, got %v", got)
+	}
+
+	// Merging forgotten sketches keeps the key forgotten; merging one that
+	// still counts it brings it back.
+	other := original.Clone()
+	other.ForgetKey(heavy, 0)
+	sk.Merge(other)
+	if got := sk.Query(heavy); got != 0 {
+		t.Errorf("expected the key to stay forgotten, got %v", got)
+	}
+	sk.Merge(original)
+	if got := sk.Query(heavy); got < 100000*0.9 {
+		t.Errorf("expected merging the original to restore the key, got %v", got)
+	}
+}
+
+func TestForgetKeyDoorkeeper(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<12))
+	once, heavy := []byte("once"), []byte("heavy")
+	sk.Update(once)
+	sk.BulkUpdate(heavy, 1000)
+
+	sk.ForgetKey(once, 0)
+	if got := sk.Query(once); got != 0 {
+		t.Errorf("expected a forgotten doorkept key to estimate 0, got %v", got)
+	}
+	sk.ForgetKey(heavy, 10)
+	if got := sk.Query(heavy); got != sk.value(10) {
+		t.Errorf("expected the floor's count without the doorkeeper's occurrence, got %v", got)
+	}
+
+	// The next occurrence enters the doorkeeper again.
+	sk.Update(once)
+	if got := sk.Query(once); got != 1 {
+		t.Errorf("expected a count of 1 after forgetting, got %v", got)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	sk, _ := NewSketch(20000, 4, 1.00026)
+	for i := 0; i < 20000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%5+1))
+	}
+	for i := 0; i < 100; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint("heavy-", i)), 1000)
+	}
+	heavy := make([]float64, 100)
+	for i := range heavy {
+		heavy[i] = sk.Query([]byte(fmt.Sprint("heavy-", i)))
+	}
+	var light int
+	floor, _ := sk.register(100)
+	for _, row := range sk.store {
+		for _, c := range row {
+			if c != 0 && c < floor {
+				light++
+			}
+		}
+	}
+	compressed := func() int {
+		data, _ := sk.MarshalBinary()
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestCompression)
+		w.Write(data)
+		w.Close()
+		return buf.Len()
+	}
+	before := compressed()
+
+	if cleared := sk.Trim(100); cleared != uint64(light) {
+		t.Errorf("expected %d registers cleared, got %d", light, cleared)
+	}
+	for i := range heavy {
+		if got := sk.Query([]byte(fmt.Sprint("heavy-", i))); got != heavy[i] {
+			t.Errorf("expected heavy-%d to stay at %v, got %v", i, heavy[i], got)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if got := sk.Query([]byte(fmt.Sprint(i))); got != 0 {
+			t.Errorf("expected light key %d to drop to 0, got %v", i, got)
+		}
+	}
+	if occupied := scanOccupied(sk); sk.occupied != uint64(occupied) {
+		t.Errorf("expected %d occupied registers, got %d", occupied, sk.occupied)
+	}
+	if after := compressed(); after > before/4 {
+		t.Errorf("expected the compressed sketch to shrink from %d bytes, got %d", before, after)
+	}
+}
@@ -0,0 +1,99 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Byte order markers of the encoding's fifth header byte.
+const (
+	byteOrderLittle byte = 0
+	byteOrderBig    byte = 1
+)
+
+var byteOrders = [...]binary.ByteOrder{
+	byteOrderLittle: binary.LittleEndian,
+	byteOrderBig:    binary.BigEndian,
+}
+
+// sketchHeader checks that hdr is the header of a Sketch encoding and returns
+// the byte order it declares.
+func sketchHeader(hdr []byte) (binary.ByteOrder, error) {
+	if hdr[0] != encodingVersion {
+		return nil, errors.New("unsupported sketch encoding version")
+	}
+	if hdr[3] != 0 {
+		return nil, errors.New("not a Sketch encoding")
+	}
+	if int(hdr[4]) >= len(byteOrders) {
+		return nil, errors.New("unknown sketch byte order")
+	}
+	return byteOrders[hdr[4]], nil
+}
+
+/*
+FormatField is a fixed-size field of the encoding's header
+*/
+type FormatField struct {
+	Name   string
+	Offset int
+	Size   int
+}
+
+/*
+Format describes the binary encoding of MarshalBinary and MarshalBinaryBigEndian.
+
+The header is followed by the registers in row-major order: register j of row i is the
+RegisterBits-bit unsigned integer at HeaderSize + (i*w + j)*RegisterBits/8, or at
+HeaderSize + (j*d + i)*RegisterBits/8 in column-major order if the "banded" flag is set. If the
+"metadata" flag is set, a metadata block sits between the two and shifts the registers by
+its size: a 4-byte length followed by entries of a 2-byte key length, the key, a 2-byte
+value length and the value. If the "doorkeeper" flag is set, the doorkeeper's bits follow as
+doorkeeperWords 64-bit words; the doorkeeperWords field is always little-endian. Multi-byte
+header fields and registers are little-endian unless the byte order field is BigEndianMarker
+rather than LittleEndianMarker. The exp field holds the IEEE 754 bits of a float64. The
+register width field is 0 for the 16-bit unsigned registers of a Sketch; other types of
+sketch use other values. The "paged" flag leaves the layout unchanged: unallocated pages are
+encoded as zeroes.
+*/
+type Format struct {
+	Version            uint8
+	HeaderSize         int
+	Header             []FormatField
+	RegisterBits       int
+	LittleEndianMarker byte
+	BigEndianMarker    byte
+	FlagBits           map[string]byte
+}
+
+/*
+FormatSpec returns the layout of the binary encoding, which is stable across releases of the
+same Version
+*/
+func FormatSpec() Format {
+	return Format{
+		Version:    encodingVersion,
+		HeaderSize: headerSize,
+		Header: []FormatField{
+			{Name: "version", Offset: 0, Size: 1},
+			{Name: "hashing", Offset: 1, Size: 1},
+			{Name: "flags", Offset: 2, Size: 1},
+			{Name: "registerWidth", Offset: 3, Size: 1},
+			{Name: "byteOrder", Offset: 4, Size: 1},
+			{Name: "doorkeeperWords", Offset: 5, Size: 3},
+			{Name: "w", Offset: 8, Size: 8},
+			{Name: "d", Offset: 16, Size: 8},
+			{Name: "exp", Offset: 24, Size: 8},
+		},
+		RegisterBits:       16,
+		LittleEndianMarker: byteOrderLittle,
+		BigEndianMarker:    byteOrderBig,
+		FlagBits: map[string]byte{
+			"deterministic": flagDeterministic,
+			"banded":        flagBanded,
+			"paged":         flagPaged,
+			"metadata":      flagMetadata,
+			"doorkeeper":    flagDoorkeeper,
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenSketch is a small fixed sketch whose registers all differ in both bytes.
+func goldenSketch() *Sketch {
+	sk, _ := NewSketch(3, 2, 1.5, WithHash128())
+	for i, row := range sk.store {
+		for j := range row {
+			row[j] = uint16(0x0102 + 0x1010*(i*3+j))
+		}
+	}
+	return sk
+}
+
+func TestEncodingGolden(t *testing.T) {
+	sk := goldenSketch()
+	little, _ := sk.MarshalBinary()
+	big, _ := sk.MarshalBinaryBigEndian()
+
+	for name, data := range map[string][]byte{"sketch_le.golden": little, "sketch_be.golden": big} {
+		path := filepath.Join("testdata", name)
+		if *update {
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, golden) {
+			t.Errorf("%s: encoding changed:\n got %x\nwant %x", name, data, golden)
+		}
+
+		restored := &Sketch{}
+		if err := restored.UnmarshalBinary(golden); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if again, _ := restored.MarshalBinary(); !bytes.Equal(again, little) {
+			t.Errorf("%s: expected to decode to the same sketch", name)
+		}
+	}
+}
+
+func TestFormatSpec(t *testing.T) {
+	spec := FormatSpec()
+	sk := goldenSketch()
+	for _, tc := range []struct {
+		marker byte
+		order  binary.ByteOrder
+		encode func() ([]byte, error)
+	}{
+		{spec.LittleEndianMarker, binary.LittleEndian, sk.MarshalBinary},
+		{spec.BigEndianMarker, binary.BigEndian, sk.MarshalBinaryBigEndian},
+	} {
+		data, _ := tc.encode()
+		field := map[string][]byte{}
+		for _, f := range spec.Header {
+			field[f.Name] = data[f.Offset : f.Offset+f.Size]
+		}
+		if field["version"][0] != spec.Version || field["byteOrder"][0] != tc.marker {
+			t.Errorf("expected version %d and byte order %d, got %x", spec.Version, tc.marker, data[:spec.HeaderSize])
+		}
+		if field["hashing"][0] != byte(hashFarm128) || field["registerWidth"][0] != 0 {
+			t.Errorf("unexpected hashing or register width in %x", data[:spec.HeaderSize])
+		}
+		if tc.order.Uint64(field["w"]) != 3 || tc.order.Uint64(field["d"]) != 2 || math.Float64frombits(tc.order.Uint64(field["exp"])) != 1.5 {
+			t.Errorf("unexpected dimensions in %x", data[:spec.HeaderSize])
+		}
+		if len(data) != spec.HeaderSize+6*spec.RegisterBits/8 {
+			t.Errorf("expected %d bytes, got %d", spec.HeaderSize+6*spec.RegisterBits/8, len(data))
+		}
+		// Row-major: register j of row i at HeaderSize + (i*w + j)*2.
+		for i, row := range sk.store {
+			for j, c := range row {
+				if got := tc.order.Uint16(data[spec.HeaderSize+(i*3+j)*spec.RegisterBits/8:]); got != c {
+					t.Errorf("expected register %d,%d = %x, got %x", i, j, c, got)
+				}
+			}
+		}
+	}
+
+	det, _ := NewSketch(3, 2, 1.5, WithDeterministic(true))
+	data, _ := det.MarshalBinary()
+	if data[2] != spec.FlagBits["deterministic"] {
+		t.Errorf("expected the deterministic flag, got %x", data[2])
+	}
+}
+
+func TestBigEndianMergeFrom(t *testing.T) {
+	sk := goldenSketch()
+	data, _ := sk.MarshalBinaryBigEndian()
+	empty, _ := NewSketch(3, 2, 1.5, WithHash128())
+	if _, err := empty.MergeFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := empty.MarshalBinary()
+	b, _ := sk.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Error("expected a big-endian stream to merge like a little-endian one")
+	}
+
+	data[4] = 7
+	if err := empty.UnmarshalBinary(data); err == nil {
+		t.Error("expected error for unknown byte order")
+	}
+}
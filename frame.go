@@ -0,0 +1,75 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+/*
+A frame is the 4-byte magic "CMLF", an 8-byte payload length, the payload (the
+MarshalBinary encoding) and a 4-byte CRC-32 of the payload, all little-endian.
+*/
+var frameMagic = [4]byte{'C', 'M', 'L', 'F'}
+
+const frameHeaderSize = 12
+
+/*
+ErrFrameCorrupt is returned by ReadFramed when a frame has a bad magic or checksum
+*/
+var ErrFrameCorrupt = errors.New("corrupt sketch frame")
+
+/*
+WriteFramed writes the sketch to w as a single self-delimiting frame
+*/
+func (cml *Sketch) WriteFramed(w io.Writer) error {
+	b := make([]byte, frameHeaderSize, frameHeaderSize+cml.encodedSize()+4)
+	copy(b, frameMagic[:])
+	binary.LittleEndian.PutUint64(b[4:], uint64(cml.encodedSize()))
+	b, err := cml.AppendBinary(b)
+	if err != nil {
+		return err
+	}
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b[frameHeaderSize:]))
+	_, err = w.Write(b)
+	return err
+}
+
+/*
+ReadFramed reads the next frame written by WriteFramed from r.
+It returns io.EOF if r ends cleanly before a frame, io.ErrUnexpectedEOF if r ends
+inside a frame and ErrFrameCorrupt if the frame fails validation.
+*/
+func ReadFramed(r io.Reader) (*Sketch, error) {
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte(hdr[:4]) != frameMagic {
+		return nil, ErrFrameCorrupt
+	}
+	size := binary.LittleEndian.Uint64(hdr[4:])
+	if size < headerSize || size > math.MaxInt-4 {
+		return nil, ErrFrameCorrupt
+	}
+	// Grow the buffer as the payload arrives rather than trusting the length, so
+	// a garbage header fails with io.ErrUnexpectedEOF instead of a huge allocation.
+	var buf bytes.Buffer
+	if n, err := io.Copy(&buf, io.LimitReader(r, int64(size+4))); err != nil {
+		return nil, err
+	} else if uint64(n) != size+4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := buf.Bytes()
+	if binary.LittleEndian.Uint32(b[size:]) != crc32.ChecksumIEEE(b[:size]) {
+		return nil, ErrFrameCorrupt
+	}
+	cml := &Sketch{}
+	if err := cml.UnmarshalBinary(b[:size]); err != nil {
+		return nil, err
+	}
+	return cml, nil
+}
@@ -0,0 +1,66 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestFramed(t *testing.T) {
+	var buf bytes.Buffer
+	for i := uint(1); i <= 3; i++ {
+		sk, _ := NewSketch(100*i, i, 1.00026)
+		sk.BulkUpdate([]byte("key"), 10*i)
+		if err := sk.WriteFramed(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	r := bytes.NewReader(data)
+	var n uint
+	for {
+		sk, err := ReadFramed(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if sk.w != 100*n || sk.d != n {
+			t.Errorf("expected frame %d to hold a %dx%d sketch, got %dx%d", n, 100*n, n, sk.w, sk.d)
+		}
+		if count := sk.Query([]byte("key")); count < float64(10*n)-1 || count > float64(10*n)+1 {
+			t.Errorf("expected ~%d, got %f", 10*n, count)
+		}
+	}
+	if n != 3 {
+		t.Errorf("expected 3 frames, got %d", n)
+	}
+
+	r = bytes.NewReader(data[:len(data)-10])
+	for i := 0; i < 2; i++ {
+		if _, err := ReadFramed(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ReadFramed(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated frame, got %v", err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[frameHeaderSize+headerSize] ^= 0xff
+	if _, err := ReadFramed(bytes.NewReader(corrupt)); !errors.Is(err, ErrFrameCorrupt) {
+		t.Errorf("expected ErrFrameCorrupt, got %v", err)
+	}
+
+	huge := append([]byte(nil), data[:frameHeaderSize]...)
+	binary.LittleEndian.PutUint64(huge[4:], math.MaxInt-4)
+	huge = append(huge, data[frameHeaderSize:frameHeaderSize+headerSize]...)
+	if _, err := ReadFramed(bytes.NewReader(huge)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a garbage length, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package cml
+
+import (
+	"math/bits"
+
+	"github.com/dgryski/go-farm"
+)
+
+// hashing identifies how keys are hashed and mapped to columns. It is part of
+// the encoding, since sketches hashed differently cannot be combined.
+type hashing uint8
+
+const (
+	// hashFarm64 splits one 64-bit farmhash into two 32-bit halves.
+	hashFarm64 hashing = 0
+	// hashFarm128 uses the two 64-bit halves of a 128-bit farmhash.
+	hashFarm128 hashing = hashWide
+	// hashFNV64 is hashFarm64 with FNV-1a instead of farmhash.
+	hashFNV64 hashing = hashFNV
+	// hashFNV128 is hashFarm128 with FNV-1a instead of farmhash.
+	hashFNV128 hashing = hashFNV | hashWide
+
+	hashWide hashing = 1 << 0
+	hashFNV  hashing = 1 << 1
+)
+
+func (hs hashing) String() string {
+	switch hs {
+	case hashFarm64:
+		return "farm64"
+	case hashFarm128:
+		return "farm128"
+	case hashFNV64:
+		return "fnv64"
+	case hashFNV128:
+		return "fnv128"
+	}
+	return "unknown"
+}
+
+// keyHash is a hashed key; hi is only used by the 128-bit schemes.
+type keyHash struct {
+	lo, hi uint64
+}
+
+func (cml *Sketch) hash(e []byte) keyHash {
+	return cml.hashing.hash(e)
+}
+
+// column returns the column row i of the store uses for the hashed key. Every
+// probe of the store must go through it.
+func (cml *Sketch) column(h keyHash, i int) uint {
+	return cml.hashing.column(h, i, cml.w)
+}
+
+func (hs hashing) hash(e []byte) keyHash {
+	switch hs {
+	case hashFarm128:
+		lo, hi := farm.Hash128(e)
+		return keyHash{lo: lo, hi: hi}
+	case hashFNV64:
+		return keyHash{lo: fnv64a(e)}
+	case hashFNV128:
+		// The low half of FNV-128 is barely mixed, since the prime's low
+		// word is small, so both halves go through a finalizer.
+		lo, hi := fnv128a(e)
+		return keyHash{lo: fmix64(lo ^ hi), hi: fmix64(hi)}
+	}
+	return keyHash{lo: farm.Hash64(e)}
+}
+
+func (hs hashing) column(h keyHash, i int, w uint) uint {
+	if hs&hashWide != 0 {
+		return uint((h.lo + uint64(i)*h.hi) % uint64(w))
+	}
+	h1 := uint32(h.lo & 0xffffffff)
+	h2 := uint32((h.lo >> 32) & 0xffffffff)
+	saltedHash := uint((h1 + uint32(i)*h2))
+	return saltedHash % w
+}
+
+// fnv64a is hash/fnv's New64a without the allocation.
+func fnv64a(e []byte) uint64 {
+	h := uint64(0xcbf29ce484222325)
+	for _, c := range e {
+		h ^= uint64(c)
+		h *= 0x100000001b3
+	}
+	return h
+}
+
+// fnv128a is hash/fnv's New128a without the allocation, returning the low and
+// high halves of the hash.
+func fnv128a(e []byte) (lo, hi uint64) {
+	hi, lo = 0x6c62272e07bb0142, 0x62b821756295c58d
+	for _, c := range e {
+		lo ^= uint64(c)
+		// Multiply by the FNV prime 2^88 + 0x13b, modulo 2^128.
+		h, l := bits.Mul64(lo, 0x13b)
+		hi, lo = h+hi*0x13b+lo<<24, l
+	}
+	return lo, hi
+}
+
+// fmix64 is the 64-bit finalizer of MurmurHash3.
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
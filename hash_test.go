@@ -0,0 +1,174 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"testing"
+)
+
+func TestHash128Uniformity(t *testing.T) {
+	// No store is needed to look at where keys land.
+	w := uint(3) << 30
+	narrow := &Sketch{w: w, d: 4}
+	wide := &Sketch{w: w, d: 4, hashing: hashFarm128}
+
+	// 2^32 is not a multiple of w, so reducing a 32-bit hash modulo w
+	// lands in [0, 2^32-w) twice as often as anywhere else.
+	const n = 100000
+	var narrowLow, wideLow int
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprint(i))
+		for row := 0; row < 4; row++ {
+			if narrow.column(narrow.hash(key), row) < 1<<30 {
+				narrowLow++
+			}
+			if wide.column(wide.hash(key), row) < 1<<30 {
+				wideLow++
+			}
+		}
+	}
+	if frac := float64(wideLow) / (4 * n); math.Abs(frac-1.0/3) > 0.01 {
+		t.Errorf("expected 128-bit hashing to hit the first third of the columns 1/3 of the time, got %f", frac)
+	}
+	if frac := float64(narrowLow) / (4 * n); math.Abs(frac-0.5) > 0.01 {
+		t.Errorf("expected 64-bit hashing to hit the first third of the columns half of the time, got %f", frac)
+	}
+
+	if math.MaxUint > math.MaxUint32 {
+		var huge uint = 1
+		huge <<= 33
+		narrow.w, wide.w = huge, huge
+		var narrowMax, wideMax uint
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprint(i))
+			narrowMax = max(narrowMax, narrow.column(narrow.hash(key), 1))
+			wideMax = max(wideMax, wide.column(wide.hash(key), 1))
+		}
+		if uint64(narrowMax) >= 1<<32 || uint64(wideMax) < 1<<32 {
+			t.Errorf("expected only 128-bit hashing to reach columns beyond 2^32, got %d and %d", narrowMax, wideMax)
+		}
+	}
+}
+
+func TestHash128Compatibility(t *testing.T) {
+	narrow, _ := NewSketch(1000, 4, 1.00026)
+	wide, _ := NewSketch(1000, 4, 1.00026, WithHash128())
+	wide.BulkUpdate([]byte("a"), 100)
+
+	if err := narrow.Merge(wide); err == nil {
+		t.Error("expected merging differently hashed sketches to fail")
+	}
+
+	data, _ := wide.MarshalBinary()
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.hashing != hashFarm128 || restored.Query([]byte("a")) != wide.Query([]byte("a")) {
+		t.Error("expected the hashing scheme to survive a round trip")
+	}
+	if err := restored.Merge(wide); err != nil {
+		t.Error(err)
+	}
+
+	data[1] = 0xff
+	if err := restored.UnmarshalBinary(data); err == nil {
+		t.Error("expected error for unknown hashing scheme")
+	}
+}
+
+func TestHash128WAL(t *testing.T) {
+	var wal bytes.Buffer
+	sk, _ := NewSketch(1000, 4, 1.00026, WithHash128(), WithWAL(&wal))
+	sk.BulkUpdate([]byte("a"), 3)
+	sk.BulkUpdate([]byte("b"), 5)
+
+	restored, _ := NewSketch(1000, 4, 1.00026, WithHash128())
+	n, err := restored.ReplayWAL(bytes.NewReader(wal.Bytes()))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 records, got %d, %v", n, err)
+	}
+	if math.Round(restored.Query([]byte("a"))) != 3 || math.Round(restored.Query([]byte("b"))) != 5 {
+		t.Error("expected replayed counts to match")
+	}
+
+	narrow, _ := NewSketch(1000, 4, 1.00026)
+	if _, err := narrow.ReplayWAL(bytes.NewReader(wal.Bytes())); err != ErrWALCorrupt {
+		t.Errorf("expected ErrWALCorrupt replaying 128-bit records, got %v", err)
+	}
+}
+
+func TestFNV(t *testing.T) {
+	for _, key := range []string{"", "a", "foobar", "the quick brown fox jumps over the lazy dog"} {
+		h64 := fnv.New64a()
+		h64.Write([]byte(key))
+		if got := fnv64a([]byte(key)); got != h64.Sum64() {
+			t.Errorf("expected fnv64a(%q) = %x, got %x", key, h64.Sum64(), got)
+		}
+
+		h128 := fnv.New128a()
+		h128.Write([]byte(key))
+		sum := h128.Sum(nil)
+		lo, hi := fnv128a([]byte(key))
+		if hi != binary.BigEndian.Uint64(sum) || lo != binary.BigEndian.Uint64(sum[8:]) {
+			t.Errorf("expected fnv128a(%q) = %x, got %016x%016x", key, sum, hi, lo)
+		}
+	}
+}
+
+func TestHashingAccuracy(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"farm64":  nil,
+		"farm128": {WithHash128()},
+		"fnv64":   {WithFNVHash()},
+		"fnv128":  {WithFNVHash(), WithHash128()},
+	} {
+		sk, _ := NewSketch(20000, 4, 1.00026, opts...)
+		for i := 0; i < 10000; i++ {
+			sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%10+1))
+		}
+		var errSum float64
+		for i := 0; i < 10000; i++ {
+			errSum += math.Abs(sk.Query([]byte(fmt.Sprint(i)))-float64(i%10+1)) / float64(i%10+1)
+		}
+		if mean := errSum / 10000; mean > 0.05 {
+			t.Errorf("%s: expected mean relative error below 5%%, got %f", name, mean)
+		}
+	}
+
+	farm, _ := NewSketch(1000, 4, 1.00026)
+	fnvSketch, _ := NewSketch(1000, 4, 1.00026, WithFNVHash())
+	if err := farm.Merge(fnvSketch); err == nil {
+		t.Error("expected merging farm and FNV sketches to fail")
+	}
+
+	fnvSketch.BulkUpdate([]byte("a"), 100)
+	data, _ := fnvSketch.MarshalBinary()
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.hashing != hashFNV64 || restored.Query([]byte("a")) != fnvSketch.Query([]byte("a")) {
+		t.Error("expected the FNV scheme to survive a round trip")
+	}
+}
+
+func benchmarkHashing(b *testing.B, opts ...Option) {
+	sk, _ := NewSketch(1<<20, 4, 1.00026, opts...)
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("https://example.com/some/path/%d", i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk.Update(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkUpdateFarm64(b *testing.B)  { benchmarkHashing(b) }
+func BenchmarkUpdateFarm128(b *testing.B) { benchmarkHashing(b, WithHash128()) }
+func BenchmarkUpdateFNV64(b *testing.B)   { benchmarkHashing(b, WithFNVHash()) }
+func BenchmarkUpdateFNV128(b *testing.B)  { benchmarkHashing(b, WithFNVHash(), WithHash128()) }
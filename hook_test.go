@@ -0,0 +1,56 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIncrementHook(t *testing.T) {
+	calls := map[uint64]int{}
+	last := map[uint64]uint64{}
+	sk, _ := NewSketch(10000, 4, 1.00026, WithIncrementHook(func(keyHash, newRegister uint64) {
+		calls[keyHash]++
+		if newRegister <= last[keyHash] {
+			t.Errorf("expected register levels to rise, got %d after %d", newRegister, last[keyHash])
+		}
+		last[keyHash] = newRegister
+	}))
+
+	for i := 0; i < 100; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i*10))
+	}
+	total := 0
+	for _, n := range calls {
+		total += n
+	}
+	stats := sk.Stats()
+	if accepted := stats.TotalUpdates - stats.RejectedUpdates; uint64(total) != accepted {
+		t.Errorf("expected %d hook calls, one per accepted increment, got %d", accepted, total)
+	}
+	h := sk.hash([]byte("99"))
+	if reg := uint64(sk.minRegister(h)); last[h.lo] != reg || uint64(calls[h.lo]) != reg {
+		t.Errorf("expected %d increments up to register %d, got %d up to %d", reg, reg, calls[h.lo], last[h.lo])
+	}
+}
+
+func TestIncrementHookReentrant(t *testing.T) {
+	calls := 0
+	var sk *Sketch
+	sk, _ = NewSketch(1000, 4, 1.00026, WithIncrementHook(func(uint64, uint64) {
+		calls++
+		sk.Update([]byte("from the hook"))
+	}))
+	sk.BulkUpdate([]byte("key"), 10)
+	if reg := int(sk.QueryRaw([]byte("key"))); calls != reg {
+		t.Errorf("expected only the outer update's %d increments to call the hook, got %d calls", reg, calls)
+	}
+	if got := sk.Query([]byte("from the hook")); got < float64(calls)-1.5 || got > float64(calls)+0.5 {
+		t.Errorf("expected the hook's %d updates to apply, got %f", calls, got)
+	}
+
+	plain, _ := NewSketch(1000, 4, 1.00026)
+	key := []byte("key")
+	if allocs := testing.AllocsPerRun(100, func() { plain.Update(key) }); allocs != 0 {
+		t.Errorf("expected no allocations without a hook, got %f", allocs)
+	}
+}
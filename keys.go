@@ -0,0 +1,66 @@
+package cml
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrEmptyKey is wrapped by the KeyError for an empty key under WithRejectEmptyKeys.
+	ErrEmptyKey = errors.New("empty key")
+	// ErrKeyTooLong is wrapped by the KeyError for a key beyond WithMaxKeyLength.
+	ErrKeyTooLong = errors.New("key too long")
+)
+
+/*
+KeyError reports a key refused by the sketch's key validation
+*/
+type KeyError struct {
+	Len int
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key of length %d: %v", e.Len, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+/*
+WithRejectEmptyKeys refuses nil and empty keys instead of counting them as one key
+*/
+func WithRejectEmptyKeys() Option {
+	return func(cml *Sketch) error {
+		cml.rejectEmptyKeys = true
+		return nil
+	}
+}
+
+/*
+WithMaxKeyLength refuses keys longer than n bytes before hashing them
+*/
+func WithMaxKeyLength(n int) Option {
+	return func(cml *Sketch) error {
+		if n < 1 {
+			return errors.New("max key length needs to be >= 1")
+		}
+		cml.maxKeyLength = n
+		return nil
+	}
+}
+
+/*
+CheckKey returns the *KeyError the sketch's key validation raises for `e`, or nil.
+Refused keys are not counted by updates and estimate 0 in queries.
+*/
+func (cml *Sketch) CheckKey(e []byte) error {
+	if cml.rejectEmptyKeys && len(e) == 0 {
+		return &KeyError{Len: 0, Err: ErrEmptyKey}
+	}
+	if cml.maxKeyLength > 0 && len(e) > cml.maxKeyLength {
+		return &KeyError{Len: len(e), Err: ErrKeyTooLong}
+	}
+	return nil
+}
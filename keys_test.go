@@ -0,0 +1,92 @@
+package cml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyValidationDefaults(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	for _, key := range [][]byte{nil, {}, make([]byte, 1<<20)} {
+		if err := sk.CheckKey(key); err != nil {
+			t.Errorf("expected no validation by default, got %v", err)
+		}
+	}
+	sk.Update(nil)
+	if sk.Query([]byte{}) != 1 {
+		t.Error("expected nil and empty keys to count as the same key by default")
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	sk, err := NewSketch(100, 4, 1.00026, WithRejectEmptyKeys(), WithMaxKeyLength(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := []byte("12345678")
+	sk.Update(valid)
+
+	for _, tc := range []struct {
+		key []byte
+		err error
+	}{
+		{nil, ErrEmptyKey},
+		{[]byte{}, ErrEmptyKey},
+		{[]byte("123456789"), ErrKeyTooLong},
+	} {
+		var keyErr *KeyError
+		if err := sk.CheckKey(tc.key); !errors.As(err, &keyErr) || !errors.Is(err, tc.err) || keyErr.Len != len(tc.key) {
+			t.Errorf("%q: expected a KeyError wrapping %v, got %v", tc.key, tc.err, err)
+		}
+		if _, err := sk.UpdateResult(tc.key); !errors.Is(err, tc.err) {
+			t.Errorf("%q: expected UpdateResult to fail with %v, got %v", tc.key, tc.err, err)
+		}
+		if _, err := sk.BulkUpdateResult(tc.key, 10); !errors.Is(err, tc.err) {
+			t.Errorf("%q: expected BulkUpdateResult to fail with %v, got %v", tc.key, tc.err, err)
+		}
+		if sk.Update(tc.key) || sk.BulkUpdate(tc.key, 10) {
+			t.Errorf("%q: expected updates to report false", tc.key)
+		}
+		if applied, _ := sk.BulkUpdateSaturating(tc.key, 10); applied != 0 {
+			t.Errorf("%q: expected nothing applied, got %d", tc.key, applied)
+		}
+		if sk.Query(tc.key) != 0 {
+			t.Errorf("%q: expected a refused key to estimate 0", tc.key)
+		}
+		if _, err := QueryAcross(tc.key, CombineMax, sk); !errors.Is(err, tc.err) {
+			t.Errorf("%q: expected QueryAcross to fail with %v, got %v", tc.key, tc.err, err)
+		}
+		if sk.CompareFrequency(tc.key, valid) != -1 {
+			t.Errorf("%q: expected a refused key to compare below a counted one", tc.key)
+		}
+		if top := sk.TopAmongCandidates([][]byte{tc.key, valid}, 2); len(top) != 1 {
+			t.Errorf("%q: expected refused candidates to be skipped, got %v", tc.key, top)
+		}
+		if counts := sk.CountsAbove([][]byte{tc.key, valid}, 0); len(counts) != 1 {
+			t.Errorf("%q: expected refused candidates to be skipped, got %v", tc.key, counts)
+		}
+	}
+
+	if sk.Query(valid) != 1 {
+		t.Errorf("expected a key of the max length to be counted, got %v", sk.Query(valid))
+	}
+	if stats := sk.Stats(); stats.TotalUpdates != 1 {
+		t.Errorf("expected refused keys not to be counted, got %d updates", stats.TotalUpdates)
+	}
+	if clone := sk.Clone(); clone.CheckKey(nil) == nil {
+		t.Error("expected clones to keep the key validation")
+	}
+
+	lines, err := sk.LoadFromReader(strings.NewReader("a\nb\n0123456789\nc\n"))
+	if lines != 2 || !errors.Is(err, ErrKeyTooLong) || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("expected the load to stop at line 3, got %d lines and %v", lines, err)
+	}
+	if _, err := sk.LoadWeightedFromReader(strings.NewReader("\t5\n")); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("expected ErrEmptyKey from the weighted load, got %v", err)
+	}
+
+	if _, err := NewSketch(100, 4, 1.00026, WithMaxKeyLength(0)); err == nil {
+		t.Error("expected error for max key length 0")
+	}
+}
@@ -0,0 +1,55 @@
+package cml
+
+import "unsafe"
+
+/*
+WithBandedLayout stores the d registers a key probes for each column next to each other
+instead of w registers apart, so a probe touches one or two cache lines rather than d. It
+speeds up updates and queries of sketches much larger than the CPU caches and leaves the
+estimates unchanged. The layout is encoded; sketches with different layouts cannot be merged.
+*/
+func WithBandedLayout(on bool) Option {
+	return func(cml *Sketch) error {
+		cml.banded = on
+		return nil
+	}
+}
+
+// cell returns the register at column col of row row, allocating its page in a
+// paged store. Probes of the store must go through it, or at for reads, rather
+// than index the store's chunks, which only hold rows in the unpaged row-major
+// layout.
+func (cml *Sketch) cell(row, col uint) *uint16 {
+	if cml.banded || cml.paged {
+		return cml.mappedCell(row, col)
+	}
+	return &cml.store[row][col]
+}
+
+// mappedCell is cell for the layouts that do not keep rows in the store's
+// chunks, out of line so that cell stays cheap enough to inline.
+func (cml *Sketch) mappedCell(row, col uint) *uint16 {
+	if cml.paged {
+		k := cml.index(row, col)
+		return &cml.chunk(int(k / pageRegisters))[k%pageRegisters]
+	}
+	if cml.banded {
+		return &unsafe.Slice(unsafe.SliceData(cml.store[0]), cml.w*cml.d)[col*cml.d+row]
+	}
+	return &cml.store[row][col]
+}
+
+// logicalRows returns the registers row by row: the store itself in the unpaged
+// row-major layout and a copy otherwise.
+func (cml *Sketch) logicalRows() [][]uint16 {
+	if !cml.banded && !cml.paged {
+		return cml.store
+	}
+	rows := newStore(cml.w, cml.d)
+	for i, row := range rows {
+		for j := range row {
+			row[j] = cml.at(uint(i), uint(j))
+		}
+	}
+	return rows
+}
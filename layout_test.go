@@ -0,0 +1,81 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBandedLayout(t *testing.T) {
+	rowMajor, _ := NewSketch(1000, 4, 1.00026, WithDeterministic(true))
+	banded, _ := NewSketch(1000, 4, 1.00026, WithDeterministic(true), WithBandedLayout(true))
+	for i := 0; i < 5000; i++ {
+		key := []byte(fmt.Sprint(i))
+		rowMajor.BulkUpdate(key, uint(i%30+1))
+		banded.BulkUpdate(key, uint(i%30+1))
+	}
+
+	for i := 0; i < 6000; i++ {
+		key := []byte(fmt.Sprint(i))
+		if a, b := rowMajor.Query(key), banded.Query(key); a != b {
+			t.Fatalf("expected %f for %s, got %f", a, key, b)
+		}
+	}
+	for row := uint(0); row < 4; row++ {
+		for col := uint(0); col < 1000; col += 97 {
+			a, _ := rowMajor.GetRegister(row, col)
+			b, _ := banded.GetRegister(row, col)
+			if a != b {
+				t.Errorf("expected register %d,%d to be %d, got %d", row, col, a, b)
+			}
+		}
+	}
+	if _, _, a := rowMajor.ExportRedisCMS(); fmt.Sprint(a) != fmt.Sprint(third(banded.ExportRedisCMS())) {
+		t.Error("expected the same matrix row by row")
+	}
+
+	data, _ := banded.MarshalBinary()
+	if data[2]&flagBanded == 0 {
+		t.Error("expected the layout in the header")
+	}
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := banded.Query([]byte("42")), restored.Query([]byte("42")); a != b {
+		t.Errorf("expected %f after a round trip, got %f", a, b)
+	}
+
+	if err := rowMajor.Merge(banded); err == nil {
+		t.Error("expected sketches with different layouts not to merge")
+	}
+	shards, _ := banded.SplitRows(2)
+	combined, err := CombineShards(shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := banded.Query([]byte("42")), combined.Query([]byte("42")); a != b {
+		t.Errorf("expected %f after splitting and combining, got %f", a, b)
+	}
+}
+
+func third(_, _ uint, counters []uint64) []uint64 {
+	return counters
+}
+
+func benchmarkLayout(b *testing.B, opts ...Option) {
+	// 128MB of registers, well beyond the CPU caches.
+	sk, _ := NewSketch(1<<24, 4, 1.00026, opts...)
+	keys := make([][]byte, 1<<16)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	i := 0
+	for b.Loop() {
+		sk.Update(keys[i%len(keys)])
+		sk.Query(keys[(i*7)%len(keys)])
+		i++
+	}
+}
+
+func BenchmarkRowMajorLayout(b *testing.B) { benchmarkLayout(b) }
+func BenchmarkBandedLayout(b *testing.B)   { benchmarkLayout(b, WithBandedLayout(true)) }
@@ -0,0 +1,93 @@
+package cml
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+NewLike returns an empty sketch that merges with other: the same dimensions, exp, hashing scheme,
+modes, doorkeeper size, seed, aging and key validation, with registers and statistics of its own. Like Clone, it has no
+write-ahead log attached. NewLike of a zero Sketch is a zero Sketch.
+*/
+func NewLike(other *Sketch) *Sketch {
+	if !other.initialized() {
+		return &Sketch{}
+	}
+	cml := &Sketch{
+		w:          other.w,
+		d:          other.d,
+		exp:        other.exp,
+		logExp:     other.logExp,
+		store:      other.emptyStore(),
+		hashing:    other.hashing,
+		sampleSize: other.sampleSize,
+
+		banded:        other.banded,
+		paged:         other.paged,
+		deterministic: other.deterministic,
+		checks:        other.checks,
+
+		rejectEmptyKeys: other.rejectEmptyKeys,
+		maxKeyLength:    other.maxKeyLength,
+	}
+	if len(other.doorkeeper) > 0 {
+		cml.doorkeeper = make([]uint64, len(other.doorkeeper))
+	}
+	if other.rnd.seeded {
+		cml.rnd = newRNG(other.rnd.seed)
+	}
+	return cml
+}
+
+// maxLikeBytes bounds the registers and doorkeeper NewLikeSerialized allocates
+// from a header alone, so a corrupt header fails instead of exhausting memory.
+const maxLikeBytes = 8 << 30
+
+/*
+NewLikeSerialized returns an empty sketch that merges with the one encoded by MarshalBinary,
+reading only the encoding's header. Settings that are not encoded, such as aging and key
+validation, are left at their defaults. Headers declaring more than 8 GiB of registers and
+doorkeeper are rejected.
+*/
+func NewLikeSerialized(header []byte) (*Sketch, error) {
+	if len(header) < headerSize {
+		return nil, errors.New("sketch header too short")
+	}
+	order, err := sketchHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		w     = order.Uint64(header[8:])
+		d     = order.Uint64(header[16:])
+		exp   = math.Float64frombits(order.Uint64(header[24:]))
+		hash  = hashing(header[1])
+		flags = header[2]
+	)
+	if err := validateParams(w, d, exp, hash, flags); err != nil {
+		return nil, err
+	}
+	words, err := doorkeeperHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if w > math.MaxInt/2/d || w > maxLikeBytes/2/d || 2*w*d+8*uint64(words) > maxLikeBytes {
+		return nil, errors.New("sketch dimensions too large")
+	}
+	var opts []Option
+	if flags&flagPaged != 0 {
+		opts = append(opts, WithPagedStore())
+	}
+	cml, err := NewSketch(uint(w), uint(d), exp, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cml.hashing = hash
+	cml.deterministic = flags&flagDeterministic != 0
+	cml.banded = flags&flagBanded != 0
+	if words > 0 {
+		cml.doorkeeper = make([]uint64, words)
+	}
+	return cml, nil
+}
@@ -0,0 +1,72 @@
+package cml
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewLike(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithHash128(), WithDeterministic(true), WithSampleSize(1000000), WithMaxKeyLength(16))
+	for i := 0; i < 100; i++ {
+		sk.BulkUpdate([]byte{byte(i)}, uint(i+1))
+	}
+
+	like := NewLike(sk)
+	if like.Query([]byte{42}) != 0 || like.Stats().FillRatePct != 0 || like.Stats().TotalUpdates != 0 {
+		t.Error("expected NewLike to be empty")
+	}
+	if like.hashing != sk.hashing || !like.deterministic || like.sampleSize != sk.sampleSize || like.maxKeyLength != 16 {
+		t.Error("expected NewLike to keep the sketch's settings")
+	}
+	if err := like.Merge(sk); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := like.MarshalBinary()
+	b, _ := sk.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Error("expected NewLike to equal the sketch after merging it")
+	}
+	like.store[0][0]++
+	if sk.store[0][0] == like.store[0][0] {
+		t.Error("expected NewLike not to share registers")
+	}
+
+	if zero := NewLike(&Sketch{}); zero.initialized() {
+		t.Error("expected NewLike of a zero Sketch to be a zero Sketch")
+	}
+}
+
+func TestNewLikeSerialized(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithFNVHash(), WithDeterministic(true))
+	sk.BulkUpdate([]byte("a"), 100)
+	for _, encode := range []func() ([]byte, error){sk.MarshalBinary, sk.MarshalBinaryBigEndian} {
+		data, _ := encode()
+		like, err := NewLikeSerialized(data[:headerSize])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if like.Query([]byte("a")) != 0 {
+			t.Error("expected NewLikeSerialized to be empty")
+		}
+		if _, err := like.MergeFrom(bytes.NewReader(data)); err != nil {
+			t.Errorf("expected NewLikeSerialized to merge with the encoded sketch, got %v", err)
+		}
+		if like.Query([]byte("a")) != sk.Query([]byte("a")) {
+			t.Error("expected the same estimate after merging")
+		}
+	}
+
+	data, _ := sk.MarshalBinary()
+	if _, err := NewLikeSerialized(data[:10]); err == nil {
+		t.Error("expected error for a short header")
+	}
+	huge := append([]byte(nil), data[:headerSize]...)
+	hostOrder.PutUint64(huge[8:], 1<<40)
+	if _, err := NewLikeSerialized(huge); err == nil {
+		t.Error("expected error for a header declaring 2^40 registers per row")
+	}
+	data[1] = 0xff
+	if _, err := NewLikeSerialized(data); err == nil {
+		t.Error("expected error for an unknown hashing scheme")
+	}
+}
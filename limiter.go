@@ -0,0 +1,63 @@
+package cml
+
+import "sync"
+
+/*
+Limiter is an approximate per-key rate limiter built on a Count-Min-Log Sketch.
+All access to the underlying sketch goes through the limiter's lock.
+*/
+type Limiter struct {
+	mu sync.Mutex
+	sk *Sketch
+}
+
+/*
+NewLimiter returns a new Limiter counting into sk
+*/
+func NewLimiter(sk *Sketch) *Limiter {
+	return &Limiter{sk: sk}
+}
+
+/*
+Allow counts one event for `key` and reports whether its estimated count is still within limit
+*/
+func (l *Limiter) Allow(key []byte, limit float64) bool {
+	return l.AllowN(key, 1, limit)
+}
+
+/*
+AllowN counts n events for `key` and reports whether its estimated count is still within limit.
+Rejected events are counted as well, so a key stays limited until the next Reset.
+*/
+func (l *Limiter) AllowN(key []byte, n uint, limit float64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sk.BulkUpdate(key, n)
+	return l.sk.Query(key) <= limit
+}
+
+/*
+Estimate returns the current estimated count of `key`
+*/
+func (l *Limiter) Estimate(key []byte) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sk.Query(key)
+}
+
+/*
+Reset starts a new window by zeroing the underlying sketch
+*/
+func (l *Limiter) Reset() {
+	l.Do((*Sketch).Reset)
+}
+
+/*
+Do runs fn on the underlying sketch while holding the limiter's lock,
+e.g. to decay or checkpoint it periodically
+*/
+func (l *Limiter) Do(fn func(sk *Sketch)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fn(l.sk)
+}
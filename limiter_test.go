@@ -0,0 +1,36 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	sk, _ := NewSketch(100000, 4, 1.00026)
+	l := NewLimiter(sk)
+
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		if l.Allow([]byte("hot"), 100) {
+			allowed++
+		}
+	}
+	if allowed < 95 || allowed > 105 {
+		t.Errorf("expected ~100 allowed events, got %d", allowed)
+	}
+
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("cold-%d", i))
+		if !l.Allow(key, 100) {
+			t.Errorf("expected %s to be allowed", key)
+		}
+	}
+
+	l.Reset()
+	if est := l.Estimate([]byte("hot")); est != 0 {
+		t.Errorf("expected 0 after reset, got %f", est)
+	}
+	if !l.AllowN([]byte("hot"), 50, 100) {
+		t.Error("expected hot to be allowed after reset")
+	}
+}
@@ -0,0 +1,81 @@
+package cml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+/*
+DefaultMaxLineLength is the longest line LoadFromReader and LoadWeightedFromReader accept
+*/
+const DefaultMaxLineLength = 8 << 20
+
+/*
+LoadFromReader updates the sketch with every non-empty newline-delimited key read from r
+and returns the number of keys ingested
+*/
+func (cml *Sketch) LoadFromReader(r io.Reader) (lines uint64, err error) {
+	return cml.load(r, DefaultMaxLineLength, false)
+}
+
+/*
+LoadFromReaderSize is LoadFromReader accepting lines of up to maxLineLength bytes
+*/
+func (cml *Sketch) LoadFromReaderSize(r io.Reader, maxLineLength int) (lines uint64, err error) {
+	return cml.load(r, maxLineLength, false)
+}
+
+/*
+LoadWeightedFromReader reads newline-delimited `key\tcount` lines from r, bulk updating each key
+by its count, and returns the number of lines ingested
+*/
+func (cml *Sketch) LoadWeightedFromReader(r io.Reader) (lines uint64, err error) {
+	return cml.load(r, DefaultMaxLineLength, true)
+}
+
+/*
+LoadWeightedFromReaderSize is LoadWeightedFromReader accepting lines of up to maxLineLength bytes
+*/
+func (cml *Sketch) LoadWeightedFromReaderSize(r io.Reader, maxLineLength int) (lines uint64, err error) {
+	return cml.load(r, maxLineLength, true)
+}
+
+func (cml *Sketch) load(r io.Reader, maxLineLength int, weighted bool) (uint64, error) {
+	if !cml.initialized() {
+		return 0, ErrUninitialized
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var lines, n uint64
+	for scanner.Scan() {
+		n++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !weighted {
+			if _, err := cml.UpdateResult(line); err != nil {
+				return lines, fmt.Errorf("line %d: %w", n, err)
+			}
+			lines++
+			continue
+		}
+		i := bytes.LastIndexByte(line, '\t')
+		if i < 0 {
+			return lines, fmt.Errorf("line %d: missing count column", n)
+		}
+		count, err := strconv.ParseUint(string(line[i+1:]), 10, 0)
+		if err != nil {
+			return lines, fmt.Errorf("line %d: %v", n, err)
+		}
+		if _, err := cml.BulkUpdateResult(line[:i], uint(count)); err != nil {
+			return lines, fmt.Errorf("line %d: %w", n, err)
+		}
+		lines++
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,73 @@
+package cml
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromReader(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	lines, err := sk.LoadFromReader(strings.NewReader("a\nb\n\na\r\nc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != 4 {
+		t.Errorf("expected 4 lines, got %d", lines)
+	}
+	for key, expected := range map[string]float64{"a": 2, "b": 1, "c": 1, "": 0} {
+		if count := sk.Query([]byte(key)); math.Round(count) != expected {
+			t.Errorf("expected %f for %q, got %f", expected, key, count)
+		}
+	}
+}
+
+func TestLoadFromReaderLongLine(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 2<<20)
+	input := append(append([]byte("short\n"), long...), '\n')
+
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	lines, err := sk.LoadFromReader(bytes.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != 2 || sk.Query(long) != 1 {
+		t.Errorf("expected the long line to be ingested, got %d lines", lines)
+	}
+
+	lines, err = sk.LoadFromReaderSize(bytes.NewReader(input), 1<<20)
+	if err != bufio.ErrTooLong {
+		t.Errorf("expected bufio.ErrTooLong, got %v", err)
+	}
+	if lines != 1 {
+		t.Errorf("expected 1 line before the error, got %d", lines)
+	}
+}
+
+func TestLoadWeightedFromReader(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	lines, err := sk.LoadWeightedFromReader(strings.NewReader("a\t100\nb\tc\t5\n\nd\t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+	if count := sk.Query([]byte("a")); count < 95 || count > 105 {
+		t.Errorf("expected ~100 for a, got %f", count)
+	}
+	if count := sk.Query([]byte("b\tc")); math.Round(count) != 5 {
+		t.Errorf("expected 5 for b\\tc, got %f", count)
+	}
+	if count := sk.Query([]byte("d")); math.Round(count) != 1 {
+		t.Errorf("expected 1 for d, got %f", count)
+	}
+
+	for _, input := range []string{"a\n", "a\tx\n", "a\t-1\n"} {
+		if _, err := sk.LoadWeightedFromReader(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
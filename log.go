@@ -2,9 +2,8 @@ package cml
 
 import (
 	"errors"
+	"io"
 	"math"
-
-	"github.com/dgryski/go-farm"
 )
 
 /*
@@ -14,151 +13,524 @@ type Sketch struct {
 	w   uint
 	d   uint
 	exp float64
+	// logExp is log(exp), computed as log1p(exp-1) so values stay accurate
+	// for exp close to 1.
+	logExp float64
+
+	// store holds the registers in chunks of w carved from one allocation: the
+	// rows in the row-major layout, a band of w/d columns each in the banded one.
+	// A paged store instead has chunks of pageRegisters, nil until written.
+	store   [][]uint16
+	hashing hashing
+	banded  bool
+	paged   bool
+
+	wal    io.Writer
+	walErr error
+
+	sampleSize uint64
+	sampled    uint64
+	resets     uint64
+
+	rejectEmptyKeys bool
+	maxKeyLength    int
+
+	doorkeeper []uint64
+
+	// readOnly is set for a store aliasing a caller's buffer.
+	readOnly bool
+
+	incrementHook func(keyHash, newRegister uint64)
+	inHook        bool
+
+	metadata        map[string]string
+	maxMetadataSize int
+	metadataCapped  bool
 
-	store [][]uint16
+	deterministic bool
+	carry         float64
+	rnd           rng
+
+	checks bool
+	inUse  int32
+
+	total     uint64
+	rejected  uint64
+	occupied  uint64
+	saturated uint64
+	// saturatedAt is total when the first register saturated.
+	saturatedAt uint64
 }
 
+/*
+ErrUninitialized is returned when using a zero Sketch that was neither built by a constructor
+nor filled by UnmarshalBinary
+*/
+var ErrUninitialized = errors.New("sketch is not initialized")
+
 /*
 NewSketch returns a new Count-Min-Log Sketch with 16-bit registers
 */
-func NewSketch(w uint, d uint, exp float64) (*Sketch, error) {
-	store := make([][]uint16, d, d)
-	for i := uint(0); i < d; i++ {
-		store[i] = make([]uint16, w, w)
+func NewSketch(w uint, d uint, exp float64, opts ...Option) (*Sketch, error) {
+	if w == 0 || d == 0 {
+		return nil, errors.New("w and d must be non-zero")
+	}
+	var (
+		cml  *Sketch
+		rows [][]uint16
+	)
+	if d <= inlineRows {
+		a := &sketchWithRows{}
+		cml, rows = &a.sketch, a.rows[:d:d]
+	} else {
+		cml = &Sketch{}
+	}
+	cml.w, cml.d = w, d
+	cml.exp, cml.logExp = exp, math.Log1p(exp-1)
+	for _, opt := range opts {
+		if err := opt(cml); err != nil {
+			return nil, err
+		}
+	}
+	if rows != nil && !cml.paged {
+		cml.store = rowsInto(rows, make([]uint16, w*d), w)
+	} else {
+		cml.store = cml.emptyStore()
+	}
+	return cml, nil
+}
+
+// inlineRows is the largest depth whose row headers NewSketch allocates along
+// with the Sketch, leaving the registers as its only other allocation.
+const inlineRows = 8
+
+// sketchWithRows is a Sketch allocated together with the headers of its rows.
+type sketchWithRows struct {
+	sketch Sketch
+	rows   [inlineRows][]uint16
+}
+
+// newStore returns d rows of w registers carved out of a single allocation.
+// Each row is capped at w so appending to it cannot spill into the next.
+func newStore(w, d uint) [][]uint16 {
+	return rowsOf(make([]uint16, w*d), w, d)
+}
+
+// rowsOf splits w*d registers into d rows of w.
+func rowsOf(registers []uint16, w, d uint) [][]uint16 {
+	return rowsInto(make([][]uint16, d), registers, w)
+}
+
+// rowsInto splits registers into the rows of store, w each.
+func rowsInto(store [][]uint16, registers []uint16, w uint) [][]uint16 {
+	for i := range store {
+		store[i] = registers[uint(i)*w : uint(i+1)*w : uint(i+1)*w]
 	}
-	return &Sketch{
-		w:     w,
-		d:     d,
-		exp:   exp,
-		store: store,
-	}, nil
+	return store
 }
 
 /*
 NewSketchForEpsilonDelta for a given error rate epsiolen with a probability of delta
 */
-func NewSketchForEpsilonDelta(epsilon, delta float64) (*Sketch, error) {
-	var (
-		width = uint(math.Ceil(math.E / epsilon))
-		depth = uint(math.Ceil(math.Log(1 / delta)))
-	)
-	return NewSketch(width, depth, 1.00026)
+func NewSketchForEpsilonDelta(epsilon, delta float64, opts ...Option) (*Sketch, error) {
+	plan, err := PlanForEpsilonDelta(epsilon, delta)
+	if err != nil {
+		return nil, err
+	}
+	return NewSketch(plan.W, plan.D, plan.Exp, opts...)
 }
 
 /*
 NewForCapacity16 returns a new Count-Min-Log Sketch with 16-bit registers optimized for a given max capacity and expected error rate
 */
-func NewForCapacity16(capacity uint64, e float64) (*Sketch, error) {
+func NewForCapacity16(capacity uint64, e float64, opts ...Option) (*Sketch, error) {
+	plan, err := PlanForCapacity(capacity, e)
+	if err != nil {
+		return nil, err
+	}
+	return NewSketch(plan.W, plan.D, plan.Exp, opts...)
+}
+
+/*
+NewFromCounts returns a sketch sized for len(counts) keys and error rate e holding the exact
+counts, written straight into the registers rather than sampled in one increment at a time.
+*/
+func NewFromCounts(counts map[string]uint64, e float64, opts ...Option) (*Sketch, error) {
+	cml, err := NewForCapacity16(uint64(len(counts)), e, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for key, count := range counts {
+		c, ok := cml.register(float64(count))
+		if !ok {
+			return nil, errors.New("count exceeds the range of exp")
+		}
+		h := cml.hash([]byte(key))
+		cml.logWAL(h, uint(count))
+		for i := range cml.d {
+			if r := cml.cell(i, cml.column(h, int(i))); c > *r {
+				*r = c
+			}
+		}
+		cml.total += count
+	}
+	cml.recount()
+	return cml, nil
+}
+
+/*
+NewForCapacityAndDepth is NewForCapacity16 with exactly d rows sharing the same total number of registers.
+A d below OptimalDepth gives up some confidence in the estimates.
+*/
+func NewForCapacityAndDepth(capacity uint64, e float64, d uint, opts ...Option) (*Sketch, error) {
+	if d < 1 {
+		return nil, errors.New("d needs to be >= 1")
+	}
+	m, _, err := capacityDimensions(capacity, e)
+	if err != nil {
+		return nil, err
+	}
+	return NewSketch(uint(math.Ceil(m/float64(d))), d, 1.00026, opts...)
+}
+
+/*
+ErrMaxCountTooLarge is returned when no exp fine enough for the requested error rate lets the
+registers reach the requested max count
+*/
+var ErrMaxCountTooLarge = errors.New("max count needs a coarser exp than the error rate allows")
+
+// maxCountHeadroom is how far beyond the requested max count the registers
+// must reach before saturating.
+const maxCountHeadroom = 2
+
+/*
+NewForCapacityAndMaxCount is NewForCapacity16 with the smallest exp whose registers count up to
+twice maxCount before saturating. Smaller exps keep low counts more precise: one register step
+is a relative error of about exp-1, which must not exceed e.
+*/
+func NewForCapacityAndMaxCount(capacity uint64, e float64, maxCount uint64, opts ...Option) (*Sketch, error) {
+	m, k, err := capacityDimensions(capacity, e)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := expForMaxCount(maxCount, e)
+	if err != nil {
+		return nil, err
+	}
+	return NewSketch(uint(m/k), uint(k), exp, opts...)
+}
+
+// expForMaxCount bisects for the smallest exp, no coarser than 1+maxStep, whose
+// largest non-saturated register is worth maxCountHeadroom*maxCount.
+func expForMaxCount(maxCount uint64, maxStep float64) (float64, error) {
+	if maxCount == 0 {
+		return 0, errors.New("maxCount needs to be > 0")
+	}
+	target := maxCountHeadroom * float64(maxCount)
+	// top is the value of the last non-saturated register for exp = 1+x.
+	top := func(x float64) float64 {
+		return math.Expm1((math.MaxUint16-1)*math.Log1p(x)) / x
+	}
+	lo, hi := 1e-9, maxStep
+	if top(hi) < target {
+		return 0, ErrMaxCountTooLarge
+	}
+	if top(lo) >= target {
+		return 1 + lo, nil
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if top(mid) >= target {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return 1 + hi, nil
+}
+
+/*
+OptimalDepth returns the depth NewForCapacity16 picks for a given max capacity and expected error rate
+*/
+func OptimalDepth(capacity uint64, e float64) (uint, error) {
+	_, k, err := capacityDimensions(capacity, e)
+	return uint(k), err
+}
+
+// capacityDimensions returns the total number of registers m and the number of
+// rows k for a given max capacity and expected error rate.
+func capacityDimensions(capacity uint64, e float64) (m, k float64, err error) {
 	if !(e >= 0.001 && e < 1.0) {
-		return nil, errors.New("e needs to be >= 0.001 and < 1.0")
+		return 0, 0, errors.New("e needs to be >= 0.001 and < 1.0")
 	}
 	if capacity < 1000000 {
 		capacity = 1000000
 	}
 
-	m := math.Ceil((float64(capacity) * math.Log(e)) / math.Log(1.0/(math.Pow(2.0, math.Log(2.0)))))
-	w := math.Ceil(math.Log(2.0) * m / float64(capacity))
-
-	return NewSketch(uint(m/w), uint(w), 1.00026)
+	m = math.Ceil((float64(capacity) * math.Log(e)) / math.Log(1.0/(math.Pow(2.0, math.Log(2.0)))))
+	k = math.Ceil(math.Log(2.0) * m / float64(capacity))
+	return m, k, nil
 }
 
 func (cml *Sketch) increaseDecision(c uint16) bool {
-	return randFloat() < 1/math.Pow(cml.exp, float64(c))
+	p := 1 / math.Pow(cml.exp, float64(c))
+	if !cml.deterministic {
+		return cml.randFloat() < p
+	}
+	// Accumulate the expected number of steps and take one whenever it adds
+	// up to a whole step.
+	if cml.carry += p; cml.carry >= 1 {
+		cml.carry--
+		return true
+	}
+	return false
 }
 
 /*
 Update increases the count of `s` by one, return true if added and the current count of `s`
 */
 func (cml *Sketch) Update(e []byte) bool {
-	sk := make([]*uint16, cml.d, cml.d)
-	c := uint16(math.MaxUint16)
-
-	hsum := farm.Hash64(e)
-	h1 := uint32(hsum & 0xffffffff)
-	h2 := uint32((hsum >> 32) & 0xffffffff)
-
-	for i := range sk {
-		saltedHash := uint((h1 + uint32(i)*h2))
-		if sk[i] = &cml.store[i][(saltedHash % cml.w)]; *sk[i] < c {
-			c = *sk[i]
-		}
-	}
-
-	if cml.increaseDecision(c) {
-		for _, k := range sk {
-			if *k == c {
-				*k = c + 1
-			}
-		}
-	}
-	return true
+	return cml.BulkUpdate(e, 1)
 }
 
 /*
 BulkUpdate increases the count of `s` by one, return true if added and the current count of `s`
 */
 func (cml *Sketch) BulkUpdate(e []byte, freq uint) bool {
-	sk := make([]*uint16, cml.d, cml.d)
-	c := uint16(math.MaxUint16)
+	_, err := cml.BulkUpdateResult(e, freq)
+	return err == nil
+}
 
-	hsum := farm.Hash64(e)
-	h1 := uint32(hsum & 0xffffffff)
-	h2 := uint32((hsum >> 32) & 0xffffffff)
+/*
+BulkUpdateSaturating is BulkUpdate that stops once the key's registers saturate. It returns
+the number of increments consumed before the stop, whether they were counted or skipped by
+the probabilistic increment, and whether the stop was due to saturation. The remaining
+freq-applied increments can be spilled into another sketch. A zero Sketch consumes nothing.
+*/
+func (cml *Sketch) BulkUpdateSaturating(e []byte, freq uint) (applied uint, saturated bool) {
+	if cml.writable() != nil || cml.CheckKey(e) != nil {
+		return 0, false
+	}
+	if cml.checks {
+		cml.beginWrite()
+		defer cml.endWrite()
+	}
+	h := cml.hash(e)
+	cml.logWAL(h, freq)
+	applied, _ = cml.add(h, freq)
+	return applied, applied < freq
+}
 
-	for i := range sk {
-		saltedHash := uint((h1 + uint32(i)*h2))
-		if sk[i] = &cml.store[i][(saltedHash % cml.w)]; *sk[i] < c {
-			c = *sk[i]
+// add applies freq increments for the hashed key, halving the sketch whenever
+// the configured sample size is reached, possibly in the middle of the batch.
+// It returns the number of increments consumed before the key saturated and
+// how many of those raised its registers.
+func (cml *Sketch) add(h keyHash, freq uint) (consumed, accepted uint) {
+	if freq > 0 && cml.doorkeep(h) {
+		cml.total++
+		consumed, accepted = cml.add(h, freq-1)
+		return consumed + 1, accepted + 1
+	}
+	if cml.sampleSize == 0 {
+		return cml.updateHash(h, freq)
+	}
+	for f := uint64(freq); f > 0; {
+		step := min(f, cml.sampleSize-cml.sampled)
+		n, a := cml.updateHash(h, uint(step))
+		consumed += n
+		accepted += a
+		cml.sampled += step
+		f -= step
+		if cml.sampled >= cml.sampleSize {
+			cml.halve()
+			cml.sampled /= 2
+			cml.resets++
+		}
+		if n < uint(step) {
+			break
 		}
 	}
+	return consumed, accepted
+}
 
+// updateHash applies freq increments for the hashed key and returns the number
+// consumed before its registers saturated and how many of those raised them.
+func (cml *Sketch) updateHash(h keyHash, freq uint) (consumed, accepted uint) {
+	var probes [maxProbes]*uint16
+	sk, c := cml.probe(h, &probes)
+
+	cml.total += uint64(freq)
 	for i := uint(0); i < freq; i++ {
+		if c == math.MaxUint16 {
+			cml.rejected += uint64(freq - i)
+			return i, accepted
+		}
 		update := false
 		if cml.increaseDecision(c) {
 			for _, k := range sk {
 				if *k == c {
 					*k = c + 1
 					update = true
+					cml.track(c, c+1)
 				}
 			}
 		}
 		if update {
 			c++
+			accepted++
+			if cml.incrementHook != nil && !cml.inHook {
+				cml.callIncrementHook(h, c)
+			}
+		} else {
+			cml.rejected++
 		}
 	}
-	return true
+	return freq, accepted
+}
+
+// callIncrementHook calls the increment hook, keeping it from being entered again.
+func (cml *Sketch) callIncrementHook(h keyHash, c uint16) {
+	cml.inHook = true
+	defer func() { cml.inHook = false }()
+	cml.incrementHook(h.lo, uint64(c))
+}
+
+// value returns the count register c stands for.
+func (cml *Sketch) value(c uint16) float64 {
+	return decodeRegister(c, cml.exp, cml.logExp)
+}
+
+/*
+DecodeRegister returns the count register c stands for in a sketch of base exp: what ValueOf
+returns for a sketch built with exp, without needing the sketch.
+*/
+func DecodeRegister(c uint16, exp float64) float64 {
+	return decodeRegister(c, exp, math.Log1p(exp-1))
 }
 
-func (cml *Sketch) pointValue(c uint16) float64 {
-	if c == 0 {
+// decodeRegister returns the geometric series (exp^c - 1) / (exp - 1) given
+// logExp = ln(exp). Expm1 keeps it accurate when exp is close to 1, where
+// exp^c - 1 would otherwise cancel.
+func decodeRegister(c uint16, exp, logExp float64) float64 {
+	switch c {
+	case 0:
 		return 0
+	case 1:
+		return 1
 	}
-	return math.Pow(cml.exp, float64(c-1))
+	return math.Expm1(float64(c)*logExp) / (exp - 1)
 }
 
-func (cml *Sketch) value(c uint16) float64 {
-	if c <= 1 {
-		return cml.pointValue(c)
+// register returns the smallest register whose value is at least v, and false
+// if v is beyond the largest non-saturated register.
+func (cml *Sketch) register(v float64) (uint16, bool) {
+	if v <= 0 {
+		return 0, true
+	}
+	x := math.Ceil(math.Log1p(v*(cml.exp-1)) / math.Log(cml.exp))
+	if x >= math.MaxUint16 {
+		return 0, false
+	}
+	c := uint16(x)
+	for c > 0 && cml.value(c-1) >= v {
+		c--
+	}
+	for cml.value(c) < v {
+		if c++; c == math.MaxUint16 {
+			return 0, false
+		}
+	}
+	return c, true
+}
+
+/*
+Reset zeroes every register, reusing the existing store
+*/
+func (cml *Sketch) Reset() {
+	if cml.readOnly {
+		return
+	}
+	if cml.paged {
+		clear(cml.store)
+	}
+	for _, chunk := range cml.store {
+		clear(chunk)
 	}
-	v := cml.pointValue(c + 1)
-	return (1 - v) / (1 - cml.exp)
+	clear(cml.doorkeeper)
+	cml.sampled = 0
+	cml.occupied = 0
+	cml.saturated = 0
+	cml.saturatedAt = 0
 }
 
 /*
 Query returns the count of `e`
 */
 func (cml *Sketch) Query(e []byte) float64 {
-	c := uint16(math.MaxUint16)
+	if cml.checks {
+		cml.beginRead()
+		defer cml.endRead()
+	}
+	if cml.CheckKey(e) != nil {
+		return 0
+	}
+	return cml.estimate(cml.hash(e))
+}
 
-	hsum := farm.Hash64(e)
-	h1 := uint32(hsum & 0xffffffff)
-	h2 := uint32((hsum >> 32) & 0xffffffff)
+/*
+QueryExtrapolated is Query that also reports whether every register probed for `e` is saturated,
+in which case Query is stuck at the largest count the registers hold while the true count may
+keep growing. The estimate is then extrapolated on the assumption that the key kept its share
+of the sketch's traffic since the first register saturated; it is best-effort but never below
+Query and never decreases as updates arrive. Saturated keys call for a coarser exp.
+*/
+func (cml *Sketch) QueryExtrapolated(e []byte) (estimate float64, saturated bool) {
+	if cml.CheckKey(e) != nil {
+		return 0, false
+	}
+	h := cml.hash(e)
+	c := cml.minRegister(h)
+	estimate = cml.value(c) + cml.doorkept(h)
+	if c != math.MaxUint16 || !cml.initialized() {
+		return estimate, false
+	}
+	if cml.saturatedAt != 0 && cml.total > cml.saturatedAt {
+		estimate *= float64(cml.total) / float64(cml.saturatedAt)
+	}
+	return estimate, true
+}
 
-	for i := range cml.store {
-		saltedHash := uint((h1 + uint32(i)*h2))
-		if sk := cml.store[i][(saltedHash % cml.w)]; sk < c {
-			c = sk
-		}
+/*
+QueryRaw returns the smallest register probed for `e`, the one Query decodes, without the
+floating-point decoding: 0 for unseen keys. ValueOf converts it to a count, which is Query
+unless the sketch has a doorkeeper, whose bit Query adds on top.
+*/
+func (cml *Sketch) QueryRaw(e []byte) uint16 {
+	if cml.checks {
+		cml.beginRead()
+		defer cml.endRead()
 	}
+	return cml.keyRegister(e)
+}
+
+/*
+ValueOf returns the count register c stands for, see DecodeRegister
+*/
+func (cml *Sketch) ValueOf(c uint16) float64 {
 	return cml.value(c)
 }
+
+// keyRegister returns the smallest register probed for `e`, or 0 if the key
+// validation refuses it.
+func (cml *Sketch) keyRegister(e []byte) uint16 {
+	if cml.CheckKey(e) != nil {
+		return 0
+	}
+	return cml.minRegister(cml.hash(e))
+}
+
+// initialized reports whether the sketch has registers. The zero Sketch has
+// none: it answers 0 to every query and refuses updates until unmarshaled into.
+func (cml *Sketch) initialized() bool {
+	return len(cml.store) != 0
+}
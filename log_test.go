@@ -1,6 +1,7 @@
 package cml
 
 import (
+	"fmt"
 	"math"
 	"testing"
 )
@@ -40,3 +41,209 @@ func TestLogAddAndCount(t *testing.T) {
 		t.Errorf("expected 0, got %d", uint(count))
 	}
 }
+
+func TestBulkUpdateSaturating(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1+1e-12)
+	key := []byte("a")
+	h := sk.hash(key)
+	for i := range sk.store {
+		sk.store[i][sk.column(h, i)] = math.MaxUint16 - 10
+	}
+
+	applied, saturated := sk.BulkUpdateSaturating(key, 25)
+	if applied != 10 || !saturated {
+		t.Errorf("expected 10 applied and saturated, got %d and %v", applied, saturated)
+	}
+	if c := sk.minRegister(h); c != math.MaxUint16 {
+		t.Errorf("expected saturated registers, got %d", c)
+	}
+
+	applied, saturated = sk.BulkUpdateSaturating(key, 5)
+	if applied != 0 || !saturated {
+		t.Errorf("expected nothing applied to a saturated key, got %d and %v", applied, saturated)
+	}
+
+	applied, saturated = sk.BulkUpdateSaturating(key, 0)
+	if applied != 0 || saturated {
+		t.Errorf("expected freq 0 to be a no-op, got %d and %v", applied, saturated)
+	}
+
+	applied, saturated = sk.BulkUpdateSaturating([]byte("b"), 1000)
+	if applied != 1000 || saturated {
+		t.Errorf("expected all increments applied below saturation, got %d and %v", applied, saturated)
+	}
+}
+
+func TestNewSketchAllocs(t *testing.T) {
+	// One allocation for the Sketch with its row headers and one for the registers.
+	allocs := testing.AllocsPerRun(100, func() {
+		NewSketch(1000, 8, 1.00026)
+	})
+	if allocs > 2 {
+		t.Errorf("expected at most 2 allocations, got %v", allocs)
+	}
+
+	sk, _ := NewSketch(10, 3, 1.00026)
+	_ = append(sk.store[0], 1)
+	sk.store[1][9] = 7
+	if sk.store[2][0] != 0 || sk.store[1][0] != 0 {
+		t.Error("expected rows not to share registers")
+	}
+
+	clone := sk.Clone()
+	clone.store[1][9] = 8
+	data, _ := sk.MarshalBinary()
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	restored.store[0][0] = 9
+	if sk.store[1][9] != 7 || sk.store[0][0] != 0 || restored.store[1][9] != 7 {
+		t.Error("expected clones and decoded sketches to have their own registers")
+	}
+}
+
+func TestNewFromCounts(t *testing.T) {
+	counts := make(map[string]uint64)
+	for i := 0; i < 10000; i++ {
+		counts[fmt.Sprint(i)] = uint64(i*i + 1)
+	}
+	sk, err := NewFromCounts(counts, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, count := range counts {
+		got := sk.Query([]byte(key))
+		// The registers round up, so the estimate is at most one step above.
+		if c, _ := sk.register(float64(count)); got < float64(count) || got > sk.value(c) {
+			t.Errorf("expected %s to be within one step of %d, got %v", key, count, got)
+		}
+	}
+	if stats := sk.Stats(); stats.TotalUpdates != 333283345000 {
+		t.Errorf("expected total to be the sum of the counts, got %d", stats.TotalUpdates)
+	}
+
+	if _, err := NewFromCounts(map[string]uint64{"a": math.MaxUint64}, 0.01); err == nil {
+		t.Error("expected error for a count beyond the range of exp")
+	}
+	if _, err := NewFromCounts(counts, 2); err == nil {
+		t.Error("expected error for invalid e")
+	}
+}
+
+func benchmarkCounts() map[string]uint64 {
+	counts := make(map[string]uint64)
+	for i := 0; i < 1000; i++ {
+		counts[fmt.Sprint(i)] = uint64(i + 1000)
+	}
+	return counts
+}
+
+func BenchmarkNewFromCounts(b *testing.B) {
+	counts := benchmarkCounts()
+	for i := 0; i < b.N; i++ {
+		NewFromCounts(counts, 0.01)
+	}
+}
+
+func BenchmarkNewFromCountsBulkUpdate(b *testing.B) {
+	counts := benchmarkCounts()
+	for i := 0; i < b.N; i++ {
+		sk, _ := NewForCapacity16(uint64(len(counts)), 0.01)
+		for key, count := range counts {
+			sk.BulkUpdate([]byte(key), uint(count))
+		}
+	}
+}
+
+func TestQueryExtrapolated(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1+1e-12)
+	key := []byte("a")
+	sk.BulkUpdate([]byte("b"), 1000)
+
+	last, saturated := sk.QueryExtrapolated(key)
+	if last != 0 || saturated {
+		t.Errorf("expected an unseen key to be 0 and unsaturated, got %v and %v", last, saturated)
+	}
+	for _, freq := range []uint{60000, 5535, 1, 1000, 100000} {
+		sk.BulkUpdate(key, freq)
+		got, _ := sk.QueryExtrapolated(key)
+		if got < last || got < sk.Query(key) {
+			t.Errorf("expected a non-decreasing estimate of at least Query, got %v after %v", got, last)
+		}
+		last = got
+	}
+	if _, saturated := sk.QueryExtrapolated(key); !saturated {
+		t.Error("expected the key to be reported saturated")
+	}
+	if got := sk.Query(key); got != sk.value(math.MaxUint16) {
+		t.Errorf("expected Query to be stuck at the largest count, got %v", got)
+	}
+	if last <= sk.Query(key) {
+		t.Errorf("expected the extrapolation to grow past Query, got %v", last)
+	}
+	if _, saturated := sk.QueryExtrapolated([]byte("b")); saturated {
+		t.Error("expected other keys not to be reported saturated")
+	}
+}
+
+func TestQueryRaw(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 2000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%100+1))
+	}
+	for i := 0; i < 3000; i += 7 {
+		key := []byte(fmt.Sprint(i))
+		if got, want := sk.ValueOf(sk.QueryRaw(key)), sk.Query(key); got != want {
+			t.Errorf("expected %f for %s, got %f", want, key, got)
+		}
+	}
+	if got := NewLike(sk).QueryRaw([]byte("unseen")); got != 0 {
+		t.Errorf("expected register 0 for an unseen key, got %d", got)
+	}
+}
+
+func benchmarkQuery(b *testing.B, query func(sk *Sketch, key []byte)) {
+	sk, _ := NewSketch(100000, 4, 1.00026)
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+		sk.BulkUpdate(keys[i], uint(i+1))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query(sk, keys[i%len(keys)])
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	benchmarkQuery(b, func(sk *Sketch, key []byte) { sk.Query(key) })
+}
+
+func BenchmarkQueryRaw(b *testing.B) {
+	benchmarkQuery(b, func(sk *Sketch, key []byte) { sk.QueryRaw(key) })
+}
+
+func TestResetReusesStore(t *testing.T) {
+	sk, _ := NewSketch(100000, 7, 1.00026)
+	hot := []byte("hot")
+	sk.BulkUpdate(hot, 1000)
+	registers := &sk.store[0][0]
+
+	if allocs := testing.AllocsPerRun(10, sk.Reset); allocs != 0 {
+		t.Errorf("expected no allocations per reset, got %f", allocs)
+	}
+	if &sk.store[0][0] != registers {
+		t.Error("expected the reset to keep the store")
+	}
+	if got := sk.Query(hot); got != 0 {
+		t.Errorf("expected 0 after a reset, got %f", got)
+	}
+	if stats := sk.Stats(); stats.FillRatePct != 0 || stats.SaturatedRegisters != 0 {
+		t.Errorf("expected empty stats after a reset, got %+v", stats)
+	}
+	sk.BulkUpdate(hot, 10)
+	if got := sk.Query(hot); got < 9 || got > 11 {
+		t.Errorf("expected about 10 after counting again, got %f", got)
+	}
+}
@@ -0,0 +1,285 @@
+package cml
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"unsafe"
+)
+
+const (
+	encodingVersion = 1
+	headerSize      = 32
+)
+
+// Flags of the encoding's third header byte.
+const (
+	flagDeterministic byte = 1 << 0
+	// flagBanded marks registers stored in the banded layout, column by column.
+	flagBanded byte = 1 << 3
+	// flagMetadata marks a metadata block between the header and the registers.
+	flagMetadata byte = 1 << 1
+	// flagDoorkeeper marks a doorkeeper block, sized by the header's reserved
+	// bytes, between the metadata and the registers.
+	flagDoorkeeper byte = 1 << 2
+	// flagPaged marks a sketch with a paged store, see WithPagedStore.
+	flagPaged byte = 1 << 4
+
+	knownFlags = flagDeterministic | flagBanded | flagPaged | flagMetadata | flagDoorkeeper
+)
+
+// flags returns the header flags describing the sketch's modes. They leave out
+// flagMetadata and flagDoorkeeper, which describe the encoding's blocks.
+func (cml *Sketch) flags() byte {
+	var f byte
+	if cml.deterministic {
+		f |= flagDeterministic
+	}
+	if cml.banded {
+		f |= flagBanded
+	}
+	if cml.paged {
+		f |= flagPaged
+	}
+	return f
+}
+
+var (
+	_ encoding.BinaryMarshaler   = (*Sketch)(nil)
+	_ encoding.BinaryUnmarshaler = (*Sketch)(nil)
+	_ encoding.BinaryAppender    = (*Sketch)(nil)
+)
+
+/*
+MarshalBinary encodes the sketch's parameters and registers.
+
+The encoding is a 32-byte header (version, hashing scheme, flags, register width, byte order,
+doorkeeper size, w, d, exp), the metadata block if the sketch has metadata, the doorkeeper
+block if it has one, and the registers row by row, all little-endian. FormatSpec describes the
+layout in full.
+*/
+func (cml *Sketch) MarshalBinary() ([]byte, error) {
+	return cml.AppendBinary(make([]byte, 0, cml.encodedSize()))
+}
+
+/*
+MarshalBinaryBigEndian is MarshalBinary in network byte order, flagged in the header so
+UnmarshalBinary and MergeFrom decode it as such
+*/
+func (cml *Sketch) MarshalBinaryBigEndian() ([]byte, error) {
+	return cml.appendBinary(make([]byte, 0, cml.encodedSize()), byteOrderBig)
+}
+
+/*
+AppendBinary appends the encoding produced by MarshalBinary to b
+*/
+func (cml *Sketch) AppendBinary(b []byte) ([]byte, error) {
+	return cml.appendBinary(b, byteOrderLittle)
+}
+
+func (cml *Sketch) appendBinary(b []byte, marker byte) ([]byte, error) {
+	if !cml.initialized() {
+		return b, ErrUninitialized
+	}
+	b = cml.appendPrefix(slices.Grow(b, cml.encodedSize()), marker)
+	order := byteOrders[marker]
+	off := len(b)
+	b = b[:off+2*int(cml.w*cml.d)]
+	for i, row := range cml.store {
+		switch {
+		case row == nil:
+			n := 2 * int(cml.chunkLen(i))
+			clear(b[off : off+n])
+			off += n
+		case order == hostOrder:
+			off += copy(b[off:], registerBytes(row))
+		default:
+			for _, c := range row {
+				order.PutUint16(b[off:], c)
+				off += 2
+			}
+		}
+	}
+	return b, nil
+}
+
+// appendPrefix appends the part of the encoding before the registers: the
+// header, the metadata block and the doorkeeper block.
+func (cml *Sketch) appendPrefix(b []byte, marker byte) []byte {
+	off := len(b)
+	b = append(b, make([]byte, headerSize)...)
+	hdr := b[off:]
+	hdr[0] = encodingVersion
+	hdr[1] = byte(cml.hashing)
+	hdr[2] = cml.flags()
+	hdr[4] = marker
+	order := byteOrders[marker]
+	order.PutUint64(hdr[8:], uint64(cml.w))
+	order.PutUint64(hdr[16:], uint64(cml.d))
+	order.PutUint64(hdr[24:], math.Float64bits(cml.exp))
+	if len(cml.metadata) > 0 {
+		hdr[2] |= flagMetadata
+	}
+	if n := len(cml.doorkeeper); n > 0 {
+		hdr[2] |= flagDoorkeeper
+		hdr[5], hdr[6], hdr[7] = byte(n), byte(n>>8), byte(n>>16)
+	}
+
+	appender := order.(binary.AppendByteOrder)
+	if len(cml.metadata) > 0 {
+		b = cml.appendMetadata(b, appender)
+	}
+	for _, w := range cml.doorkeeper {
+		b = appender.AppendUint64(b, w)
+	}
+	return b
+}
+
+// registerBytes returns the memory of a row of registers as bytes, in the
+// machine's byte order, for copying a row at once.
+func registerBytes(row []uint16) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(row))), 2*len(row))
+}
+
+func (cml *Sketch) encodedSize() int {
+	n := headerSize + 2*int(cml.w*cml.d)
+	if len(cml.metadata) > 0 {
+		n += 4 + cml.metadataSize()
+	}
+	return n + 8*len(cml.doorkeeper)
+}
+
+/*
+UnmarshalBinary restores a sketch encoded by MarshalBinary, replacing its parameters, registers,
+metadata and doorkeeper. Metadata larger than the sketch's cap is rejected.
+*/
+func (cml *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) < headerSize {
+		return errors.New("sketch data too short")
+	}
+	order, err := sketchHeader(b)
+	if err != nil {
+		return err
+	}
+	data := b[headerSize:]
+	var md map[string]string
+	if b[2]&flagMetadata != 0 {
+		if md, data, err = parseMetadata(data, order, cml.metadataLimit()); err != nil {
+			return err
+		}
+	}
+	dk, data, err := decodeDoorkeeper(b, data, order)
+	if err != nil {
+		return err
+	}
+	if err := cml.decode(
+		order.Uint64(b[8:]),
+		order.Uint64(b[16:]),
+		math.Float64frombits(order.Uint64(b[24:])),
+		hashing(b[1]),
+		b[2],
+		order,
+		data,
+	); err != nil {
+		return err
+	}
+	cml.metadata = md
+	cml.doorkeeper = dk
+	return nil
+}
+
+// decode validates the parameters and registers of an encoded sketch and
+// replaces the sketch's own with them, see adopt.
+func (cml *Sketch) decode(w, d uint64, exp float64, hash hashing, flags byte, order binary.ByteOrder, data []byte) error {
+	if err := validateParams(w, d, exp, hash, flags); err != nil {
+		return err
+	}
+	if n := uint64(len(data)); n%2 != 0 || w > n/2/d || w*d != n/2 {
+		return errors.New("sketch data size does not match its dimensions")
+	}
+
+	dec := cml.decoding(w, d, exp, hash, flags)
+	dec.store = dec.emptyStore()
+	off := 0
+	for i := range dec.store {
+		chunk := data[off : off+2*int(dec.chunkLen(i))]
+		off += len(chunk)
+		dec.decodeChunk(i, chunk, order)
+	}
+	return cml.adopt(dec)
+}
+
+// decoding returns a sketch with an encoding's validated parameters and no
+// store, for the caller to allocate, decodeChunk to fill and adopt to take over.
+func (cml *Sketch) decoding(w, d uint64, exp float64, hash hashing, flags byte) *Sketch {
+	dec := &Sketch{
+		w:             uint(w),
+		d:             uint(d),
+		exp:           exp,
+		logExp:        math.Log1p(exp - 1),
+		hashing:       hash,
+		banded:        flags&flagBanded != 0,
+		paged:         flags&flagPaged != 0,
+		deterministic: flags&flagDeterministic != 0,
+		saturatedAt:   cml.saturatedAt,
+	}
+	return dec
+}
+
+// decodeChunk decodes chunk i of the store from its encoding b.
+func (cml *Sketch) decodeChunk(i int, b []byte, order binary.ByteOrder) {
+	if cml.paged {
+		// Pages without counts stay unallocated.
+		if zeroBytes(b) {
+			return
+		}
+		cml.chunk(i)
+	}
+	row := cml.store[i]
+	if order == hostOrder {
+		copy(registerBytes(row), b)
+		return
+	}
+	for j := range row {
+		row[j] = order.Uint16(b[2*j:])
+	}
+}
+
+// adopt replaces the sketch's parameters and registers with those of dec once
+// dec passes Validate.
+func (cml *Sketch) adopt(dec *Sketch) error {
+	dec.recount()
+	if err := dec.Validate(); err != nil {
+		return err
+	}
+	cml.w, cml.d = dec.w, dec.d
+	cml.exp, cml.logExp = dec.exp, dec.logExp
+	cml.store = dec.store
+	cml.hashing = dec.hashing
+	cml.deterministic = dec.deterministic
+	cml.banded = dec.banded
+	cml.paged = dec.paged
+	cml.carry = 0
+	cml.readOnly = false
+	cml.occupied, cml.saturated, cml.saturatedAt = dec.occupied, dec.saturated, dec.saturatedAt
+	return nil
+}
+
+// validateParams checks the parameters of an encoded sketch.
+func validateParams(w, d uint64, exp float64, hash hashing, flags byte) error {
+	if w == 0 || d == 0 {
+		return errors.New("sketch dimensions must be non-zero")
+	}
+	if !(exp > 1) || math.IsInf(exp, 1) {
+		return errors.New("sketch exp must be > 1 and finite")
+	}
+	if hash > hashFNV128 {
+		return errors.New("unknown sketch hashing scheme")
+	}
+	if flags&^knownFlags != 0 {
+		return errors.New("unknown sketch flags")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	sk.BulkUpdate([]byte("a"), 10000)
+	sk.Update([]byte("b"))
+
+	data, err := sk.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Sketch{}
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if other.w != sk.w || other.d != sk.d || other.exp != sk.exp {
+		t.Fatalf("expected %d/%d/%f, got %d/%d/%f", sk.w, sk.d, sk.exp, other.w, other.d, other.exp)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if got, expected := other.Query([]byte(key)), sk.Query([]byte(key)); got != expected {
+			t.Errorf("expected %f for %s, got %f", expected, key, got)
+		}
+	}
+	again, _ := other.MarshalBinary()
+	if !bytes.Equal(data, again) {
+		t.Error("expected identical encoding after round trip")
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	sk.BulkUpdate([]byte("a"), 10000)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sk); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Sketch
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := sk.MarshalBinary()
+	if again, _ := decoded.MarshalBinary(); !bytes.Equal(data, again) {
+		t.Error("expected gob to round trip the binary encoding")
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	sk, _ := NewSketch(10, 2, 1.00026)
+	data, _ := sk.MarshalBinary()
+
+	corrupt := func(fn func(b []byte) []byte) []byte {
+		return fn(append([]byte(nil), data...))
+	}
+	tests := map[string][]byte{
+		"empty":           nil,
+		"short header":    data[:headerSize-1],
+		"truncated store": data[:len(data)-2],
+		"trailing byte":   append(append([]byte(nil), data...), 0),
+		"bad version":     corrupt(func(b []byte) []byte { b[0] = 99; return b }),
+		"zero width":      corrupt(func(b []byte) []byte { b[8] = 0; return b }),
+		"huge depth":      corrupt(func(b []byte) []byte { b[23] = 0xff; return b }),
+		"exp of one":      corrupt(func(b []byte) []byte { copy(b[24:], []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}); return b }),
+		"absurd width":    corrupt(func(b []byte) []byte { b[15] = 0x80; return b }),
+		"size overflow":   corrupt(func(b []byte) []byte { b[15], b[23] = 0x80, 0x01; return b }),
+		"unknown flag":    corrupt(func(b []byte) []byte { b[2] = 0x80; return b }),
+	}
+	for name, b := range tests {
+		if err := (&Sketch{}).UnmarshalBinary(b); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	sk.BulkUpdate([]byte("a"), 1000)
+	data, _ := sk.MarshalBinary()
+
+	appended, err := sk.AppendBinary(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(appended, data) {
+		t.Error("expected AppendBinary to match MarshalBinary")
+	}
+
+	prefix := []byte("prefix")
+	appended, _ = sk.AppendBinary(append([]byte(nil), prefix...))
+	if !bytes.Equal(appended[:len(prefix)], prefix) || !bytes.Equal(appended[len(prefix):], data) {
+		t.Error("expected the prefix to be preserved")
+	}
+
+	buf := make([]byte, 0, 2*len(data))
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = sk.AppendBinary(buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %f", allocs)
+	}
+}
+
+// benchmarkStore is a 100MB store of distinct registers.
+func benchmarkStore() *Sketch {
+	sk, _ := NewSketch(1<<24, 3, 1.00026)
+	for i, row := range sk.store {
+		for j := range row {
+			row[j] = uint16(i*7 + j)
+		}
+	}
+	return sk
+}
+
+func BenchmarkMarshalBinary(b *testing.B) {
+	sk := benchmarkStore()
+	buf := make([]byte, 0, sk.encodedSize())
+	b.SetBytes(int64(sk.encodedSize()))
+	for b.Loop() {
+		sk.AppendBinary(buf[:0])
+	}
+}
+
+func BenchmarkMarshalBinaryBigEndian(b *testing.B) {
+	sk := benchmarkStore()
+	b.SetBytes(int64(sk.encodedSize()))
+	for b.Loop() {
+		sk.MarshalBinaryBigEndian()
+	}
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	data, _ := benchmarkStore().MarshalBinary()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		(&Sketch{}).UnmarshalBinary(data)
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	sk, _ := NewSketch(10, 2, 1.00026)
+	sk.Update([]byte("a"))
+	data, _ := sk.MarshalBinary()
+	f.Add(data)
+	be, _ := sk.MarshalBinaryBigEndian()
+	f.Add(be)
+	sk.SetMetadata("k", "v")
+	data, _ = sk.MarshalBinary()
+	f.Add(data)
+	for _, opt := range []Option{WithDoorkeeper(64), WithBandedLayout(true), WithPagedStore()} {
+		other, _ := NewSketch(10, 2, 1.00026, opt)
+		other.Update([]byte("a"))
+		data, _ := other.MarshalBinary()
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		decoded := &Sketch{}
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			return
+		}
+		decoded.Query([]byte("a"))
+		again, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&Sketch{}).UnmarshalBinary(again); err != nil {
+			t.Errorf("expected a decoded sketch to encode validly, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package cml
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewForCapacityAndMaxCount(t *testing.T) {
+	sk, err := NewForCapacityAndMaxCount(1000000, 0.01, 10000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := sk.value(math.MaxUint16 - 1); top < 20000000 || top > 20000100 {
+		t.Errorf("expected the registers to reach twice the max count, got %v", top)
+	}
+	// Low counts stay within a fraction of a percent per register step.
+	if sk.exp > 1.0002 {
+		t.Errorf("expected a fine exp, got %v", sk.exp)
+	}
+
+	key := []byte("hot")
+	sk.BulkUpdate(key, 10000000)
+	if got := sk.Query(key); math.Abs(got-10000000) > 10000000*0.05 {
+		t.Errorf("expected ~10000000, got %v", got)
+	}
+	if sk.Stats().SaturatedRegisters != 0 {
+		t.Error("expected no saturated registers")
+	}
+
+	small, _ := NewForCapacityAndMaxCount(1000000, 0.01, 100)
+	if small.exp > 1+1e-8 {
+		t.Errorf("expected the finest exp for counts the registers reach unscaled, got %v", small.exp)
+	}
+
+	if _, err := NewForCapacityAndMaxCount(1000000, 0.01, 0); err == nil {
+		t.Error("expected error for maxCount 0")
+	}
+	if _, err := expForMaxCount(math.MaxUint64, 1e-5); !errors.Is(err, ErrMaxCountTooLarge) {
+		t.Errorf("expected ErrMaxCountTooLarge, got %v", err)
+	}
+}
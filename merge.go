@@ -0,0 +1,236 @@
+package cml
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+/*
+MismatchError is returned when sketches cannot be combined because a parameter differs.
+Field is one of "width", "depth", "exp", "hashing", "flags" or "doorkeeper".
+*/
+type MismatchError struct {
+	Field  string
+	Ours   any
+	Theirs any
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("sketch mismatch: %s ours=%v theirs=%v", e.Field, e.Ours, e.Theirs)
+}
+
+// expTolerance is the relative difference up to which two exps are the same,
+// absorbing last-bit differences from deriving exp with different math.
+const expTolerance = 1e-12
+
+// sameExp reports whether a and b are equal within expTolerance.
+func sameExp(a, b float64) bool {
+	return math.Abs(a-b) <= expTolerance*math.Max(a, b)
+}
+
+func (cml *Sketch) compatible(other *Sketch) error {
+	if !cml.initialized() || !other.initialized() {
+		return ErrUninitialized
+	}
+	if err := cml.compatibleWith(other.w, other.d, other.exp, other.hashing, other.flags()); err != nil {
+		return err
+	}
+	return cml.compatibleDoorkeeper(len(other.doorkeeper))
+}
+
+// compatibleDoorkeeper checks that a sketch with a doorkeeper of words words
+// merges with the sketch.
+func (cml *Sketch) compatibleDoorkeeper(words int) error {
+	if len(cml.doorkeeper) != words {
+		return &MismatchError{Field: "doorkeeper", Ours: 64 * len(cml.doorkeeper), Theirs: 64 * words}
+	}
+	return nil
+}
+
+// compatibleWith is compatible for the parameters of a sketch that is not in
+// memory, such as one being streamed in.
+func (cml *Sketch) compatibleWith(w, d uint, exp float64, hash hashing, flags byte) error {
+	if cml.w != w {
+		return &MismatchError{Field: "width", Ours: cml.w, Theirs: w}
+	}
+	if cml.d != d {
+		return &MismatchError{Field: "depth", Ours: cml.d, Theirs: d}
+	}
+	if !sameExp(cml.exp, exp) {
+		return &MismatchError{Field: "exp", Ours: cml.exp, Theirs: exp}
+	}
+	if cml.hashing != hash {
+		return &MismatchError{Field: "hashing", Ours: cml.hashing, Theirs: hash}
+	}
+	if cml.flags() != flags {
+		return &MismatchError{Field: "flags", Ours: cml.flags(), Theirs: flags}
+	}
+	return nil
+}
+
+/*
+Merge combines other into the sketch by taking the element-wise maximum of the registers,
+approximating the counts of the union of both streams
+*/
+func (cml *Sketch) Merge(other *Sketch) error {
+	if err := cml.writable(); err != nil {
+		return err
+	}
+	if err := cml.compatible(other); err != nil {
+		return err
+	}
+	for i, row := range other.store {
+		if row == nil {
+			continue
+		}
+		ours := cml.chunk(i)
+		for j, c := range row {
+			if c > ours[j] {
+				ours[j] = c
+			}
+		}
+	}
+	for i, w := range other.doorkeeper {
+		cml.doorkeeper[i] |= w
+	}
+	cml.total += other.total
+	cml.rejected += other.rejected
+	cml.recount()
+	return nil
+}
+
+/*
+MergeMin combines other into the sketch by taking the element-wise minimum of the registers.
+
+A key's estimate afterwards is an upper bound on the smaller of its two per-sketch counts,
+which helps to tell keys hot in both streams from keys hot in only one. It is not a true
+intersection: collisions in either sketch still inflate it.
+*/
+func (cml *Sketch) MergeMin(other *Sketch) error {
+	if err := cml.writable(); err != nil {
+		return err
+	}
+	if err := cml.compatible(other); err != nil {
+		return err
+	}
+	for i, row := range cml.store {
+		theirs := other.store[i]
+		if row == nil || theirs == nil {
+			// Only a page of a paged store is nil, and the minimum with it is empty.
+			cml.store[i] = nil
+			continue
+		}
+		for j, c := range theirs {
+			if c < row[j] {
+				row[j] = c
+			}
+		}
+	}
+	for i, w := range other.doorkeeper {
+		cml.doorkeeper[i] &= w
+	}
+	cml.recount()
+	return nil
+}
+
+/*
+MinOf returns a new sketch holding the element-wise minimum of a and b, leaving both untouched
+*/
+func MinOf(a, b *Sketch) (*Sketch, error) {
+	if err := a.compatible(b); err != nil {
+		return nil, err
+	}
+	c := a.Clone()
+	return c, c.MergeMin(b)
+}
+
+/*
+MergeWithDecay scales every register of the sketch by selfWeight in value space and then
+merges other into it like Merge, in a single pass over the store.
+A selfWeight of 1 is exactly Merge.
+*/
+func (cml *Sketch) MergeWithDecay(other *Sketch, selfWeight float64) error {
+	if !(selfWeight > 0 && selfWeight <= 1) {
+		return errors.New("selfWeight needs to be > 0 and <= 1")
+	}
+	if err := cml.writable(); err != nil {
+		return err
+	}
+	if err := cml.compatible(other); err != nil {
+		return err
+	}
+	scale := cml.newScaler(selfWeight)
+	for i, theirs := range other.store {
+		if theirs == nil {
+			for j, c := range cml.store[i] {
+				cml.store[i][j] = scale.register(c)
+			}
+			continue
+		}
+		ours := cml.chunk(i)
+		for j, c := range theirs {
+			ours[j] = max(scale.register(ours[j]), c)
+		}
+	}
+	// Decay clears the sketch's own doorkeeper, as aging does.
+	if selfWeight < 1 {
+		clear(cml.doorkeeper)
+	}
+	for i, w := range other.doorkeeper {
+		cml.doorkeeper[i] |= w
+	}
+	cml.total += other.total
+	cml.rejected += other.rejected
+	cml.recount()
+	return nil
+}
+
+/*
+Clone returns a deep copy of the sketch, including its aging state and statistics. The copy has no write-ahead log attached.
+*/
+func (cml *Sketch) Clone() *Sketch {
+	store := cml.emptyStore()
+	for i, chunk := range cml.store {
+		if cml.paged {
+			store[i] = slices.Clone(chunk)
+			continue
+		}
+		copy(store[i], chunk)
+	}
+	return &Sketch{
+		w:          cml.w,
+		d:          cml.d,
+		exp:        cml.exp,
+		logExp:     cml.logExp,
+		store:      store,
+		hashing:    cml.hashing,
+		sampleSize: cml.sampleSize,
+		sampled:    cml.sampled,
+		resets:     cml.resets,
+
+		banded:        cml.banded,
+		paged:         cml.paged,
+		deterministic: cml.deterministic,
+		carry:         cml.carry,
+		rnd:           cml.rnd,
+		checks:        cml.checks,
+
+		rejectEmptyKeys: cml.rejectEmptyKeys,
+		maxKeyLength:    cml.maxKeyLength,
+
+		doorkeeper: slices.Clone(cml.doorkeeper),
+
+		metadata:        maps.Clone(cml.metadata),
+		maxMetadataSize: cml.maxMetadataSize,
+		metadataCapped:  cml.metadataCapped,
+
+		total:       cml.total,
+		rejected:    cml.rejected,
+		occupied:    cml.occupied,
+		saturated:   cml.saturated,
+		saturatedAt: cml.saturatedAt,
+	}
+}
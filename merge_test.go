@@ -0,0 +1,188 @@
+package cml
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a, _ := NewSketch(10000, 4, 1.00026)
+	b, _ := NewSketch(10000, 4, 1.00026)
+	a.BulkUpdate([]byte("a"), 1000)
+	b.BulkUpdate([]byte("b"), 500)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if count := a.Query([]byte("a")); count < 950 || count > 1050 {
+		t.Errorf("expected ~1000, got %f", count)
+	}
+	if count := a.Query([]byte("b")); count < 475 || count > 525 {
+		t.Errorf("expected ~500, got %f", count)
+	}
+}
+
+func TestMergeMin(t *testing.T) {
+	a, _ := NewSketch(10000, 4, 1.00026)
+	b, _ := NewSketch(10000, 4, 1.00026)
+	a.BulkUpdate([]byte("only-a"), 1000)
+	a.BulkUpdate([]byte("both"), 1000)
+	b.BulkUpdate([]byte("both"), 300)
+
+	both, err := MinOf(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := a.Query([]byte("only-a")); count < 950 {
+		t.Errorf("expected MinOf to leave a untouched, got %f", count)
+	}
+	if count := both.Query([]byte("only-a")); count != 0 {
+		t.Errorf("expected 0, got %f", count)
+	}
+	if count := both.Query([]byte("both")); count < 285 || count > 315 {
+		t.Errorf("expected ~300, got %f", count)
+	}
+
+	if err := a.MergeMin(b); err != nil {
+		t.Fatal(err)
+	}
+	if count := a.Query([]byte("both")); count != both.Query([]byte("both")) {
+		t.Errorf("expected MergeMin to match MinOf, got %f", count)
+	}
+}
+
+func TestMergeIncompatible(t *testing.T) {
+	a, _ := NewSketch(100, 4, 1.00026)
+	for _, b := range []*Sketch{
+		{w: 200, d: 4, exp: 1.00026},
+		{w: 100, d: 3, exp: 1.00026},
+		{w: 100, d: 4, exp: 1.005},
+	} {
+		if err := a.Merge(b); err == nil {
+			t.Errorf("expected Merge error for %d/%d/%f", b.w, b.d, b.exp)
+		}
+		if err := a.MergeMin(b); err == nil {
+			t.Errorf("expected MergeMin error for %d/%d/%f", b.w, b.d, b.exp)
+		}
+	}
+}
+
+func TestMergeWithDecay(t *testing.T) {
+	parent, _ := NewSketch(10000, 4, 1.00026)
+	parent.BulkUpdate([]byte("old"), 1000)
+
+	for cycle := 0; cycle < 5; cycle++ {
+		child, _ := NewSketch(10000, 4, 1.00026)
+		child.BulkUpdate([]byte("steady"), 1000)
+		if err := parent.MergeWithDecay(child, 0.8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count := parent.Query([]byte("old")); count < 300 || count > 360 {
+		t.Errorf("expected old to decay to ~328, got %f", count)
+	}
+	if count := parent.Query([]byte("steady")); count < 950 || count > 1050 {
+		t.Errorf("expected steady to stay at ~1000, got %f", count)
+	}
+}
+
+func TestMergeWithDecayNoDecay(t *testing.T) {
+	a, _ := NewSketch(1000, 4, 1.00026)
+	b, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 2000; i++ {
+		a.Update([]byte{byte(i)})
+		b.Update([]byte{byte(i >> 3)})
+	}
+	merged, decayed := a.Clone(), a.Clone()
+	merged.Merge(b)
+	if err := decayed.MergeWithDecay(b, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i := range merged.store {
+		for j := range merged.store[i] {
+			if merged.store[i][j] != decayed.store[i][j] {
+				t.Fatalf("expected selfWeight 1 to match Merge at %d/%d", i, j)
+			}
+		}
+	}
+
+	for _, w := range []float64{0, -1, 1.5} {
+		if err := a.MergeWithDecay(b, w); err == nil {
+			t.Errorf("expected error for selfWeight %f", w)
+		}
+	}
+}
+
+func TestMergeExpTolerance(t *testing.T) {
+	a, _ := NewSketch(1000, 4, 1.00026)
+	b, _ := NewSketch(1000, 4, math.Nextafter(1.00026, 2))
+	b.BulkUpdate([]byte("a"), 100)
+	if err := a.Merge(b); err != nil {
+		t.Errorf("expected exps 1 ULP apart to merge, got %v", err)
+	}
+	if a.exp != 1.00026 || a.logExp != math.Log1p(a.exp-1) {
+		t.Error("expected the receiver to keep its own exp")
+	}
+
+	c, _ := NewSketch(1000, 4, 1.00026*1.01)
+	if err := a.Merge(c); err == nil || !strings.Contains(err.Error(), "exp") {
+		t.Errorf("expected an exp mismatch error, got %v", err)
+	}
+	d, _ := NewSketch(1000, 5, 1.00026)
+	if err := a.Merge(d); err == nil || !strings.Contains(err.Error(), "depth ours=4 theirs=5") {
+		t.Errorf("expected a depth mismatch error, got %v", err)
+	}
+}
+
+func TestMismatchError(t *testing.T) {
+	base, _ := NewSketch(1000, 4, 1.00026)
+	for _, tc := range []struct {
+		field        string
+		other        func() *Sketch
+		ours, theirs any
+	}{
+		{"width", func() *Sketch { sk, _ := NewSketch(500, 4, 1.00026); return sk }, uint(1000), uint(500)},
+		{"depth", func() *Sketch { sk, _ := NewSketch(1000, 3, 1.00026); return sk }, uint(4), uint(3)},
+		{"exp", func() *Sketch { sk, _ := NewSketch(1000, 4, 1.08); return sk }, 1.00026, 1.08},
+		{"hashing", func() *Sketch { sk, _ := NewSketch(1000, 4, 1.00026, WithFNVHash()); return sk }, hashFarm64, hashFNV64},
+		{"flags", func() *Sketch { sk, _ := NewSketch(1000, 4, 1.00026, WithDeterministic(true)); return sk }, byte(0), flagDeterministic},
+	} {
+		other := tc.other()
+		for name, err := range map[string]error{
+			"Merge":          base.Merge(other),
+			"MergeMin":       base.MergeMin(other),
+			"MergeWithDecay": base.MergeWithDecay(other, 0.5),
+		} {
+			var mismatch *MismatchError
+			if !errors.As(err, &mismatch) {
+				t.Errorf("%s/%s: expected a MismatchError, got %v", tc.field, name, err)
+				continue
+			}
+			if mismatch.Field != tc.field || mismatch.Ours != tc.ours || mismatch.Theirs != tc.theirs {
+				t.Errorf("%s/%s: expected ours=%v theirs=%v, got %+v", tc.field, name, tc.ours, tc.theirs, mismatch)
+			}
+		}
+	}
+
+	narrow, _ := NewSketch(131072, 1, 1.00026)
+	wide, _ := NewSketch(262144, 1, 1.00026)
+	if err := wide.Merge(narrow); err.Error() != "sketch mismatch: width ours=262144 theirs=131072" {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	fnv, _ := NewSketch(1000, 4, 1.00026, WithFNVHash())
+	_, err := QueryAcross([]byte("a"), CombineMax, base, fnv)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) || mismatch.Field != "hashing" || err.Error() != "sketch mismatch: hashing ours=farm64 theirs=fnv64" {
+		t.Errorf("expected a hashing MismatchError from QueryAcross, got %v", err)
+	}
+
+	fa, _ := NewFloatSketch(100, 4, 1.08)
+	fb, _ := NewFloatSketch(100, 2, 1.08)
+	if err := fa.Merge(fb); !errors.As(err, &mismatch) || mismatch.Field != "depth" {
+		t.Errorf("expected a depth MismatchError from FloatSketch.Merge, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package cml
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// mergeChunkSize is how many bytes of registers MergeFrom and ReadFrom read and
+// WriteTo writes at a time.
+const mergeChunkSize = 64 << 10
+
+var (
+	// ErrTruncated is returned by MergeFrom when the stream ends inside the encoding.
+	ErrTruncated = errors.New("sketch stream truncated")
+	// ErrTrailingData is returned by MergeFrom when the stream continues past the encoding.
+	ErrTrailingData = errors.New("trailing data after sketch stream")
+)
+
+/*
+MergeFrom merges the MarshalBinary encoding read from r into the sketch like Merge, reading
+the registers in fixed-size chunks instead of decoding the whole sketch first. It returns the
+number of bytes consumed. r must hold exactly one encoding: a stream ending early fails with
+ErrTruncated and one continuing past it with ErrTrailingData. The update counters reported by
+Stats are not part of the encoding, so unlike Merge it leaves them as they are.
+
+The registers read before a failure stay merged. Merging only raises registers, so the sketch
+remains valid, but it then holds part of the other sketch.
+*/
+func (cml *Sketch) MergeFrom(r io.Reader) (int64, error) {
+	if err := cml.writable(); err != nil {
+		return 0, err
+	}
+	var hdr [headerSize]byte
+	n, err := io.ReadFull(r, hdr[:])
+	consumed := int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return consumed, ErrTruncated
+	} else if err != nil {
+		return consumed, err
+	}
+	order, err := sketchHeader(hdr[:])
+	if err != nil {
+		return consumed, err
+	}
+	if err := cml.compatibleWith(
+		uint(order.Uint64(hdr[8:])),
+		uint(order.Uint64(hdr[16:])),
+		math.Float64frombits(order.Uint64(hdr[24:])),
+		hashing(hdr[1]),
+		hdr[2]&^(flagMetadata|flagDoorkeeper),
+	); err != nil {
+		return consumed, err
+	}
+	words, err := doorkeeperHeader(hdr[:])
+	if err != nil {
+		return consumed, err
+	}
+	if err := cml.compatibleDoorkeeper(words); err != nil {
+		return consumed, err
+	}
+	if hdr[2]&flagMetadata != 0 {
+		// The receiver keeps its own metadata, so the block is skipped.
+		var size [4]byte
+		n, err := io.ReadFull(r, size[:])
+		consumed += int64(n)
+		if err == nil {
+			var m int64
+			m, err = io.CopyN(io.Discard, r, int64(order.Uint32(size[:])))
+			consumed += m
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return consumed, ErrTruncated
+		} else if err != nil {
+			return consumed, err
+		}
+	}
+	if words > 0 {
+		buf := make([]byte, 8*words)
+		n, err := io.ReadFull(r, buf)
+		consumed += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return consumed, ErrTruncated
+		} else if err != nil {
+			return consumed, err
+		}
+		for i := range cml.doorkeeper {
+			cml.doorkeeper[i] |= order.Uint64(buf[8*i:])
+		}
+	}
+
+	defer cml.recount()
+	buf := make([]byte, min(mergeChunkSize, 2*cml.w*cml.d))
+	size := cml.chunkLen(0)
+	for pos, total := uint(0), cml.w*cml.d; pos < total; {
+		chunk := buf[:min(uint(len(buf)), 2*(total-pos))]
+		n, err := io.ReadFull(r, chunk)
+		consumed += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrTruncated
+		}
+		for off := 0; off+1 < n; off, pos = off+2, pos+1 {
+			c := order.Uint16(chunk[off:])
+			if c == 0 {
+				continue
+			}
+			if r := &cml.chunk(int(pos / size))[pos%size]; c > *r {
+				*r = c
+			}
+		}
+		if err != nil {
+			return consumed, err
+		}
+	}
+
+	var extra [1]byte
+	n, err = io.ReadFull(r, extra[:])
+	consumed += int64(n)
+	if n > 0 {
+		return consumed, ErrTrailingData
+	}
+	if err != io.EOF {
+		return consumed, err
+	}
+	return consumed, nil
+}
@@ -0,0 +1,90 @@
+package cml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMergeFrom(t *testing.T) {
+	a, _ := NewSketch(50000, 4, 1.00026)
+	b, _ := NewSketch(50000, 4, 1.00026)
+	for i := 0; i < 1000; i++ {
+		a.BulkUpdate([]byte(fmt.Sprint("a", i)), uint(i%10+1))
+		b.BulkUpdate([]byte(fmt.Sprint("b", i)), uint(i%10+1))
+	}
+	data, _ := b.MarshalBinary()
+	expected := a.Clone()
+	expected.Merge(b)
+
+	for name, wrap := range map[string]func(io.Reader) io.Reader{
+		"plain":   func(r io.Reader) io.Reader { return r },
+		"onebyte": iotest.OneByteReader,
+		"half":    iotest.HalfReader,
+		"dataerr": iotest.DataErrReader,
+	} {
+		sk := a.Clone()
+		n, err := sk.MergeFrom(wrap(bytes.NewReader(data)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if n != int64(len(data)) {
+			t.Errorf("%s: expected %d bytes consumed, got %d", name, len(data), n)
+		}
+		got, _ := sk.MarshalBinary()
+		want, _ := expected.MarshalBinary()
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: expected the same registers as Merge", name)
+		}
+		if got, want := sk.Stats(), expected.Stats(); got.FillRatePct != want.FillRatePct || got.TotalUpdates != a.Stats().TotalUpdates {
+			t.Errorf("%s: expected recounted fill and unchanged totals, got %+v", name, got)
+		}
+	}
+}
+
+func TestMergeFromInvalid(t *testing.T) {
+	sk, _ := NewSketch(50000, 4, 1.00026)
+	other, _ := NewSketch(50000, 4, 1.00026)
+	other.BulkUpdate([]byte("a"), 100)
+	data, _ := other.MarshalBinary()
+
+	for name, tc := range map[string]struct {
+		data []byte
+		err  error
+	}{
+		"short header":    {data[:10], ErrTruncated},
+		"short registers": {data[:len(data)-3], ErrTruncated},
+		"trailing data":   {append(append([]byte(nil), data...), 0), ErrTrailingData},
+	} {
+		n, err := sk.Clone().MergeFrom(bytes.NewReader(tc.data))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+		if n > int64(len(tc.data)) {
+			t.Errorf("%s: expected at most %d bytes consumed, got %d", name, len(tc.data), n)
+		}
+	}
+
+	partial := sk.Clone()
+	if _, err := partial.MergeFrom(iotest.TimeoutReader(bytes.NewReader(data))); !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("expected the reader's error, got %v", err)
+	}
+	if partial.Query([]byte("b")) != 0 || partial.Stats().FillRatePct > other.Stats().FillRatePct {
+		t.Error("expected a partially merged sketch to stay valid")
+	}
+
+	wide, _ := NewSketch(50001, 4, 1.00026)
+	data, _ = wide.MarshalBinary()
+	if _, err := sk.MergeFrom(bytes.NewReader(data)); err == nil {
+		t.Error("expected error for different dimensions")
+	}
+
+	var zero Sketch
+	if _, err := zero.MergeFrom(bytes.NewReader(data)); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized, got %v", err)
+	}
+}
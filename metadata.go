@@ -0,0 +1,159 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+)
+
+/*
+DefaultMaxMetadataSize is the encoded size of metadata a sketch accepts unless configured
+otherwise with WithMaxMetadataSize
+*/
+const DefaultMaxMetadataSize = 4 << 10
+
+/*
+ErrMetadataTooLarge is returned when metadata would exceed the sketch's size cap
+*/
+var ErrMetadataTooLarge = errors.New("sketch metadata too large")
+
+/*
+WithMaxMetadataSize caps the encoded size of the sketch's metadata, both when set and when
+decoded, at n bytes. Each entry takes 4 bytes plus the length of its key and value.
+*/
+func WithMaxMetadataSize(n int) Option {
+	return func(cml *Sketch) error {
+		if n < 0 || n > math.MaxUint32 {
+			return errors.New("max metadata size needs to be between 0 and 2^32-1")
+		}
+		cml.maxMetadataSize = n
+		cml.metadataCapped = true
+		return nil
+	}
+}
+
+/*
+SetMetadata sets a metadata entry, such as a tenant or the window a sketch covers. Metadata is
+written by MarshalBinary and restored by UnmarshalBinary; merging leaves the receiver's as it is.
+An empty value deletes the entry.
+*/
+func (cml *Sketch) SetMetadata(key, value string) error {
+	if value == "" {
+		delete(cml.metadata, key)
+		return nil
+	}
+	if len(key) > math.MaxUint16 || len(value) > math.MaxUint16 {
+		return ErrMetadataTooLarge
+	}
+	size := cml.metadataSize() + metadataEntrySize(key, value)
+	if old, ok := cml.metadata[key]; ok {
+		size -= metadataEntrySize(key, old)
+	}
+	if size > cml.metadataLimit() {
+		return ErrMetadataTooLarge
+	}
+	if cml.metadata == nil {
+		cml.metadata = make(map[string]string)
+	}
+	cml.metadata[key] = value
+	return nil
+}
+
+/*
+Metadata returns a copy of the sketch's metadata entries
+*/
+func (cml *Sketch) Metadata() map[string]string {
+	return maps.Clone(cml.metadata)
+}
+
+// metadataLimit returns the sketch's cap on the encoded metadata size.
+func (cml *Sketch) metadataLimit() int {
+	if cml.metadataCapped {
+		return cml.maxMetadataSize
+	}
+	return DefaultMaxMetadataSize
+}
+
+func metadataEntrySize(key, value string) int {
+	return 4 + len(key) + len(value)
+}
+
+// metadataSize returns the size of the encoded entries, without the block's length prefix.
+func (cml *Sketch) metadataSize() int {
+	n := 0
+	for k, v := range cml.metadata {
+		n += metadataEntrySize(k, v)
+	}
+	return n
+}
+
+// appendMetadata appends the metadata block: a 4-byte length followed by, for every entry in
+// key order, the 2-byte length of the key, the key, the 2-byte length of the value and the value.
+func (cml *Sketch) appendMetadata(b []byte, order binary.AppendByteOrder) []byte {
+	b = order.AppendUint32(b, uint32(cml.metadataSize()))
+	for _, k := range slices.Sorted(maps.Keys(cml.metadata)) {
+		v := cml.metadata[k]
+		b = order.AppendUint16(b, uint16(len(k)))
+		b = append(b, k...)
+		b = order.AppendUint16(b, uint16(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+// checkMetadata checks that md fits the encoding and the size cap limit.
+func checkMetadata(md map[string]string, limit int) error {
+	size := 0
+	for k, v := range md {
+		if len(k) > math.MaxUint16 || len(v) > math.MaxUint16 {
+			return ErrMetadataTooLarge
+		}
+		size += metadataEntrySize(k, v)
+	}
+	if size > limit {
+		return ErrMetadataTooLarge
+	}
+	return nil
+}
+
+// parseMetadata decodes the metadata block at the start of b, rejecting blocks larger
+// than limit, and returns the entries and the rest of b.
+func parseMetadata(b []byte, order binary.ByteOrder, limit int) (map[string]string, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("sketch metadata truncated")
+	}
+	size := uint64(order.Uint32(b))
+	if size > uint64(limit) {
+		return nil, nil, ErrMetadataTooLarge
+	}
+	if size > uint64(len(b)-4) {
+		return nil, nil, errors.New("sketch metadata truncated")
+	}
+	block, rest := b[4:4+size], b[4+size:]
+	md := make(map[string]string)
+	for len(block) > 0 {
+		var k, v string
+		var ok bool
+		if k, block, ok = metadataString(block, order); !ok {
+			return nil, nil, errors.New("malformed sketch metadata")
+		}
+		if v, block, ok = metadataString(block, order); !ok {
+			return nil, nil, errors.New("malformed sketch metadata")
+		}
+		md[k] = v
+	}
+	return md, rest, nil
+}
+
+func metadataString(b []byte, order binary.ByteOrder) (string, []byte, bool) {
+	if len(b) < 2 {
+		return "", nil, false
+	}
+	n := int(order.Uint16(b))
+	if len(b)-2 < n {
+		return "", nil, false
+	}
+	return string(b[2 : 2+n]), b[2+n:], true
+}
@@ -0,0 +1,115 @@
+package cml
+
+import (
+	"bytes"
+	"maps"
+	"testing"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 10)
+	entries := map[string]string{
+		"tenant":   "acme",
+		"start":    "2026-10-01T00:00:00Z",
+		"end":      "2026-10-02T00:00:00Z",
+		"producer": "v1.4.2",
+	}
+	for k, v := range entries {
+		if err := sk.SetMetadata(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, marshal := range []func() ([]byte, error){sk.MarshalBinary, sk.MarshalBinaryBigEndian} {
+		data, _ := marshal()
+		restored := &Sketch{}
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(restored.Metadata(), entries) {
+			t.Errorf("expected %v, got %v", entries, restored.Metadata())
+		}
+		if restored.Query([]byte("a")) != sk.Query([]byte("a")) {
+			t.Error("expected registers to survive alongside metadata")
+		}
+
+		// Merging from the encoding keeps the receiver's metadata.
+		target, _ := NewSketch(100, 3, 1.00026)
+		target.SetMetadata("tenant", "other")
+		if _, err := target.MergeFrom(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		if md := target.Metadata(); len(md) != 1 || md["tenant"] != "other" {
+			t.Errorf("expected MergeFrom to keep the receiver's metadata, got %v", md)
+		}
+		if target.Query([]byte("a")) != sk.Query([]byte("a")) {
+			t.Error("expected MergeFrom to merge the registers past the metadata")
+		}
+	}
+
+	other := sk.Clone()
+	other.SetMetadata("tenant", "other")
+	if err := sk.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	if sk.Metadata()["tenant"] != "acme" {
+		t.Error("expected Merge to keep the receiver's metadata")
+	}
+
+	sk.Metadata()["tenant"] = "changed"
+	if sk.Metadata()["tenant"] != "acme" {
+		t.Error("expected Metadata to return a copy")
+	}
+	sk.SetMetadata("tenant", "")
+	if _, ok := sk.Metadata()["tenant"]; ok {
+		t.Error("expected an empty value to delete the entry")
+	}
+}
+
+func TestMetadataSizeCap(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026, WithMaxMetadataSize(20))
+	if err := sk.SetMetadata("tenant", "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sk.SetMetadata("window", "2026-10"); err != ErrMetadataTooLarge {
+		t.Errorf("expected ErrMetadataTooLarge, got %v", err)
+	}
+	if err := sk.SetMetadata("tenant", "acme-corp"); err != nil {
+		t.Errorf("expected replacing an entry to count only the new value, got %v", err)
+	}
+
+	big, _ := NewSketch(100, 3, 1.00026)
+	big.SetMetadata("tenant", "a long tenant name")
+	data, _ := big.MarshalBinary()
+	if err := sk.UnmarshalBinary(data); err != ErrMetadataTooLarge {
+		t.Errorf("expected decoding to enforce the cap, got %v", err)
+	}
+
+	if _, err := NewSketch(100, 3, 1.00026, WithMaxMetadataSize(-1)); err == nil {
+		t.Error("expected error for a negative cap")
+	}
+}
+
+func TestMetadataLegacy(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 10)
+	data, _ := sk.MarshalBinary()
+	if len(data) != headerSize+2*100*3 || data[2]&flagMetadata != 0 {
+		t.Fatal("expected a sketch without metadata to encode as before")
+	}
+	restored := &Sketch{}
+	restored.SetMetadata("stale", "entry")
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if md := restored.Metadata(); len(md) != 0 {
+		t.Errorf("expected no metadata, got %v", md)
+	}
+
+	sk.SetMetadata("tenant", "acme")
+	data, _ = sk.MarshalBinary()
+	if err := restored.UnmarshalBinary(data[:headerSize+6]); err == nil {
+		t.Error("expected error for truncated metadata")
+	}
+}
@@ -0,0 +1,101 @@
+package cml
+
+import (
+	"errors"
+	"io"
+)
+
+/*
+Option configures optional behaviour of a Sketch at construction time
+*/
+type Option func(*Sketch) error
+
+/*
+WithWAL appends a write-ahead log record to w for every Update and BulkUpdate
+*/
+func WithWAL(w io.Writer) Option {
+	return func(cml *Sketch) error {
+		if w == nil {
+			return errors.New("WAL writer must not be nil")
+		}
+		cml.wal = w
+		return nil
+	}
+}
+
+/*
+WithSampleSize enables TinyLFU-style aging: once W increments have been counted every
+register is halved (in value space) and the running total is halved, so recent traffic
+dominates the estimates
+*/
+func WithSampleSize(W uint64) Option {
+	return func(cml *Sketch) error {
+		if W < 2 {
+			return errors.New("sample size needs to be >= 2")
+		}
+		cml.sampleSize = W
+		return nil
+	}
+}
+
+/*
+WithIncrementHook calls fn whenever an update raises the key's registers, with the key's 64-bit
+hash, so the key itself is not retained, and the new register level. Increments declined by the
+probabilistic decision do not call it. Updates fn makes to the sketch do not call it again.
+*/
+func WithIncrementHook(fn func(keyHash, newRegister uint64)) Option {
+	return func(cml *Sketch) error {
+		cml.incrementHook = fn
+		return nil
+	}
+}
+
+/*
+WithSeed seeds the sketch's random number generator, which decides the probabilistic increments.
+Every sketch has a generator of its own, seeded from its dimensions unless given a seed, so
+equally seeded sketches fed the same updates end up with the same registers.
+*/
+func WithSeed(seed uint64) Option {
+	return func(cml *Sketch) error {
+		cml.rnd = newRNG(seed)
+		return nil
+	}
+}
+
+/*
+WithDeterministic replaces the probabilistic increment with a running sum of the expected number
+of register steps, taking a step whenever it adds up to a whole one. Updates then depend only on
+the sequence of updates, reproducibly across runs. The sum is not encoded, so a decoded sketch
+starts it afresh. Sketches built with and without it cannot be merged.
+*/
+func WithDeterministic(on bool) Option {
+	return func(cml *Sketch) error {
+		cml.deterministic = on
+		return nil
+	}
+}
+
+/*
+WithHash128 derives columns from a 128-bit farmhash in full 64-bit arithmetic instead of
+splitting a 64-bit one, keeping probes uniform for widths approaching or beyond 2^32.
+Sketches built with and without it cannot be merged.
+*/
+func WithHash128() Option {
+	return func(cml *Sketch) error {
+		cml.hashing |= hashWide
+		return nil
+	}
+}
+
+/*
+WithFNVHash hashes keys with FNV-1a, as defined by the standard library's hash/fnv,
+instead of farmhash, so key placement does not depend on a third-party hash. It is
+slower than farmhash on long keys. It combines with WithHash128.
+Sketches built with and without it cannot be merged.
+*/
+func WithFNVHash() Option {
+	return func(cml *Sketch) error {
+		cml.hashing |= hashFNV
+		return nil
+	}
+}
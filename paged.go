@@ -0,0 +1,91 @@
+package cml
+
+// pageRegisters is the number of registers in a page of a paged store.
+const pageRegisters = 1 << 12
+
+/*
+WithPagedStore allocates the registers in pages of 4096, each on the first write to it, so a
+sketch sized for an enormous key space only takes memory for the parts of it that are used.
+Reads of unallocated pages see zeroes, and Stats reports the allocated pages as StoreBytes.
+The encoding is the same dense one, marking the sketch as paged so decoding allocates only
+the pages with counts. Paged and unpaged sketches cannot be merged with each other.
+*/
+func WithPagedStore() Option {
+	return func(cml *Sketch) error {
+		cml.paged = true
+		return nil
+	}
+}
+
+// emptyStore returns a zeroed store for the sketch's dimensions and paging.
+func (cml *Sketch) emptyStore() [][]uint16 {
+	if cml.paged {
+		return make([][]uint16, (cml.w*cml.d+pageRegisters-1)/pageRegisters)
+	}
+	return newStore(cml.w, cml.d)
+}
+
+// chunkLen returns the number of registers in chunk i of the store, which is
+// nil for an unallocated page.
+func (cml *Sketch) chunkLen(i int) uint {
+	if !cml.paged {
+		return cml.w
+	}
+	return min(pageRegisters, cml.w*cml.d-uint(i)*pageRegisters)
+}
+
+// chunk returns chunk i of the store, allocating it if it is an unallocated page.
+func (cml *Sketch) chunk(i int) []uint16 {
+	if cml.store[i] == nil {
+		cml.store[i] = make([]uint16, cml.chunkLen(i))
+	}
+	return cml.store[i]
+}
+
+// at returns the register at column col of row row without allocating.
+func (cml *Sketch) at(row, col uint) uint16 {
+	if cml.banded || cml.paged {
+		return cml.mappedAt(row, col)
+	}
+	return cml.store[row][col]
+}
+
+// mappedAt is at for the layouts mappedCell serves.
+func (cml *Sketch) mappedAt(row, col uint) uint16 {
+	if !cml.paged {
+		return *cml.mappedCell(row, col)
+	}
+	k := cml.index(row, col)
+	if page := cml.store[k/pageRegisters]; page != nil {
+		return page[k%pageRegisters]
+	}
+	return 0
+}
+
+// index returns the position of the register at column col of row row in the
+// registers laid out one after the other.
+func (cml *Sketch) index(row, col uint) uint {
+	if cml.banded {
+		return col*cml.d + row
+	}
+	return row*cml.w + col
+}
+
+// residentBytes returns the memory the store's registers take.
+func (cml *Sketch) residentBytes() uint64 {
+	var n uint64
+	for _, chunk := range cml.store {
+		n += 2 * uint64(len(chunk))
+	}
+	return n
+}
+
+// zeroBytes reports whether b holds only zeroes.
+func zeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
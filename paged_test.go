@@ -0,0 +1,129 @@
+package cml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestPagedStoreHuge(t *testing.T) {
+	const w, d, keys = 1 << 26, 4, 2000
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	sk, err := NewSketch(w, d, 1.00026, WithDeterministic(true), WithPagedStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < keys; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%50+1))
+	}
+	for i := 0; i < keys; i++ {
+		want := float64(i%50 + 1)
+		if got := sk.Query([]byte(fmt.Sprint(i))); math.Abs(got-want) > 1.5 {
+			t.Errorf("expected %f for %d, got %f", want, i, got)
+		}
+	}
+	if got := sk.Query([]byte("unseen")); got != 0 {
+		t.Errorf("expected 0 for an unseen key, got %f", got)
+	}
+
+	pages := 0
+	for _, page := range sk.store {
+		if page != nil {
+			pages++
+		}
+	}
+	if pages == 0 || pages > keys*d {
+		t.Errorf("expected at most %d pages, got %d", keys*d, pages)
+	}
+	if got, want := sk.Stats().StoreBytes, uint64(2*pageRegisters*pages); got != want {
+		t.Errorf("expected %d store bytes, got %d", want, got)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	// The store slice itself takes 24 bytes per page, allocated or not.
+	limit := sk.Stats().StoreBytes + 24*uint64(len(sk.store)) + 8<<20
+	if grown := after.HeapAlloc - min(after.HeapAlloc, before.HeapAlloc); grown > limit {
+		t.Errorf("expected the heap to grow by at most %d bytes, grew by %d", limit, grown)
+	}
+	runtime.KeepAlive(sk)
+}
+
+func TestPagedStore(t *testing.T) {
+	dense, _ := NewSketch(10000, 4, 1.00026, WithDeterministic(true))
+	paged, _ := NewSketch(10000, 4, 1.00026, WithDeterministic(true), WithPagedStore())
+	other, _ := NewSketch(10000, 4, 1.00026, WithDeterministic(true), WithPagedStore())
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprint(i))
+		dense.BulkUpdate(key, uint(i+1))
+		paged.BulkUpdate(key, uint(i+1))
+	}
+	other.BulkUpdate([]byte("other"), 7)
+	want := other.Query([]byte("other"))
+
+	denseData, _ := dense.MarshalBinary()
+	data, _ := paged.MarshalBinary()
+	if data[2]&flagPaged == 0 {
+		t.Error("expected the paging in the header")
+	}
+	if !bytes.Equal(data[headerSize:], denseData[headerSize:]) {
+		t.Error("expected the registers to be encoded like a dense store")
+	}
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := paged.Stats().StoreBytes, restored.Stats().StoreBytes; a != b {
+		t.Errorf("expected %d store bytes after a round trip, got %d", a, b)
+	}
+	rebased, err := Rebase(paged, 1.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rebased.Query([]byte("19")); got < 19 || got > 21 {
+		t.Errorf("expected about 20 after a rebase, got %f", got)
+	}
+	like := NewLike(paged)
+	if err := like.Merge(paged); err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range []*Sketch{restored, paged.Clone(), like} {
+		for i := 0; i < 20; i++ {
+			key := []byte(fmt.Sprint(i))
+			if a, b := dense.Query(key), sk.Query(key); a != b {
+				t.Errorf("expected %f for %s, got %f", a, key, b)
+			}
+		}
+	}
+
+	if err := dense.Merge(paged); err == nil {
+		t.Error("expected paged and dense sketches not to merge")
+	}
+	if err := paged.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := paged.MergeFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got := paged.Query([]byte("other")); got < want {
+		t.Errorf("expected at least %f after merging, got %f", want, got)
+	}
+	if err := other.MergeMin(paged); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Query([]byte("other")); got < want {
+		t.Errorf("expected at least %f after a minimum merge, got %f", want, got)
+	}
+	if got := other.Query([]byte("3")); got != 0 {
+		t.Errorf("expected 0 after a minimum merge, got %f", got)
+	}
+
+	paged.Reset()
+	if got := paged.Stats().StoreBytes; got != 0 {
+		t.Errorf("expected no store bytes after a reset, got %d", got)
+	}
+}
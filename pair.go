@@ -0,0 +1,70 @@
+package cml
+
+import "bytes"
+
+/*
+UpdatePair increases the count of the pair (a, b) by one, for co-occurrence counting. The
+two keys are hashed separately and the hashes combined, so unlike concatenating them the
+pairs ("ab", "c") and ("a", "bc") are distinct keys. The pair is ordered: (a, b) and (b, a)
+are counted apart; see UpdatePairUnordered. Both keys are subject to the key validation.
+*/
+func (cml *Sketch) UpdatePair(a, b []byte) bool {
+	if cml.writable() != nil || cml.CheckKey(a) != nil || cml.CheckKey(b) != nil {
+		return false
+	}
+	cml.updateResult(cml.pairHash(a, b), 1)
+	return true
+}
+
+/*
+QueryPair returns the estimated count of the pair (a, b) counted with UpdatePair
+*/
+func (cml *Sketch) QueryPair(a, b []byte) float64 {
+	if cml.checks {
+		cml.beginRead()
+		defer cml.endRead()
+	}
+	if cml.CheckKey(a) != nil || cml.CheckKey(b) != nil {
+		return 0
+	}
+	return cml.estimate(cml.pairHash(a, b))
+}
+
+/*
+UpdatePairUnordered is UpdatePair with the pair put in canonical order first, so (a, b) and
+(b, a) count as the same pair
+*/
+func (cml *Sketch) UpdatePairUnordered(a, b []byte) bool {
+	a, b = canonicalPair(a, b)
+	return cml.UpdatePair(a, b)
+}
+
+/*
+QueryPairUnordered returns the estimated count of the pair counted with UpdatePairUnordered
+*/
+func (cml *Sketch) QueryPairUnordered(a, b []byte) float64 {
+	a, b = canonicalPair(a, b)
+	return cml.QueryPair(a, b)
+}
+
+func canonicalPair(a, b []byte) ([]byte, []byte) {
+	if bytes.Compare(a, b) > 0 {
+		return b, a
+	}
+	return a, b
+}
+
+// pairHash combines the hashes of both keys.
+func (cml *Sketch) pairHash(a, b []byte) keyHash {
+	return combineHash(cml.hash(a), cml.hash(b))
+}
+
+// combineHash derives the hash of a compound key from the hashes of its parts.
+// The second one is mixed before the first is folded in, so the combination
+// depends on the order.
+func combineHash(ha, hb keyHash) keyHash {
+	return keyHash{
+		lo: fmix64(ha.lo ^ fmix64(hb.lo+0x9e3779b97f4a7c15)),
+		hi: fmix64(ha.hi ^ fmix64(hb.hi+0xc2b2ae3d27d4eb4f)),
+	}
+}
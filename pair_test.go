@@ -0,0 +1,49 @@
+package cml
+
+import "testing"
+
+func TestUpdatePair(t *testing.T) {
+	sk, _ := NewSketch(10000, 4, 1.00026)
+	for i := 0; i < 100; i++ {
+		sk.UpdatePair([]byte("ab"), []byte("c"))
+	}
+
+	if got := sk.QueryPair([]byte("ab"), []byte("c")); got < 90 || got > 110 {
+		t.Errorf("expected about 100, got %f", got)
+	}
+	for _, pair := range [][2]string{{"a", "bc"}, {"abc", ""}, {"", "abc"}, {"c", "ab"}} {
+		if got := sk.QueryPair([]byte(pair[0]), []byte(pair[1])); got != 0 {
+			t.Errorf("expected %q to be distinct from (\"ab\", \"c\"), got %f", pair, got)
+		}
+	}
+	if got := sk.Query([]byte("abc")); got != 0 {
+		t.Errorf("expected the pair to be distinct from the concatenated key, got %f", got)
+	}
+
+	for i := 0; i < 50; i++ {
+		sk.UpdatePairUnordered([]byte("x"), []byte("y"))
+		sk.UpdatePairUnordered([]byte("y"), []byte("x"))
+	}
+	if a, b := sk.QueryPairUnordered([]byte("x"), []byte("y")), sk.QueryPairUnordered([]byte("y"), []byte("x")); a != b || a < 90 {
+		t.Errorf("expected both orders to count as one pair, got %f and %f", a, b)
+	}
+
+	strict, _ := NewSketch(100, 2, 1.00026, WithRejectEmptyKeys())
+	if strict.UpdatePair([]byte("a"), nil) {
+		t.Error("expected the key validation to apply to both keys")
+	}
+}
+
+func TestUpdatePairAllocs(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	a, b := []byte("user"), []byte("item")
+	for name, f := range map[string]func(){
+		"QueryPair":           func() { sk.QueryPair(a, b) },
+		"QueryPairUnordered":  func() { sk.QueryPairUnordered(a, b) },
+		"UpdatePairUnordered": func() { sk.UpdatePairUnordered(a, b) },
+	} {
+		if allocs := testing.AllocsPerRun(100, f); allocs != 0 {
+			t.Errorf("%s: expected no allocations, got %f", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cml
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+Plan is what a constructor would allocate: the dimensions and exp of the sketch, the bytes its
+registers take, and the Count-Min bounds they give, an error of at most Epsilon times the total
+count with probability at least 1-Delta
+*/
+type Plan struct {
+	W, D       uint
+	Exp        float64
+	StoreBytes uint64
+	Epsilon    float64
+	Delta      float64
+}
+
+func newPlan(w, d uint, exp float64) Plan {
+	return Plan{
+		W:          w,
+		D:          d,
+		Exp:        exp,
+		StoreBytes: 2 * uint64(w) * uint64(d),
+		Epsilon:    math.E / float64(w),
+		Delta:      math.Exp(-float64(d)),
+	}
+}
+
+/*
+PlanForCapacity returns the Plan NewForCapacity16 builds its sketch from, without allocating it
+*/
+func PlanForCapacity(capacity uint64, e float64) (Plan, error) {
+	m, k, err := capacityDimensions(capacity, e)
+	if err != nil {
+		return Plan{}, err
+	}
+	return newPlan(uint(m/k), uint(k), 1.00026), nil
+}
+
+/*
+PlanForEpsilonDelta returns the Plan NewSketchForEpsilonDelta builds its sketch from, without allocating it
+*/
+func PlanForEpsilonDelta(epsilon, delta float64) (Plan, error) {
+	if !(epsilon > 0 && epsilon < 1) {
+		return Plan{}, errors.New("epsilon needs to be > 0 and < 1")
+	}
+	if !(delta > 0 && delta < 1) {
+		return Plan{}, errors.New("delta needs to be > 0 and < 1")
+	}
+	return newPlan(
+		uint(math.Ceil(math.E/epsilon)),
+		uint(math.Ceil(math.Log(1/delta))),
+		1.00026,
+	), nil
+}
@@ -0,0 +1,56 @@
+package cml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlanForCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		capacity uint64
+		e        float64
+	}{
+		{1000, 0.01},
+		{10000000, 0.001},
+		{5000000, 0.05},
+	} {
+		plan, err := PlanForCapacity(tc.capacity, tc.e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sk, _ := NewForCapacity16(tc.capacity, tc.e)
+		if sk.w != plan.W || sk.d != plan.D || sk.exp != plan.Exp {
+			t.Errorf("%d/%v: expected %dx%d exp %v, planned %+v", tc.capacity, tc.e, sk.w, sk.d, sk.exp, plan)
+		}
+		if stored := uint64(2 * len(sk.store[0]) * len(sk.store)); plan.StoreBytes != stored || sk.Stats().StoreBytes != stored {
+			t.Errorf("%d/%v: expected %d store bytes, planned %d", tc.capacity, tc.e, stored, plan.StoreBytes)
+		}
+	}
+
+	if _, err := PlanForCapacity(1000, 2); err == nil {
+		t.Error("expected error for invalid e")
+	}
+}
+
+func TestPlanForEpsilonDelta(t *testing.T) {
+	plan, err := PlanForEpsilonDelta(0.001, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, _ := NewSketchForEpsilonDelta(0.001, 0.01)
+	if sk.w != plan.W || sk.d != plan.D || sk.exp != plan.Exp || plan.StoreBytes != uint64(2*sk.w*sk.d) {
+		t.Errorf("expected %dx%d exp %v, planned %+v", sk.w, sk.d, sk.exp, plan)
+	}
+	if plan.Epsilon > 0.001 || plan.Delta > 0.01 || math.Abs(plan.Epsilon-0.001) > 1e-6 {
+		t.Errorf("expected bounds at least as tight as requested, got %+v", plan)
+	}
+
+	for _, bad := range [][2]float64{{0, 0.01}, {1, 0.01}, {0.01, 0}, {0.01, 1}} {
+		if _, err := PlanForEpsilonDelta(bad[0], bad[1]); err == nil {
+			t.Errorf("expected error for epsilon %v and delta %v", bad[0], bad[1])
+		}
+		if _, err := NewSketchForEpsilonDelta(bad[0], bad[1]); err == nil {
+			t.Errorf("expected NewSketchForEpsilonDelta to reject epsilon %v and delta %v", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cml
+
+import (
+	"errors"
+	"maps"
+	"math"
+)
+
+/*
+Rebase returns a new sketch with the same dimensions as src whose registers encode the
+same values under base newExp, rounded up so no estimate shrinks. Saturated registers
+stay saturated. The result keeps what NewLike keeps, the doorkeeper's bits, the metadata and
+the update statistics; like NewLike, it has no write-ahead log or increment hook attached.
+
+A coarser base (larger newExp) widens the representable range but each register step
+then spans a relative error of about newExp-1, and that quantization is lost for good:
+rebasing back to a finer base cannot recover it. Rebasing to a finer base fails if a
+value no longer fits below the saturated register.
+*/
+func Rebase(src *Sketch, newExp float64) (*Sketch, error) {
+	if !(newExp > 1) || math.IsInf(newExp, 1) {
+		return nil, errors.New("newExp needs to be > 1")
+	}
+	if !src.initialized() {
+		return nil, ErrUninitialized
+	}
+	dst := NewLike(src)
+	dst.exp, dst.logExp = newExp, math.Log1p(newExp-1)
+	copy(dst.doorkeeper, src.doorkeeper)
+	dst.metadata = maps.Clone(src.metadata)
+	dst.maxMetadataSize, dst.metadataCapped = src.maxMetadataSize, src.metadataCapped
+	dst.total, dst.rejected, dst.saturatedAt = src.total, src.rejected, src.saturatedAt
+	for i, row := range src.store {
+		if row == nil {
+			continue
+		}
+		out := dst.chunk(i)
+		for j, c := range row {
+			if c == math.MaxUint16 {
+				out[j] = c
+				continue
+			}
+			r, ok := dst.register(src.value(c))
+			if !ok {
+				return nil, errors.New("register value exceeds the range of newExp")
+			}
+			out[j] = r
+		}
+	}
+	dst.recount()
+	return dst, nil
+}
@@ -0,0 +1,72 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRebase(t *testing.T) {
+	sk, _ := NewSketch(10000, 4, 1.00026)
+	counts := []uint{1, 3, 10, 100, 1000, 10000, 100000}
+	for _, c := range counts {
+		sk.BulkUpdate([]byte(fmt.Sprint(c)), c)
+	}
+
+	for _, exp := range []float64{1.005, 1.0001, 1.05} {
+		rebased, err := Rebase(sk, exp)
+		if err != nil {
+			t.Fatalf("exp=%f: %v", exp, err)
+		}
+		if rebased.w != sk.w || rebased.d != sk.d || rebased.exp != exp {
+			t.Fatalf("exp=%f: expected same dimensions", exp)
+		}
+		for _, c := range counts {
+			key := []byte(fmt.Sprint(c))
+			orig, got := sk.Query(key), rebased.Query(key)
+			if step := orig*(exp-1) + 1; got < orig || got > orig+step {
+				t.Errorf("exp=%f: expected %f within one step (%f), got %f", exp, orig, step, got)
+			}
+		}
+	}
+}
+
+func TestRebaseKeepsSettings(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<10), WithSeed(7), WithRejectEmptyKeys())
+	sk.SetMetadata("tenant", "a")
+	sk.Update([]byte("once"))
+	sk.BulkUpdate([]byte("bulk"), 1000)
+
+	rebased, err := Rebase(sk, 1.005)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rebased.Query([]byte("once")); got != 1 {
+		t.Errorf("expected the doorkept key to estimate 1, got %f", got)
+	}
+	if rebased.Metadata()["tenant"] != "a" || !rebased.rnd.seeded || rebased.rnd.seed != 7 {
+		t.Error("expected the metadata and seed to be kept")
+	}
+	if rebased.CheckKey(nil) == nil {
+		t.Error("expected the key validation to be kept")
+	}
+	if got, expected := rebased.Stats().TotalUpdates, sk.Stats().TotalUpdates; got != expected {
+		t.Errorf("expected %d total updates, got %d", expected, got)
+	}
+	if _, err := Rebase(&Sketch{}, 1.005); err != ErrUninitialized {
+		t.Errorf("expected ErrUninitialized for a zero Sketch, got %v", err)
+	}
+}
+
+func TestRebaseInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 2, 1.05)
+	for _, exp := range []float64{0.5, 1} {
+		if _, err := Rebase(sk, exp); err == nil {
+			t.Errorf("expected error for exp=%f", exp)
+		}
+	}
+
+	sk.store[0][0] = 60000
+	if _, err := Rebase(sk, 1.00026); err == nil {
+		t.Error("expected error when the value exceeds the finer range")
+	}
+}
@@ -0,0 +1,58 @@
+package cml
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+ExportRedisCMS returns the sketch as a plain Count-Min matrix: its width, depth and the
+decoded registers row by row, rounded to the nearest integer, as accepted by RedisBloom's
+CMS.INITBYDIM and CMS.MERGE.
+
+RedisBloom places keys with its own hash functions, so the exported matrix can be merged
+with other exports of this package but must not be queried with CMS.QUERY.
+*/
+func (cml *Sketch) ExportRedisCMS() (width, depth uint, counters []uint64) {
+	counters = make([]uint64, 0, cml.w*cml.d)
+	for _, row := range cml.logicalRows() {
+		for _, c := range row {
+			counters = append(counters, uint64(math.Round(cml.value(min(c, math.MaxUint16-1)))))
+		}
+	}
+	return cml.w, cml.d, counters
+}
+
+/*
+ImportRedisCMS returns a sketch whose registers encode the plain counters of a
+width x depth Count-Min matrix under base exp, rounding up to the next register.
+
+Because placement differs from RedisBloom's, the result is only meaningful for
+matrices produced by ExportRedisCMS, e.g. after merging them in Redis.
+*/
+func ImportRedisCMS(width, depth uint, counters []uint64, exp float64) (*Sketch, error) {
+	if width == 0 || depth == 0 {
+		return nil, errors.New("width and depth must be non-zero")
+	}
+	if uint64(len(counters)) != uint64(width)*uint64(depth) {
+		return nil, errors.New("counters do not match width and depth")
+	}
+	if !(exp > 1) || math.IsInf(exp, 1) {
+		return nil, errors.New("exp needs to be > 1")
+	}
+	cml, err := NewSketch(width, depth, exp)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range cml.store {
+		for j := range row {
+			c, ok := cml.register(float64(counters[uint(i)*width+uint(j)]))
+			if !ok {
+				return nil, errors.New("counter exceeds the range of exp")
+			}
+			row[j] = c
+		}
+	}
+	cml.recount()
+	return cml, nil
+}
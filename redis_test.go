@@ -0,0 +1,52 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestRedisCMS(t *testing.T) {
+	counters := []uint64{
+		0, 1, 2, 10,
+		100, 1000, 54321, 1000000,
+	}
+	sk, err := ImportRedisCMS(4, 2, counters, 1.00026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, depth, exported := sk.ExportRedisCMS()
+	if width != 4 || depth != 2 || len(exported) != len(counters) {
+		t.Fatalf("expected 4x2 matrix, got %dx%d with %d counters", width, depth, len(exported))
+	}
+	for i, expected := range counters {
+		if got := float64(exported[i]); got < float64(expected) || got > float64(expected)*1.00026+1 {
+			t.Errorf("expected %d within quantization, got %d", expected, exported[i])
+		}
+	}
+
+	if _, err := ImportRedisCMS(4, 2, counters[:7], 1.00026); err == nil {
+		t.Error("expected error for mismatched counters")
+	}
+	if _, err := ImportRedisCMS(1, 1, []uint64{math.MaxUint64}, 1.00026); err == nil {
+		t.Error("expected error for out-of-range counter")
+	}
+}
+
+func TestRedisCMSRoundTrip(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 100; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i*10))
+	}
+	width, depth, counters := sk.ExportRedisCMS()
+	imported, err := ImportRedisCMS(width, depth, counters, 1.00026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprint(i))
+		if orig, got := sk.Query(key), imported.Query(key); math.Abs(got-orig) > 2*(orig*0.00026+1) {
+			t.Errorf("expected %f for %s, got %f", orig, key, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cml
+
+import "fmt"
+
+/*
+RegisterIndexError is returned by GetRegister and SetRegister for a cell outside the sketch
+*/
+type RegisterIndexError struct {
+	Row, Col uint
+	W, D     uint
+}
+
+func (e *RegisterIndexError) Error() string {
+	return fmt.Sprintf("register %d,%d out of range for a %dx%d sketch", e.Row, e.Col, e.D, e.W)
+}
+
+/*
+GetRegister returns the raw register at column col of row row
+*/
+func (cml *Sketch) GetRegister(row, col uint) (uint16, error) {
+	if row >= cml.d || col >= cml.w {
+		return 0, &RegisterIndexError{Row: row, Col: col, W: cml.w, D: cml.d}
+	}
+	return cml.at(row, col), nil
+}
+
+/*
+SetRegister overwrites the raw register at column col of row row. It is meant for repair
+and research tooling: it bypasses every invariant of the sketch, such as the conservative
+update keeping a key's registers consistent, and is not written to the write-ahead log.
+*/
+func (cml *Sketch) SetRegister(row, col uint, v uint16) error {
+	if row >= cml.d || col >= cml.w {
+		return &RegisterIndexError{Row: row, Col: col, W: cml.w, D: cml.d}
+	}
+	if cml.readOnly {
+		return ErrReadOnly
+	}
+	r := cml.cell(row, col)
+	cml.track(*r, v)
+	*r = v
+	return nil
+}
+
+/*
+RowSlice returns a copy of the raw registers of row row, or nil if there is no such row
+*/
+func (cml *Sketch) RowSlice(row uint) []uint16 {
+	if row >= cml.d {
+		return nil
+	}
+	if cml.banded || cml.paged {
+		return append([]uint16(nil), cml.logicalRows()[row]...)
+	}
+	return append([]uint16(nil), cml.store[row]...)
+}
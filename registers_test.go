@@ -0,0 +1,53 @@
+package cml
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRegisters(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	for _, cell := range [][2]uint{{0, 0}, {3, 99}, {2, 50}} {
+		if err := sk.SetRegister(cell[0], cell[1], 1234); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := sk.GetRegister(cell[0], cell[1]); err != nil || c != 1234 {
+			t.Errorf("expected 1234 at %v, got %d and %v", cell, c, err)
+		}
+	}
+
+	for _, cell := range [][2]uint{{4, 0}, {0, 100}, {math.MaxUint32, 1}} {
+		var rangeErr *RegisterIndexError
+		if _, err := sk.GetRegister(cell[0], cell[1]); !errors.As(err, &rangeErr) || rangeErr.Row != cell[0] || rangeErr.Col != cell[1] || rangeErr.W != 100 || rangeErr.D != 4 {
+			t.Errorf("expected a RegisterIndexError for %v, got %v", cell, err)
+		}
+		if err := sk.SetRegister(cell[0], cell[1], 1); !errors.As(err, &rangeErr) {
+			t.Errorf("expected a RegisterIndexError for %v, got %v", cell, err)
+		}
+	}
+
+	key := []byte("a")
+	h := sk.hash(key)
+	for i := uint(0); i < 4; i++ {
+		sk.SetRegister(i, sk.column(h, int(i)), 500)
+	}
+	if got := sk.Query(key); got != sk.value(500) {
+		t.Errorf("expected Query to reflect the registers, got %v", got)
+	}
+	if occupied := scanOccupied(sk); sk.occupied != uint64(occupied) {
+		t.Errorf("expected %d occupied registers, got %d", occupied, sk.occupied)
+	}
+
+	row := sk.RowSlice(3)
+	if len(row) != 100 || row[99] != 1234 {
+		t.Errorf("expected a copy of row 3, got %v", row)
+	}
+	row[99] = 0
+	if c, _ := sk.GetRegister(3, 99); c != 1234 {
+		t.Error("expected RowSlice not to alias the store")
+	}
+	if sk.RowSlice(4) != nil {
+		t.Error("expected nil for a missing row")
+	}
+}
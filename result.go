@@ -0,0 +1,75 @@
+package cml
+
+/*
+Result is the outcome of an update
+*/
+type Result int
+
+const (
+	// Applied means at least one increment raised the key's registers.
+	Applied Result = iota
+	// Skipped means the probabilistic increment declined every increment, or there were none.
+	// This is the normal outcome for most updates of frequent keys.
+	Skipped
+	// Saturated means the key's registers are all at their maximum and the update was
+	// stopped there; it is permanent and worth alerting on or spilling elsewhere.
+	Saturated
+)
+
+func (r Result) String() string {
+	switch r {
+	case Applied:
+		return "applied"
+	case Skipped:
+		return "skipped"
+	case Saturated:
+		return "saturated"
+	}
+	return "unknown"
+}
+
+/*
+UpdateResult increases the count of `e` by one and reports whether it was applied, skipped or
+stopped by saturation. It returns ErrUninitialized for a zero Sketch and a *KeyError for a
+key refused by the sketch's key validation.
+*/
+func (cml *Sketch) UpdateResult(e []byte) (Result, error) {
+	return cml.BulkUpdateResult(e, 1)
+}
+
+/*
+BulkUpdateResult increases the count of `e` by freq and reports Saturated if saturation stopped
+any of the increments, Applied if any of them raised the registers, and Skipped otherwise,
+including for a freq of 0. It returns ErrUninitialized for a zero Sketch, ErrReadOnly for a
+read-only one and a *KeyError for a key refused by the sketch's key validation.
+*/
+func (cml *Sketch) BulkUpdateResult(e []byte, freq uint) (Result, error) {
+	if err := cml.writable(); err != nil {
+		return Skipped, err
+	}
+	if err := cml.CheckKey(e); err != nil {
+		return Skipped, err
+	}
+	return cml.updateResult(cml.hash(e), freq), nil
+}
+
+// updateResult increases the count of the hashed key by freq, logging it to
+// the write-ahead log, and reports the outcome.
+func (cml *Sketch) updateResult(h keyHash, freq uint) Result {
+	if freq == 0 {
+		return Skipped
+	}
+	if cml.checks {
+		cml.beginWrite()
+		defer cml.endWrite()
+	}
+	cml.logWAL(h, freq)
+	consumed, accepted := cml.add(h, freq)
+	switch {
+	case consumed < freq:
+		return Saturated
+	case accepted > 0:
+		return Applied
+	}
+	return Skipped
+}
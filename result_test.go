@@ -0,0 +1,54 @@
+package cml
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestUpdateResult(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	key := []byte("a")
+	h := sk.hash(key)
+
+	if res, err := sk.UpdateResult(key); err != nil || res != Applied {
+		t.Errorf("expected the first update to apply, got %v, %v", res, err)
+	}
+	if res, _ := sk.BulkUpdateResult(key, 0); res != Skipped {
+		t.Errorf("expected freq 0 to skip, got %v", res)
+	}
+
+	// A register this high passes the coin flip with probability exp^-60000.
+	for i := range sk.store {
+		sk.store[i][sk.column(h, i)] = 60000
+	}
+	if res, _ := sk.UpdateResult(key); res != Skipped {
+		t.Errorf("expected a skipped update, got %v", res)
+	}
+
+	// Saturating only some of the probed rows leaves the key countable.
+	coarse, _ := NewSketch(100, 4, 1+1e-12)
+	for i := range coarse.store {
+		coarse.store[i][coarse.column(h, i)] = math.MaxUint16
+	}
+	coarse.store[1][coarse.column(h, 1)] = 100
+	if res, _ := coarse.UpdateResult(key); res != Applied {
+		t.Errorf("expected a partially saturated key to apply, got %v", res)
+	}
+
+	coarse.store[1][coarse.column(h, 1)] = math.MaxUint16 - 2
+	if res, _ := coarse.BulkUpdateResult(key, 10); res != Saturated {
+		t.Errorf("expected a bulk update running into saturation to report it, got %v", res)
+	}
+	if res, _ := coarse.UpdateResult(key); res != Saturated {
+		t.Errorf("expected a saturated key to report it, got %v", res)
+	}
+	if !coarse.Update(key) {
+		t.Error("expected the boolean wrapper to keep returning true")
+	}
+
+	var zero Sketch
+	if _, err := zero.UpdateResult(key); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package cml
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSeededSketchesReproducible(t *testing.T) {
+	feed := func(opts ...Option) *Sketch {
+		sk, _ := NewSketch(1000, 4, 1.00026, opts...)
+		for i := 0; i < 20000; i++ {
+			sk.BulkUpdate([]byte(fmt.Sprint(i%3000)), uint(i%7+1))
+		}
+		return sk
+	}
+	registers := func(sk *Sketch) []uint64 {
+		_, _, counters := sk.ExportRedisCMS()
+		return counters
+	}
+
+	a, b := feed(WithSeed(42)), feed(WithSeed(42))
+	if !slices.Equal(registers(a), registers(b)) {
+		t.Error("expected equally seeded sketches fed the same stream to have the same registers")
+	}
+	if c := feed(WithSeed(43)); slices.Equal(registers(a), registers(c)) {
+		t.Error("expected differently seeded sketches to differ")
+	}
+	if !slices.Equal(registers(feed()), registers(feed())) {
+		t.Error("expected unseeded sketches of the same dimensions to have the same registers")
+	}
+
+	like := NewLike(a)
+	for i := 0; i < 20000; i++ {
+		like.BulkUpdate([]byte(fmt.Sprint(i%3000)), uint(i%7+1))
+	}
+	if !slices.Equal(registers(a), registers(like)) {
+		t.Error("expected NewLike to keep the seed")
+	}
+}
+
+func TestSketchesUpdatedConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for range 2 {
+		sk, _ := NewSketch(1000, 4, 1.00026)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				sk.Update([]byte(fmt.Sprint(i % 100)))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRandFloatPrecision(t *testing.T) {
+	const p, trials = 1e-7, 50_000_000
+	g := newRNG(1)
+	hits := 0
+	for range trials {
+		if f := g.float(0); f < p {
+			hits++
+		} else if f >= 1 {
+			t.Fatalf("expected numbers below 1, got %f", f)
+		}
+	}
+	// 5 hits are expected; a million distinct values would accept 50.
+	if hits == 0 || hits > 15 {
+		t.Errorf("expected about %d numbers below %g, got %d", int(p*trials), p, hits)
+	}
+}
+
+func TestHighRegistersKeepGrowing(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithSeed(7))
+	key := []byte("hot")
+	// Each update raises the register with a probability of about 5e-7 here.
+	const start = 55810
+	h := sk.hash(key)
+	for i := uint(0); i < 4; i++ {
+		sk.SetRegister(i, sk.column(h, int(i)), start)
+	}
+	sk.BulkUpdate(key, 20_000_000)
+	// 10 steps are expected; numbers rounded to a millionth took 20.
+	if steps := sk.QueryRaw(key) - start; steps < 3 || steps > 17 {
+		t.Errorf("expected about 10 register steps, got %d", steps)
+	}
+}
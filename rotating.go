@@ -0,0 +1,178 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+/*
+Rotating keeps a ring of sketches, one per interval of wall-clock time, and rotates
+to a fresh one whenever the current interval has elapsed, so traffic ages out after
+slots intervals
+*/
+type Rotating struct {
+	interval time.Duration
+	now      func() time.Time
+	head     int
+	sketches []*Sketch
+	epochs   []time.Time
+}
+
+/*
+NewRotating returns a new Rotating with slots sketches, each created by NewSketch(w, d, exp, opts...)
+*/
+func NewRotating(slots int, interval time.Duration, w uint, d uint, exp float64, opts ...Option) (*Rotating, error) {
+	if slots < 1 {
+		return nil, errors.New("slots needs to be >= 1")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval needs to be > 0")
+	}
+	r := &Rotating{
+		interval: interval,
+		now:      time.Now,
+		sketches: make([]*Sketch, slots),
+		epochs:   make([]time.Time, slots),
+	}
+	for i := range r.sketches {
+		sk, err := NewSketch(w, d, exp, opts...)
+		if err != nil {
+			return nil, err
+		}
+		r.sketches[i] = sk
+	}
+	r.epochs[0] = r.now()
+	return r, nil
+}
+
+/*
+SetClock replaces the clock used to decide when to rotate, e.g. with a fake one in tests,
+and restarts the current interval at its current time
+*/
+func (r *Rotating) SetClock(now func() time.Time) {
+	r.now = now
+	r.epochs[r.head] = now()
+}
+
+// advance rotates once per interval elapsed since the current one started.
+func (r *Rotating) advance() {
+	now := r.now()
+	for i := 0; i < len(r.sketches) && !now.Before(r.epochs[r.head].Add(r.interval)); i++ {
+		r.rotate(r.epochs[r.head].Add(r.interval))
+	}
+	if !now.Before(r.epochs[r.head].Add(r.interval)) {
+		// Idle for longer than the whole ring: everything has been reset.
+		r.epochs[r.head] = now
+	}
+}
+
+// rotate reuses the oldest sketch as the new current one starting at epoch.
+func (r *Rotating) rotate(epoch time.Time) {
+	r.head = (r.head + 1) % len(r.sketches)
+	r.sketches[r.head].Reset()
+	r.epochs[r.head] = epoch
+}
+
+/*
+Flush forces a rotation, e.g. before a shutdown checkpoint
+*/
+func (r *Rotating) Flush() {
+	r.advance()
+	r.rotate(r.now())
+}
+
+/*
+Update increases the count of `e` by one in the current interval
+*/
+func (r *Rotating) Update(e []byte) bool {
+	return r.BulkUpdate(e, 1)
+}
+
+/*
+BulkUpdate increases the count of `e` by freq in the current interval
+*/
+func (r *Rotating) BulkUpdate(e []byte, freq uint) bool {
+	r.advance()
+	return r.sketches[r.head].BulkUpdate(e, freq)
+}
+
+/*
+QueryLastN returns the count of `e` summed over the current and the n-1 previous intervals
+*/
+func (r *Rotating) QueryLastN(e []byte, n int) float64 {
+	r.advance()
+	var sum float64
+	for i := 0; i < n && i < len(r.sketches); i++ {
+		sum += r.sketches[(r.head-i+len(r.sketches))%len(r.sketches)].Query(e)
+	}
+	return sum
+}
+
+/*
+QueryAll returns the count of `e` summed over all intervals
+*/
+func (r *Rotating) QueryAll(e []byte) float64 {
+	return r.QueryLastN(e, len(r.sketches))
+}
+
+/*
+MarshalBinary encodes the interval and every sketch with the time its interval started, oldest first
+*/
+func (r *Rotating) MarshalBinary() ([]byte, error) {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(r.interval))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(r.sketches)))
+	for i := 1; i <= len(r.sketches); i++ {
+		j := (r.head + i) % len(r.sketches)
+		b = binary.LittleEndian.AppendUint64(b, uint64(r.epochs[j].UnixNano()))
+		b = binary.LittleEndian.AppendUint64(b, uint64(r.sketches[j].encodedSize()))
+		var err error
+		if b, err = r.sketches[j].AppendBinary(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+/*
+UnmarshalBinary restores a Rotating encoded by MarshalBinary. The clock is kept, or set to time.Now on a zero value.
+*/
+func (r *Rotating) UnmarshalBinary(b []byte) error {
+	if len(b) < 16 {
+		return errors.New("rotating data too short")
+	}
+	interval := time.Duration(binary.LittleEndian.Uint64(b))
+	slots := binary.LittleEndian.Uint64(b[8:])
+	if interval <= 0 || slots < 1 || slots > uint64(len(b)) {
+		return errors.New("invalid rotating header")
+	}
+	b = b[16:]
+	sketches := make([]*Sketch, slots)
+	epochs := make([]time.Time, slots)
+	for i := range sketches {
+		if len(b) < 16 {
+			return errors.New("rotating data truncated")
+		}
+		epochs[i] = time.Unix(0, int64(binary.LittleEndian.Uint64(b)))
+		size := binary.LittleEndian.Uint64(b[8:])
+		if size > uint64(len(b)-16) {
+			return errors.New("rotating data truncated")
+		}
+		sketches[i] = &Sketch{}
+		if err := sketches[i].UnmarshalBinary(b[16 : 16+size]); err != nil {
+			return err
+		}
+		b = b[16+size:]
+	}
+	if len(b) != 0 {
+		return errors.New("trailing data after rotating sketches")
+	}
+	r.interval = interval
+	r.sketches = sketches
+	r.epochs = epochs
+	r.head = len(sketches) - 1
+	if r.now == nil {
+		r.now = time.Now
+	}
+	return nil
+}
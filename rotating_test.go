@@ -0,0 +1,91 @@
+package cml
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func TestRotating(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	r, err := NewRotating(3, time.Minute, 1000, 4, 1.00026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetClock(clock.Now)
+	key := []byte("key")
+
+	r.BulkUpdate(key, 100)
+	clock.t = clock.t.Add(time.Minute)
+	r.BulkUpdate(key, 10)
+	clock.t = clock.t.Add(time.Minute + time.Second)
+	r.BulkUpdate(key, 1)
+
+	if count := r.QueryLastN(key, 1); count < 0.5 || count > 1.5 {
+		t.Errorf("expected ~1 in the current interval, got %f", count)
+	}
+	if count := r.QueryLastN(key, 2); count < 10.5 || count > 11.5 {
+		t.Errorf("expected ~11 in the last two intervals, got %f", count)
+	}
+	if count := r.QueryAll(key); count < 105 || count > 117 {
+		t.Errorf("expected ~111 overall, got %f", count)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if count := r.QueryAll(key); count < 10.5 || count > 11.5 {
+		t.Errorf("expected oldest interval to age out, got %f", count)
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	if count := r.QueryAll(key); count != 0 {
+		t.Errorf("expected everything to age out after idling, got %f", count)
+	}
+
+	r.BulkUpdate(key, 5)
+	r.Flush()
+	if count := r.QueryLastN(key, 1); count != 0 {
+		t.Errorf("expected a fresh interval after Flush, got %f", count)
+	}
+	if count := r.QueryLastN(key, 2); count < 4.5 || count > 5.5 {
+		t.Errorf("expected ~5 in the flushed interval, got %f", count)
+	}
+}
+
+func TestRotatingMarshal(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	r, _ := NewRotating(3, time.Minute, 1000, 4, 1.00026)
+	r.SetClock(clock.Now)
+	key := []byte("key")
+	r.BulkUpdate(key, 100)
+	clock.t = clock.t.Add(time.Minute)
+	r.BulkUpdate(key, 10)
+
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &Rotating{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	restored.now = clock.Now
+	for n := 1; n <= 3; n++ {
+		if got, expected := restored.QueryLastN(key, n), r.QueryLastN(key, n); got != expected {
+			t.Errorf("expected %f for the last %d intervals, got %f", expected, n, got)
+		}
+	}
+	if !restored.epochs[restored.head].Equal(clock.t) {
+		t.Errorf("expected current interval to start at %v, got %v", clock.t, restored.epochs[restored.head])
+	}
+
+	if err := restored.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
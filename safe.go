@@ -0,0 +1,119 @@
+package cml
+
+import "sync"
+
+var _ Sketcher = (*SafeSketch)(nil)
+
+/*
+SafeSketch guards a Count-Min-Log Sketch for concurrent use: updates take its lock
+exclusively, while queries, statistics and encoding share it with each other.
+All access to the underlying sketch goes through the lock.
+*/
+type SafeSketch struct {
+	mu sync.RWMutex
+	sk *Sketch
+}
+
+/*
+NewSafeSketch returns a new SafeSketch guarding sk, which must not be used directly afterwards
+*/
+func NewSafeSketch(sk *Sketch) *SafeSketch {
+	return &SafeSketch{sk: sk}
+}
+
+/*
+Update increases the count of `e` by one
+*/
+func (ss *SafeSketch) Update(e []byte) bool {
+	return ss.BulkUpdate(e, 1)
+}
+
+/*
+BulkUpdate increases the count of `e` by freq
+*/
+func (ss *SafeSketch) BulkUpdate(e []byte, freq uint) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.sk.BulkUpdate(e, freq)
+}
+
+/*
+Query returns the count of `e`
+*/
+func (ss *SafeSketch) Query(e []byte) float64 {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sk.Query(e)
+}
+
+/*
+Stats returns a snapshot of the sketch's dimensions and health
+*/
+func (ss *SafeSketch) Stats() SketchStats {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sk.Stats()
+}
+
+/*
+Reset zeroes every register
+*/
+func (ss *SafeSketch) Reset() {
+	ss.Do((*Sketch).Reset)
+}
+
+/*
+MarshalBinary encodes the sketch like Sketch.MarshalBinary
+*/
+func (ss *SafeSketch) MarshalBinary() ([]byte, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sk.MarshalBinary()
+}
+
+/*
+UnmarshalBinary replaces the sketch with the one encoded by MarshalBinary
+*/
+func (ss *SafeSketch) UnmarshalBinary(b []byte) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.sk.UnmarshalBinary(b)
+}
+
+/*
+Do runs fn on the underlying sketch while holding the lock exclusively,
+e.g. to merge into or decay it
+*/
+func (ss *SafeSketch) Do(fn func(sk *Sketch)) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	fn(ss.sk)
+}
+
+/*
+Insert is Update, for Sketcher
+*/
+func (ss *SafeSketch) Insert(key []byte) bool {
+	return ss.Update(key)
+}
+
+/*
+InsertN is BulkUpdate, for Sketcher
+*/
+func (ss *SafeSketch) InsertN(key []byte, n uint) bool {
+	return ss.BulkUpdate(key, n)
+}
+
+/*
+Estimate is Query, for Sketcher
+*/
+func (ss *SafeSketch) Estimate(key []byte) float64 {
+	return ss.Query(key)
+}
+
+/*
+Clear is Reset, for Sketcher
+*/
+func (ss *SafeSketch) Clear() {
+	ss.Reset()
+}
@@ -0,0 +1,60 @@
+package cml
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSafeSketch(t *testing.T) {
+	sk, _ := NewSketch(10000, 4, 1.00026, WithConcurrencyChecks(true))
+	ss := NewSafeSketch(sk)
+
+	const writers, readers, updates = 4, 4, 5000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				ss.Update([]byte(fmt.Sprint(i % 10)))
+			}
+		}()
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				ss.Query([]byte(fmt.Sprint(i % 10)))
+				if i%500 == 0 {
+					ss.Stats()
+					ss.MarshalBinary()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := ss.Stats(); stats.TotalUpdates != writers*updates {
+		t.Errorf("expected %d updates, got %d", writers*updates, stats.TotalUpdates)
+	}
+	want := float64(writers * updates / 10)
+	for i := 0; i < 10; i++ {
+		if got := ss.Query([]byte(fmt.Sprint(i))); got < 0.9*want || got > 1.1*want {
+			t.Errorf("expected about %f for %d, got %f", want, i, got)
+		}
+	}
+
+	data, _ := ss.MarshalBinary()
+	ss.Reset()
+	if got := ss.Query([]byte("0")); got != 0 {
+		t.Errorf("expected 0 after a reset, got %f", got)
+	}
+	if err := ss.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := ss.Query([]byte("0")); got < 0.9*want {
+		t.Errorf("expected about %f after restoring, got %f", want, got)
+	}
+}
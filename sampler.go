@@ -0,0 +1,224 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+/*
+KeySampler wraps a Count-Min-Log Sketch and keeps a fixed-size uniform reservoir
+sample of the keys seen by Update and BulkUpdate, so probable heavy hitters can be
+listed and then estimated with Query.
+
+The reservoir is uniform over events, not over distinct keys: a key seen 1000 times
+is 1000 times more likely to be sampled than a key seen once.
+*/
+type KeySampler struct {
+	*Sketch
+
+	capacity int
+	maxBytes int
+	bytes    int
+	seen     uint64
+	keys     [][]byte
+}
+
+/*
+NewKeySampler returns a new KeySampler holding at most capacity keys using at most maxBytes of key data
+*/
+func NewKeySampler(sk *Sketch, capacity int, maxBytes int) (*KeySampler, error) {
+	if capacity < 1 || uint64(capacity) > math.MaxUint32 {
+		return nil, errors.New("capacity needs to be between 1 and 2^32-1")
+	}
+	if maxBytes < 1 {
+		return nil, errors.New("maxBytes needs to be >= 1")
+	}
+	return &KeySampler{
+		Sketch:   sk,
+		capacity: capacity,
+		maxBytes: maxBytes,
+		keys:     make([][]byte, 0, capacity),
+	}, nil
+}
+
+/*
+Update increases the count of `e` by one and offers it to the reservoir
+*/
+func (ks *KeySampler) Update(e []byte) bool {
+	ks.sample(e, 1)
+	return ks.Sketch.Update(e)
+}
+
+/*
+BulkUpdate increases the count of `e` by freq and offers it to the reservoir with weight freq
+*/
+func (ks *KeySampler) BulkUpdate(e []byte, freq uint) bool {
+	if freq > 0 {
+		ks.sample(e, freq)
+	}
+	return ks.Sketch.BulkUpdate(e, freq)
+}
+
+// sample offers freq occurrences of e to the reservoir, keeping every slot a
+// uniform draw from the events seen so far: the first capacity events fill the
+// slots, and a later batch of freq events takes over each slot with probability
+// freq/seen, which reduces to Algorithm R for single events.
+func (ks *KeySampler) sample(e []byte, freq uint) {
+	seen := ks.seen
+	ks.seen += uint64(freq)
+	if len(e) > ks.maxBytes {
+		return
+	}
+	var key []byte
+	for ; freq > 0 && seen < uint64(ks.capacity); freq, seen = freq-1, seen+1 {
+		key = ks.put(len(ks.keys), key, e)
+	}
+	if freq == 0 {
+		return
+	}
+	p := float64(freq) / float64(ks.seen)
+	for i := ks.skip(p); i < ks.capacity; i += 1 + ks.skip(p) {
+		key = ks.put(i, key, e)
+	}
+}
+
+// skip returns how many slots to pass over before the next one taken with
+// probability p, a geometric variate capped at the capacity.
+func (ks *KeySampler) skip(p float64) int {
+	if p >= 1 {
+		return 0
+	}
+	return int(min(math.Log(1-ks.randFloat())/math.Log1p(-p), float64(ks.capacity)))
+}
+
+// put stores e in slot i, or in a new slot if i is past the last one, then
+// evicts random other slots until the keys fit maxBytes again, so long keys are
+// not sampled less often than short ones. The slots one update takes share key,
+// a copy of e made on first use, which put returns.
+func (ks *KeySampler) put(i int, key, e []byte) []byte {
+	if key == nil {
+		key = append([]byte{}, e...)
+	}
+	if i >= len(ks.keys) {
+		i = len(ks.keys)
+		ks.keys = append(ks.keys, key)
+	} else {
+		ks.bytes -= len(ks.keys[i])
+		ks.keys[i] = key
+	}
+	ks.bytes += len(key)
+	for ks.bytes > ks.maxBytes {
+		j := int(ks.randUint32() % uint32(len(ks.keys)))
+		if j == i {
+			continue
+		}
+		last := len(ks.keys) - 1
+		ks.bytes -= len(ks.keys[j])
+		ks.keys[j] = ks.keys[last]
+		ks.keys = ks.keys[:last]
+		if i == last {
+			i = j
+		}
+	}
+	return key
+}
+
+/*
+SampledKeys returns the distinct keys currently in the reservoir.
+The returned slices are copies and may be modified by the caller.
+*/
+func (ks *KeySampler) SampledKeys() [][]byte {
+	seen := make(map[string]struct{}, len(ks.keys))
+	keys := make([][]byte, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if _, ok := seen[string(k)]; ok {
+			continue
+		}
+		seen[string(k)] = struct{}{}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	return keys
+}
+
+/*
+Reset zeroes the underlying sketch and empties the reservoir
+*/
+func (ks *KeySampler) Reset() {
+	ks.Sketch.Reset()
+	ks.keys = ks.keys[:0]
+	ks.bytes = 0
+	ks.seen = 0
+}
+
+/*
+MarshalBinary encodes the reservoir followed by the underlying sketch
+*/
+func (ks *KeySampler) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 32+ks.bytes+4*len(ks.keys)+ks.encodedSize())
+	b = binary.LittleEndian.AppendUint64(b, uint64(ks.capacity))
+	b = binary.LittleEndian.AppendUint64(b, uint64(ks.maxBytes))
+	b = binary.LittleEndian.AppendUint64(b, ks.seen)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(ks.keys)))
+	for _, k := range ks.keys {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+	}
+	return ks.AppendBinary(b)
+}
+
+/*
+UnmarshalBinary restores a KeySampler encoded by MarshalBinary, decoding the sketch into the
+current underlying one, or a new one on a zero value. Encodings of the reservoir alone, as
+written before the sketch was included, keep the current underlying sketch.
+*/
+func (ks *KeySampler) UnmarshalBinary(b []byte) error {
+	if len(b) < 32 {
+		return errors.New("reservoir data too short")
+	}
+	var (
+		capacity = binary.LittleEndian.Uint64(b[0:])
+		maxBytes = binary.LittleEndian.Uint64(b[8:])
+		seen     = binary.LittleEndian.Uint64(b[16:])
+		n        = binary.LittleEndian.Uint64(b[24:])
+	)
+	// Every key takes at least its 4-byte length.
+	if capacity < 1 || capacity > math.MaxUint32 || maxBytes < 1 || maxBytes > math.MaxInt ||
+		n > capacity || n > uint64(len(b)-32)/4 {
+		return errors.New("invalid reservoir header")
+	}
+	b = b[32:]
+	keys := make([][]byte, 0, n)
+	bytes := 0
+	for i := uint64(0); i < n; i++ {
+		if len(b) < 4 {
+			return errors.New("reservoir data truncated")
+		}
+		l := binary.LittleEndian.Uint32(b)
+		if uint64(len(b)-4) < uint64(l) {
+			return errors.New("reservoir data truncated")
+		}
+		keys = append(keys, append([]byte(nil), b[4:4+l]...))
+		bytes += int(l)
+		b = b[4+l:]
+	}
+	if uint64(bytes) > maxBytes {
+		return errors.New("reservoir exceeds its memory bound")
+	}
+	sk := ks.Sketch
+	if sk == nil {
+		sk = &Sketch{}
+	}
+	if len(b) != 0 {
+		if err := sk.UnmarshalBinary(b); err != nil {
+			return err
+		}
+	}
+	ks.Sketch = sk
+	ks.capacity = int(capacity)
+	ks.maxBytes = int(maxBytes)
+	ks.seen = seen
+	ks.keys = keys
+	ks.bytes = bytes
+	return nil
+}
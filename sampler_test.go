@@ -0,0 +1,190 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestKeySamplerUniform(t *testing.T) {
+	const (
+		nKeys    = 10
+		perKey   = 1000
+		capacity = 100
+		trials   = 200
+	)
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	keys := make([][]byte, nKeys)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+	}
+
+	hits := make(map[string]int)
+	for trial := 0; trial < trials; trial++ {
+		ks, _ := NewKeySampler(sk, capacity, 1<<20)
+		for i := 0; i < nKeys*perKey; i++ {
+			ks.Update(keys[i%nKeys])
+		}
+		for _, k := range ks.keys {
+			hits[string(k)]++
+		}
+	}
+
+	expected := float64(trials * capacity / nKeys)
+	for _, k := range keys {
+		if got := float64(hits[string(k)]); math.Abs(got-expected) > expected/10 {
+			t.Errorf("expected %s sampled ~%.0f times, got %.0f", k, expected, got)
+		}
+	}
+}
+
+func TestKeySamplerDedupAndBounds(t *testing.T) {
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	ks, _ := NewKeySampler(sk, 8, 16)
+
+	for i := 0; i < 100; i++ {
+		ks.Update([]byte("aaaa"))
+	}
+	ks.Update([]byte("this key is far too long to sample"))
+
+	if keys := ks.SampledKeys(); len(keys) != 1 || !bytes.Equal(keys[0], []byte("aaaa")) {
+		t.Errorf("expected a single deduplicated key, got %q", keys)
+	}
+	if ks.bytes > 16 {
+		t.Errorf("expected at most 16 bytes of keys, got %d", ks.bytes)
+	}
+	if count := ks.Query([]byte("aaaa")); count < 95 || count > 105 {
+		t.Errorf("expected 100, got %d", uint(count))
+	}
+}
+
+func TestKeySamplerWeighted(t *testing.T) {
+	const trials = 200
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	for _, heavyFirst := range []bool{true, false} {
+		heavy := 0
+		for trial := 0; trial < trials; trial++ {
+			ks, _ := NewKeySampler(sk, 100, 1<<20)
+			if heavyFirst {
+				ks.BulkUpdate([]byte("heavy"), 900)
+			}
+			for i := 0; i < 100; i++ {
+				ks.Update([]byte(fmt.Sprint(i)))
+			}
+			if !heavyFirst {
+				ks.BulkUpdate([]byte("heavy"), 900)
+			}
+			for _, k := range ks.keys {
+				if string(k) == "heavy" {
+					heavy++
+				}
+			}
+		}
+		if share := float64(heavy) / (trials * 100); math.Abs(share-0.9) > 0.03 {
+			t.Errorf("heavy first %t: expected the heavy key in ~90%% of the slots, got %.1f%%", heavyFirst, 100*share)
+		}
+	}
+}
+
+func TestKeySamplerLongKeys(t *testing.T) {
+	const trials = 200
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	short, long := []byte("a"), []byte("bbbbbbbb")
+	var hits [2]int
+	for trial := 0; trial < trials; trial++ {
+		ks, _ := NewKeySampler(sk, 10, 40)
+		for i := 0; i < 1000; i++ {
+			ks.Update(short)
+			ks.Update(long)
+		}
+		if ks.bytes > 40 {
+			t.Fatalf("expected at most 40 bytes of keys, got %d", ks.bytes)
+		}
+		for _, k := range ks.keys {
+			hits[len(k)/len(long)]++
+		}
+	}
+	// Evicting for a long key frees one slot per short key, so long keys hold
+	// fewer slots, but they must not be shut out once the reservoir is full.
+	if hits[1] < trials {
+		t.Errorf("expected long keys to keep being sampled, got %d slots against %d", hits[1], hits[0])
+	}
+}
+
+func TestKeySamplerMarshal(t *testing.T) {
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	ks, _ := NewKeySampler(sk, 4, 1024)
+	for i := 0; i < 20; i++ {
+		ks.BulkUpdate([]byte(fmt.Sprintf("key-%d", i)), uint(i+1))
+	}
+
+	data, err := ks.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := NewSketch(10, 1, 1.00026)
+	other, _ := NewKeySampler(fresh, 1, 1)
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if got, expected := other.Query(key), ks.Query(key); got != expected {
+			t.Errorf("expected %f for %s after round trip, got %f", expected, key, got)
+		}
+	}
+	if other.capacity != ks.capacity || other.seen != ks.seen || len(other.keys) != len(ks.keys) {
+		t.Fatalf("expected reservoirs to match, got %+v", other)
+	}
+	for i := range ks.keys {
+		if !bytes.Equal(ks.keys[i], other.keys[i]) {
+			t.Errorf("expected %q, got %q", ks.keys[i], other.keys[i])
+		}
+	}
+
+	if err := other.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("expected error for a truncated encoding")
+	}
+
+	// An encoding of the reservoir alone keeps the current sketch.
+	reservoir := data[:len(data)-ks.encodedSize()]
+	if err := other.UnmarshalBinary(reservoir); err != nil {
+		t.Fatal(err)
+	}
+	if other.Sketch != fresh || len(other.keys) != len(ks.keys) {
+		t.Errorf("expected the reservoir alone to keep the sketch, got %+v", other)
+	}
+	if err := other.UnmarshalBinary(reservoir[:len(reservoir)-1]); err == nil {
+		t.Error("expected error for a truncated reservoir")
+	}
+
+	huge := make([]byte, 32)
+	binary.LittleEndian.PutUint64(huge[0:], 1<<62)
+	binary.LittleEndian.PutUint64(huge[8:], 1)
+	if err := other.UnmarshalBinary(huge); err == nil {
+		t.Error("expected error for a capacity beyond 2^32-1")
+	}
+	binary.LittleEndian.PutUint64(huge[0:], 1<<31)
+	binary.LittleEndian.PutUint64(huge[24:], 1<<30)
+	if err := other.UnmarshalBinary(huge); err == nil {
+		t.Error("expected error for more keys than the data holds")
+	}
+}
+
+func TestKeySamplerInsert(t *testing.T) {
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	ks, _ := NewKeySampler(sk, 8, 1024)
+	var s Sketcher = ks
+	s.InsertN([]byte("a"), 10)
+	s.Insert([]byte("b"))
+
+	if keys := ks.SampledKeys(); len(keys) != 2 {
+		t.Errorf("expected Insert and InsertN to sample, got %q", keys)
+	}
+	s.Clear()
+	if keys := ks.SampledKeys(); len(keys) != 0 {
+		t.Errorf("expected Clear to empty the reservoir, got %q", keys)
+	}
+}
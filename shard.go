@@ -0,0 +1,226 @@
+package cml
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+)
+
+var shardMagic = [4]byte{'C', 'M', 'L', 'S'}
+
+var (
+	_ encoding.BinaryMarshaler   = (*SketchShard)(nil)
+	_ encoding.BinaryUnmarshaler = (*SketchShard)(nil)
+)
+
+/*
+SketchShard is a contiguous range of rows of a sketch, split off for row-local work such as
+decay, histogramming or compression and reassembled with CombineShards. Every shard also
+carries the sketch's metadata and doorkeeper, which span all rows.
+*/
+type SketchShard struct {
+	// Start and End delimit the rows of the sketch the shard holds, [Start, End).
+	Start, End uint
+	// Rows holds a copy of the shard's registers, which may be modified in place.
+	Rows [][]uint16
+
+	w, d       uint
+	exp        float64
+	hashing    hashing
+	flags      byte
+	metadata   map[string]string
+	doorkeeper []uint64
+}
+
+/*
+SplitRows splits the sketch's rows into n shards of nearly equal size, each with a copy of
+its rows. n must be between 1 and the depth of the sketch.
+*/
+func (cml *Sketch) SplitRows(n int) ([]*SketchShard, error) {
+	if !cml.initialized() {
+		return nil, ErrUninitialized
+	}
+	if n < 1 || uint(n) > cml.d {
+		return nil, errors.New("n needs to be between 1 and the depth of the sketch")
+	}
+	// The shards share one copy of the metadata and doorkeeper, which they never modify.
+	metadata, doorkeeper := maps.Clone(cml.metadata), slices.Clone(cml.doorkeeper)
+	shards := make([]*SketchShard, n)
+	for i := range shards {
+		start, end := cml.d*uint(i)/uint(n), cml.d*uint(i+1)/uint(n)
+		rows := newStore(cml.w, end-start)
+		for j := range rows {
+			for k := range rows[j] {
+				rows[j][k] = cml.at(start+uint(j), uint(k))
+			}
+		}
+		shards[i] = &SketchShard{
+			Start:      start,
+			End:        end,
+			Rows:       rows,
+			w:          cml.w,
+			d:          cml.d,
+			exp:        cml.exp,
+			hashing:    cml.hashing,
+			flags:      cml.flags(),
+			metadata:   metadata,
+			doorkeeper: doorkeeper,
+		}
+	}
+	return shards, nil
+}
+
+/*
+CombineShards reassembles the sketch split by SplitRows, with its metadata and doorkeeper. The
+shards, in any order, must come from sketches with the same parameters, metadata and doorkeeper
+and cover its rows exactly once. Settings that are not encoded, such as aging and key
+validation, and the update statistics are not carried over.
+*/
+func CombineShards(shards []*SketchShard) (*Sketch, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("no shards to combine")
+	}
+	sorted := append([]*SketchShard(nil), shards...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	// Check every shard before allocating, so the store is sized by the rows
+	// the shards hold rather than by a depth they declare.
+	first := sorted[0]
+	cml := (&Sketch{}).decoding(uint64(first.w), uint64(first.d), first.exp, first.hashing, first.flags)
+	cml.metadata = maps.Clone(first.metadata)
+	cml.doorkeeper = slices.Clone(first.doorkeeper)
+	var next uint
+	for _, s := range sorted {
+		if err := cml.compatibleWith(s.w, s.d, s.exp, s.hashing, s.flags); err != nil {
+			return nil, err
+		}
+		if err := cml.compatibleDoorkeeper(len(s.doorkeeper)); err != nil {
+			return nil, err
+		}
+		if !slices.Equal(s.doorkeeper, first.doorkeeper) {
+			return nil, errors.New("shards carry different doorkeepers")
+		}
+		if !maps.Equal(s.metadata, first.metadata) {
+			return nil, errors.New("shards carry different metadata")
+		}
+		if s.Start != next {
+			return nil, errors.New("shards leave gaps or overlap")
+		}
+		if s.End <= s.Start || s.End > cml.d || uint(len(s.Rows)) != s.End-s.Start {
+			return nil, errors.New("shard rows do not match its range")
+		}
+		for _, row := range s.Rows {
+			if uint(len(row)) != cml.w {
+				return nil, errors.New("shard rows do not match the sketch width")
+			}
+		}
+		next = s.End
+	}
+	if next != cml.d {
+		return nil, errors.New("shards do not cover every row")
+	}
+
+	cml.store = cml.emptyStore()
+	for _, s := range sorted {
+		for j, row := range s.Rows {
+			for k, c := range row {
+				if c != 0 {
+					*cml.cell(s.Start+uint(j), uint(k)) = c
+				}
+			}
+		}
+	}
+	cml.recount()
+	return cml, nil
+}
+
+/*
+MarshalBinary encodes the shard: the 4-byte magic "CMLS", Start and End as 8 bytes each, the
+MarshalBinary header of the whole sketch with its metadata and doorkeeper, and the shard's
+registers row by row, all little-endian
+*/
+func (s *SketchShard) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 20, 20+headerSize+8*len(s.doorkeeper)+2*int(s.w)*len(s.Rows))
+	copy(b, shardMagic[:])
+	binary.LittleEndian.PutUint64(b[4:], uint64(s.Start))
+	binary.LittleEndian.PutUint64(b[12:], uint64(s.End))
+	prefix := (&Sketch{}).decoding(uint64(s.w), uint64(s.d), s.exp, s.hashing, s.flags)
+	prefix.metadata = s.metadata
+	prefix.doorkeeper = s.doorkeeper
+	b = prefix.appendPrefix(b, byteOrderLittle)
+	for _, row := range s.Rows {
+		for _, c := range row {
+			b = binary.LittleEndian.AppendUint16(b, c)
+		}
+	}
+	return b, nil
+}
+
+/*
+UnmarshalBinary restores a shard encoded by MarshalBinary
+*/
+func (s *SketchShard) UnmarshalBinary(b []byte) error {
+	if len(b) < 20+headerSize || [4]byte(b[:4]) != shardMagic {
+		return errors.New("not a sketch shard encoding")
+	}
+	start, end := binary.LittleEndian.Uint64(b[4:]), binary.LittleEndian.Uint64(b[12:])
+	hdr := b[20 : 20+headerSize]
+	order, err := sketchHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if order != binary.LittleEndian {
+		return errors.New("sketch shard encodings are little-endian")
+	}
+	var (
+		w     = order.Uint64(hdr[8:])
+		d     = order.Uint64(hdr[16:])
+		exp   = math.Float64frombits(order.Uint64(hdr[24:]))
+		hash  = hashing(hdr[1])
+		flags = hdr[2]
+	)
+	if err := validateParams(w, d, exp, hash, flags); err != nil {
+		return err
+	}
+	if end <= start || end > d {
+		return errors.New("invalid shard range")
+	}
+	data := b[20+headerSize:]
+	var md map[string]string
+	if flags&flagMetadata != 0 {
+		if md, data, err = parseMetadata(data, order, DefaultMaxMetadataSize); err != nil {
+			return err
+		}
+	}
+	dk, data, err := decodeDoorkeeper(hdr, data, order)
+	if err != nil {
+		return err
+	}
+	if n := uint64(len(data)); n%2 != 0 || w > n/2/(end-start) || w*(end-start) != n/2 {
+		return errors.New("shard data size does not match its range")
+	}
+	rows := newStore(uint(w), uint(end-start))
+	for _, row := range rows {
+		for j := range row {
+			row[j] = binary.LittleEndian.Uint16(data)
+			data = data[2:]
+		}
+	}
+	*s = SketchShard{
+		Start:      uint(start),
+		End:        uint(end),
+		Rows:       rows,
+		w:          uint(w),
+		d:          uint(d),
+		exp:        exp,
+		hashing:    hash,
+		flags:      flags &^ (flagMetadata | flagDoorkeeper),
+		metadata:   md,
+		doorkeeper: dk,
+	}
+	return nil
+}
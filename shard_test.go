@@ -0,0 +1,155 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestSplitRows(t *testing.T) {
+	sk, _ := NewSketch(1000, 7, 1.00026, WithHash128())
+	for i := 0; i < 5000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%50+1))
+	}
+	whole := sk.Clone()
+
+	shards, err := sk.SplitRows(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 3 || shards[0].Start != 0 || shards[2].End != 7 {
+		t.Fatalf("expected 3 shards covering 7 rows, got %d", len(shards))
+	}
+
+	// Halve every register, shard by shard and on the whole sketch.
+	for _, s := range shards {
+		for _, row := range s.Rows {
+			for j := range row {
+				row[j] /= 2
+			}
+		}
+	}
+	for _, row := range whole.store {
+		for j := range row {
+			row[j] /= 2
+		}
+	}
+	if c, _ := sk.GetRegister(0, 0); c != sk.store[0][0] || shards[0].Rows[0][0] > c {
+		t.Error("expected shards to hold copies of the rows")
+	}
+
+	// Shards survive a round trip and combine in any order.
+	var restored []*SketchShard
+	for i := len(shards) - 1; i >= 0; i-- {
+		data, _ := shards[i].MarshalBinary()
+		s := &SketchShard{}
+		if err := s.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		restored = append(restored, s)
+	}
+	combined, err := CombineShards(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := combined.MarshalBinary()
+	b, _ := whole.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Error("expected combining the shards to equal mutating the whole sketch")
+	}
+	if combined.Occupied() != uint64(scanOccupied(combined)) {
+		t.Error("expected the combined sketch to be recounted")
+	}
+}
+
+func TestCombineShardsInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 4, 1.00026)
+	other, _ := NewSketch(100, 4, 1.5)
+	shards, _ := sk.SplitRows(2)
+	otherShards, _ := other.SplitRows(2)
+
+	for name, set := range map[string][]*SketchShard{
+		"gap":     {shards[0]},
+		"overlap": {shards[0], shards[0], shards[1]},
+		"params":  {shards[0], otherShards[1]},
+		"none":    nil,
+	} {
+		if _, err := CombineShards(set); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := sk.SplitRows(5); err == nil {
+		t.Error("expected error for more shards than rows")
+	}
+	if _, err := sk.SplitRows(0); err == nil {
+		t.Error("expected error for 0 shards")
+	}
+	data, _ := sk.MarshalBinary()
+	if err := (&SketchShard{}).UnmarshalBinary(data); err == nil {
+		t.Error("expected a shard to reject a sketch encoding")
+	}
+
+	data, _ = shards[0].MarshalBinary()
+	bigEndian := append([]byte(nil), data...)
+	bigEndian[20+4] = byteOrderBig
+	if err := (&SketchShard{}).UnmarshalBinary(bigEndian); err == nil {
+		t.Error("expected a shard to reject a big-endian header")
+	}
+
+	// A shard of the first two rows of a sketch declaring 2^50 rows.
+	deep := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(deep[20+16:], 1<<50)
+	s := &SketchShard{}
+	if err := s.UnmarshalBinary(deep); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CombineShards([]*SketchShard{s}); err == nil {
+		t.Error("expected error for a shard declaring rows it does not hold")
+	}
+}
+
+func TestSplitRowsDoorkeeperAndMetadata(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<12))
+	sk.SetMetadata("source", "test")
+	for i := 0; i < 500; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%3+1))
+	}
+	sk.Update([]byte("once"))
+
+	shards, _ := sk.SplitRows(2)
+	var restored []*SketchShard
+	for _, s := range shards {
+		data, _ := s.MarshalBinary()
+		r := &SketchShard{}
+		if err := r.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		restored = append(restored, r)
+	}
+	combined, err := CombineShards(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := combined.MarshalBinary()
+	b, _ := sk.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Error("expected the combined sketch to keep the metadata and doorkeeper")
+	}
+	if got := combined.Query([]byte("once")); got != 1 {
+		t.Errorf("expected the doorkept key to estimate 1, got %f", got)
+	}
+
+	plain, _ := NewSketch(1000, 4, 1.00026)
+	plainShards, _ := plain.SplitRows(2)
+	if _, err := CombineShards([]*SketchShard{shards[0], plainShards[1]}); err == nil {
+		t.Error("expected error for shards with and without a doorkeeper")
+	}
+	other := sk.Clone()
+	other.SetMetadata("source", "other")
+	otherShards, _ := other.SplitRows(2)
+	if _, err := CombineShards([]*SketchShard{shards[0], otherShards[1]}); err == nil {
+		t.Error("expected error for shards with different metadata")
+	}
+}
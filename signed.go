@@ -0,0 +1,234 @@
+package cml
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// signedRegisterWidth marks the encoding of a SignedSketch in the fourth header
+// byte: 16-bit registers with the top bit set for signed.
+const signedRegisterWidth = 0x80 | 16
+
+var (
+	_ encoding.BinaryMarshaler   = (*SignedSketch)(nil)
+	_ encoding.BinaryUnmarshaler = (*SignedSketch)(nil)
+)
+
+/*
+SignedSketch is a Count-Min-Log Sketch with int16 registers for turnstile streams, where keys
+are deleted as well as inserted. A register of -c stands for minus the count c stands for, and
+insertions and deletions step it up and down with the probability that keeps it unbiased, so
+a key's count can go transiently negative and recover. Every row is updated independently and
+Query takes the median over rows, since the minimum is no longer an upper bound.
+*/
+type SignedSketch struct {
+	w      uint
+	d      uint
+	exp    float64
+	logExp float64
+
+	store   [][]int16
+	hashing hashing
+	rnd     rng
+}
+
+/*
+NewSignedSketch returns a new Count-Min-Log Sketch with int16 registers
+*/
+func NewSignedSketch(w uint, d uint, exp float64) (*SignedSketch, error) {
+	if w == 0 || d == 0 {
+		return nil, errors.New("w and d must be non-zero")
+	}
+	if !(exp > 1) || math.IsInf(exp, 1) {
+		return nil, errors.New("exp needs to be > 1")
+	}
+	registers := make([]int16, w*d)
+	store := make([][]int16, d)
+	for i := range store {
+		store[i] = registers[uint(i)*w : uint(i+1)*w : uint(i+1)*w]
+	}
+	return &SignedSketch{
+		w:      w,
+		d:      d,
+		exp:    exp,
+		logExp: math.Log1p(exp - 1),
+		store:  store,
+	}, nil
+}
+
+/*
+Update increases the count of `e` by one
+*/
+func (ss *SignedSketch) Update(e []byte) bool {
+	return ss.BulkUpdate(e, 1)
+}
+
+/*
+Delete decreases the count of `e` by one
+*/
+func (ss *SignedSketch) Delete(e []byte) bool {
+	return ss.BulkUpdate(e, -1)
+}
+
+/*
+BulkUpdate adds delta, which may be negative, to the count of `e`
+*/
+func (ss *SignedSketch) BulkUpdate(e []byte, delta int) bool {
+	if len(ss.store) == 0 {
+		return false
+	}
+	step := int16(1)
+	if delta < 0 {
+		step, delta = -1, -delta
+	}
+	h := ss.hashing.hash(e)
+	for i, row := range ss.store {
+		r := &row[ss.hashing.column(h, i, ss.w)]
+		for n := 0; n < delta; n++ {
+			if *r == step*math.MaxInt16 {
+				break
+			}
+			// Step with the probability that moves the value by one on average.
+			if ss.rnd.float(dimensionSeed(ss.w, ss.d)) < 1/math.Abs(ss.value(*r+step)-ss.value(*r)) {
+				*r += step
+			}
+		}
+	}
+	return true
+}
+
+/*
+Query returns the count of `e`, which may be negative
+*/
+func (ss *SignedSketch) Query(e []byte) float64 {
+	if len(ss.store) == 0 {
+		return 0
+	}
+	h := ss.hashing.hash(e)
+	values := make([]float64, len(ss.store))
+	for i, row := range ss.store {
+		values[i] = ss.value(row[ss.hashing.column(h, i, ss.w)])
+	}
+	sort.Float64s(values)
+	if n := len(values); n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+	return values[len(values)/2]
+}
+
+// value is Sketch.value carrying the register's sign.
+func (ss *SignedSketch) value(r int16) float64 {
+	v := math.Expm1(math.Abs(float64(r))*ss.logExp) / (ss.exp - 1)
+	if r < 0 {
+		return -v
+	}
+	return v
+}
+
+// register returns the register whose value is nearest to v.
+func (ss *SignedSketch) register(v float64) int16 {
+	c := math.Log1p(math.Abs(v)*(ss.exp-1)) / ss.logExp
+	lo := math.Floor(c)
+	if lo >= math.MaxInt16 {
+		lo, c = math.MaxInt16, math.MaxInt16
+	}
+	r := int16(lo)
+	if c > lo && ss.value(r+1)-math.Abs(v) < math.Abs(v)-ss.value(r) {
+		r++
+	}
+	if v < 0 {
+		return -r
+	}
+	return r
+}
+
+/*
+Merge adds other into the sketch, summing the registers element-wise in value space and
+rounding to the nearest register, approximating the counts of both streams combined
+*/
+func (ss *SignedSketch) Merge(other *SignedSketch) error {
+	if ss.w != other.w {
+		return &MismatchError{Field: "width", Ours: ss.w, Theirs: other.w}
+	}
+	if ss.d != other.d {
+		return &MismatchError{Field: "depth", Ours: ss.d, Theirs: other.d}
+	}
+	if !sameExp(ss.exp, other.exp) {
+		return &MismatchError{Field: "exp", Ours: ss.exp, Theirs: other.exp}
+	}
+	for i, row := range other.store {
+		for j, r := range row {
+			if r != 0 {
+				ss.store[i][j] = ss.register(ss.value(ss.store[i][j]) + ss.value(r))
+			}
+		}
+	}
+	return nil
+}
+
+/*
+MarshalBinary encodes the sketch's parameters and registers.
+
+The encoding is the 32-byte header of a Sketch with 0x90 as its fourth byte, followed by the
+registers row by row as two's complement int16, all little-endian.
+*/
+func (ss *SignedSketch) MarshalBinary() ([]byte, error) {
+	b := make([]byte, headerSize, headerSize+2*ss.w*ss.d)
+	b[0] = encodingVersion
+	b[1] = byte(ss.hashing)
+	b[3] = signedRegisterWidth
+	binary.LittleEndian.PutUint64(b[8:], uint64(ss.w))
+	binary.LittleEndian.PutUint64(b[16:], uint64(ss.d))
+	binary.LittleEndian.PutUint64(b[24:], math.Float64bits(ss.exp))
+	for _, row := range ss.store {
+		for _, r := range row {
+			b = binary.LittleEndian.AppendUint16(b, uint16(r))
+		}
+	}
+	return b, nil
+}
+
+/*
+UnmarshalBinary restores a sketch encoded by MarshalBinary, replacing its parameters and registers
+*/
+func (ss *SignedSketch) UnmarshalBinary(b []byte) error {
+	if len(b) < headerSize {
+		return errors.New("sketch data too short")
+	}
+	if b[0] != encodingVersion {
+		return errors.New("unsupported sketch encoding version")
+	}
+	if b[3] != signedRegisterWidth {
+		return errors.New("not a signed sketch encoding")
+	}
+	if b[1] != byte(hashFarm64) || b[2] != 0 || b[4] != byteOrderLittle {
+		return errors.New("unsupported signed sketch hashing, flags or byte order")
+	}
+	w, d := binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[16:])
+	if w == 0 || d == 0 {
+		return errors.New("sketch dimensions must be non-zero")
+	}
+	if n := uint64(len(b) - headerSize); n%2 != 0 || w > n/2/d || w*d != n/2 {
+		return errors.New("sketch data size does not match its dimensions")
+	}
+	decoded, err := NewSignedSketch(uint(w), uint(d), math.Float64frombits(binary.LittleEndian.Uint64(b[24:])))
+	if err != nil {
+		return err
+	}
+	data := b[headerSize:]
+	for _, row := range decoded.store {
+		for j := range row {
+			r := int16(binary.LittleEndian.Uint16(data))
+			if r == math.MinInt16 {
+				return errors.New("invalid signed sketch register")
+			}
+			row[j] = r
+			data = data[2:]
+		}
+	}
+	*ss = *decoded
+	return nil
+}
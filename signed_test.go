@@ -0,0 +1,70 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSignedSketchTurnstile(t *testing.T) {
+	ss, _ := NewSignedSketch(10000, 5, 1.00026)
+	for round := 0; round < 500; round++ {
+		for i := 0; i < 20; i++ {
+			ss.Update([]byte(fmt.Sprint("key-", i)))
+		}
+		for i := 0; i < 20; i++ {
+			ss.Delete([]byte(fmt.Sprint("key-", i)))
+		}
+	}
+	ss.BulkUpdate([]byte("kept"), 1000)
+	ss.BulkUpdate([]byte("kept"), -400)
+	ss.BulkUpdate([]byte("negative"), -50)
+
+	for i := 0; i < 20; i++ {
+		if got := ss.Query([]byte(fmt.Sprint("key-", i))); math.Abs(got) > 5 {
+			t.Errorf("expected key-%d to converge near 0, got %v", i, got)
+		}
+	}
+	if got := ss.Query([]byte("kept")); math.Abs(got-600) > 600*0.05 {
+		t.Errorf("expected ~600, got %v", got)
+	}
+	if got := ss.Query([]byte("negative")); math.Abs(got+50) > 3 {
+		t.Errorf("expected ~-50, got %v", got)
+	}
+}
+
+func TestSignedSketchMergeAndMarshal(t *testing.T) {
+	a, _ := NewSignedSketch(1000, 3, 1.00026)
+	b, _ := NewSignedSketch(1000, 3, 1.00026)
+	a.BulkUpdate([]byte("x"), 500)
+	b.BulkUpdate([]byte("x"), -200)
+	b.BulkUpdate([]byte("y"), 100)
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Query([]byte("x")); math.Abs(got-300) > 300*0.05 {
+		t.Errorf("expected ~300 after merge, got %v", got)
+	}
+	if got := a.Query([]byte("y")); math.Abs(got-100) > 5 {
+		t.Errorf("expected ~100 after merge, got %v", got)
+	}
+	other, _ := NewSignedSketch(1000, 3, 1.5)
+	if err := a.Merge(other); err == nil {
+		t.Error("expected error for different exp")
+	}
+
+	data, _ := a.MarshalBinary()
+	restored := &SignedSketch{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Query([]byte("x")) != a.Query([]byte("x")) {
+		t.Error("expected the same estimate after a round trip")
+	}
+	if err := (&Sketch{}).UnmarshalBinary(data); err == nil {
+		t.Error("expected a Sketch to reject a SignedSketch encoding")
+	}
+	if _, err := NewLikeSerialized(data); err == nil {
+		t.Error("expected NewLikeSerialized to reject a SignedSketch encoding")
+	}
+}
@@ -0,0 +1,107 @@
+package cml
+
+import "encoding"
+
+/*
+Sketcher is the common interface of the package's counting sketches, letting
+applications switch implementations via configuration
+*/
+type Sketcher interface {
+	// Insert counts one occurrence of the key.
+	Insert(key []byte) bool
+	// InsertN counts n occurrences of the key.
+	InsertN(key []byte, n uint) bool
+	// Estimate returns the estimated count of the key.
+	Estimate(key []byte) float64
+	// Clear forgets all counts.
+	Clear()
+
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+var (
+	_ Sketcher = (*Sketch)(nil)
+	_ Sketcher = (*Rotating)(nil)
+	_ Sketcher = (*KeySampler)(nil)
+)
+
+/*
+Insert is Update, for Sketcher
+*/
+func (cml *Sketch) Insert(key []byte) bool {
+	return cml.Update(key)
+}
+
+/*
+InsertN is BulkUpdate, for Sketcher
+*/
+func (cml *Sketch) InsertN(key []byte, n uint) bool {
+	return cml.BulkUpdate(key, n)
+}
+
+/*
+Estimate is Query, for Sketcher
+*/
+func (cml *Sketch) Estimate(key []byte) float64 {
+	return cml.Query(key)
+}
+
+/*
+Clear is Reset, for Sketcher
+*/
+func (cml *Sketch) Clear() {
+	cml.Reset()
+}
+
+/*
+Insert is Update, so sampling is not bypassed through the embedded sketch
+*/
+func (ks *KeySampler) Insert(key []byte) bool {
+	return ks.Update(key)
+}
+
+/*
+InsertN is BulkUpdate, so sampling is not bypassed through the embedded sketch
+*/
+func (ks *KeySampler) InsertN(key []byte, n uint) bool {
+	return ks.BulkUpdate(key, n)
+}
+
+/*
+Clear is Reset, so the reservoir is emptied as well
+*/
+func (ks *KeySampler) Clear() {
+	ks.Reset()
+}
+
+/*
+Insert is Update, for Sketcher
+*/
+func (r *Rotating) Insert(key []byte) bool {
+	return r.Update(key)
+}
+
+/*
+InsertN is BulkUpdate, for Sketcher
+*/
+func (r *Rotating) InsertN(key []byte, n uint) bool {
+	return r.BulkUpdate(key, n)
+}
+
+/*
+Estimate is QueryAll, for Sketcher
+*/
+func (r *Rotating) Estimate(key []byte) float64 {
+	return r.QueryAll(key)
+}
+
+/*
+Clear resets every interval and starts a new one now
+*/
+func (r *Rotating) Clear() {
+	for _, sk := range r.sketches {
+		sk.Reset()
+	}
+	r.epochs[r.head] = r.now()
+}
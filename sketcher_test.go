@@ -0,0 +1,77 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSketcherConformance(t *testing.T) {
+	implementations := map[string]func() Sketcher{
+		"Sketch": func() Sketcher {
+			sk, _ := NewSketch(1000, 4, 1.00026)
+			return sk
+		},
+		"Rotating": func() Sketcher {
+			r, _ := NewRotating(3, time.Hour, 1000, 4, 1.00026)
+			return r
+		},
+		"SafeSketch": func() Sketcher {
+			sk, _ := NewSketch(1000, 4, 1.00026)
+			return NewSafeSketch(sk)
+		},
+		"KeySampler": func() Sketcher {
+			sk, _ := NewSketch(1000, 4, 1.00026)
+			ks, _ := NewKeySampler(sk, 8, 1024)
+			return ks
+		},
+	}
+	for name, newSketcher := range implementations {
+		t.Run(name, func(t *testing.T) {
+			testSketcher(t, newSketcher)
+		})
+	}
+}
+
+func testSketcher(t *testing.T, newSketcher func() Sketcher) {
+	sk := newSketcher()
+	for i := 0; i < 10; i++ {
+		sk.Insert([]byte("once"))
+	}
+	sk.InsertN([]byte("bulk"), 1000)
+
+	if count := sk.Estimate([]byte("once")); math.Round(count) != 10 {
+		t.Errorf("expected 10, got %f", count)
+	}
+	if count := sk.Estimate([]byte("bulk")); count < 950 || count > 1050 {
+		t.Errorf("expected ~1000, got %f", count)
+	}
+	if count := sk.Estimate([]byte("unseen")); count != 0 {
+		t.Errorf("expected 0, got %f", count)
+	}
+
+	data, err := sk.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := newSketcher()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"once", "bulk", "unseen"} {
+		if got, expected := restored.Estimate([]byte(key)), sk.Estimate([]byte(key)); got != expected {
+			t.Errorf("expected %f for %s after round trip, got %f", expected, key, got)
+		}
+	}
+
+	sk.Clear()
+	for i := 0; i < 100; i++ {
+		if count := sk.Estimate([]byte(fmt.Sprint(i))); count != 0 {
+			t.Fatalf("expected 0 after Clear, got %f", count)
+		}
+	}
+	if count := sk.Estimate([]byte("bulk")); count != 0 {
+		t.Errorf("expected 0 after Clear, got %f", count)
+	}
+}
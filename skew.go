@@ -0,0 +1,56 @@
+package cml
+
+import (
+	"math"
+	"sort"
+)
+
+// minSkewRegisters is the least number of usable registers per row needed to
+// fit a power law with any confidence.
+const minSkewRegisters = 32
+
+/*
+EstimateSkew estimates the Zipf exponent of the stream by fitting a power law to the
+rank-frequency curve of the decoded registers of each row, and returns the median over rows.
+Zero and saturated registers are ignored. ok is false when the sketch is too empty
+(fewer than 32 usable registers per row) or too full (more than half the registers set,
+so collisions dominate) for the fit to be reliable.
+*/
+func (cml *Sketch) EstimateSkew() (zipfS float64, ok bool) {
+	if !cml.initialized() {
+		return 0, false
+	}
+	slopes := make([]float64, 0, cml.d)
+	values := make([]float64, 0, cml.w)
+	for _, row := range cml.logicalRows() {
+		values = values[:0]
+		for _, c := range row {
+			if c != 0 && c != math.MaxUint16 {
+				values = append(values, cml.value(c))
+			}
+		}
+		if len(values) < minSkewRegisters || uint(len(values)) > cml.w/2 {
+			return 0, false
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+		slopes = append(slopes, -fitLogLog(values))
+	}
+	sort.Float64s(slopes)
+	return slopes[len(slopes)/2], true
+}
+
+// fitLogLog returns the least-squares slope of log(values[i]) against log(i+1),
+// sampling ranks on a logarithmic grid so the long tail doesn't outweigh the head.
+func fitLogLog(values []float64) float64 {
+	var sx, sy, sxx, sxy, n float64
+	for r := 1.0; int(r) <= len(values); r *= 1.25 {
+		x := math.Log(math.Floor(r))
+		y := math.Log(values[int(r)-1])
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+		n++
+	}
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx)
+}
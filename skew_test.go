@@ -0,0 +1,42 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// feedZipf updates sk with n keys where the key of rank r occurs round(top * r^-s) times.
+func feedZipf(sk *Sketch, n int, top float64, s float64) {
+	for r := 1; r <= n; r++ {
+		if freq := uint(math.Round(top * math.Pow(float64(r), -s))); freq > 0 {
+			sk.BulkUpdate([]byte(fmt.Sprintf("zipf-%d", r)), freq)
+		}
+	}
+}
+
+func TestEstimateSkew(t *testing.T) {
+	for _, s := range []float64{0.8, 1.0, 1.2} {
+		sk, _ := NewSketch(100000, 3, 1.00026)
+		feedZipf(sk, 10000, 10000, s)
+		got, ok := sk.EstimateSkew()
+		if !ok {
+			t.Errorf("s=%.1f: expected estimate", s)
+			continue
+		}
+		if math.Abs(got-s) > 0.15 {
+			t.Errorf("s=%.1f: expected estimate within 0.15, got %f", s, got)
+		}
+	}
+}
+
+func TestEstimateSkewUnreliable(t *testing.T) {
+	sk, _ := NewSketch(1000, 3, 1.00026)
+	if _, ok := sk.EstimateSkew(); ok {
+		t.Error("expected empty sketch to be rejected")
+	}
+	feedZipf(sk, 10000, 10000, 1.0)
+	if _, ok := sk.EstimateSkew(); ok {
+		t.Error("expected overfull sketch to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package cml
+
+import (
+	"math"
+	"slices"
+)
+
+/*
+SketchStats is a snapshot of a sketch's dimensions and health.
+
+TotalUpdates counts the increments requested through Update, BulkUpdate or merged in
+with Merge; RejectedUpdates counts those that did not move a register, either by the
+probabilistic decision or because the registers were saturated. Both start from zero
+when a sketch is unmarshaled.
+*/
+type SketchStats struct {
+	Width              uint    `json:"width"`
+	Depth              uint    `json:"depth"`
+	Exp                float64 `json:"exp"`
+	FillRatePct        float64 `json:"fillRatePct"`
+	SaturatedRegisters uint64  `json:"saturatedRegisters"`
+	TotalUpdates       uint64  `json:"totalUpdates"`
+	RejectedUpdates    uint64  `json:"rejectedUpdates"`
+	StoreBytes         uint64  `json:"storeBytes"`
+	DoorkeeperBytes    uint64  `json:"doorkeeperBytes,omitempty"`
+}
+
+/*
+Stats returns the sketch's current statistics without scanning the store
+*/
+func (cml *Sketch) Stats() SketchStats {
+	registers := uint64(cml.w) * uint64(cml.d)
+	var fill float64
+	if registers > 0 {
+		fill = 100 * float64(cml.occupied) / float64(registers)
+	}
+	return SketchStats{
+		Width:              cml.w,
+		Depth:              cml.d,
+		Exp:                cml.exp,
+		FillRatePct:        fill,
+		SaturatedRegisters: cml.saturated,
+		TotalUpdates:       cml.total,
+		RejectedUpdates:    cml.rejected,
+		StoreBytes:         cml.residentBytes(),
+		DoorkeeperBytes:    8 * uint64(len(cml.doorkeeper)),
+	}
+}
+
+/*
+Occupied returns the number of non-zero registers without scanning the store. It is
+recomputed when a sketch is unmarshaled or merged.
+*/
+func (cml *Sketch) Occupied() uint64 {
+	return cml.occupied
+}
+
+/*
+IsEmpty reports whether every register and doorkeeper bit is zero, i.e. the sketch has counted
+nothing since it was created or last Reset
+*/
+func (cml *Sketch) IsEmpty() bool {
+	return cml.occupied == 0 && !slices.ContainsFunc(cml.doorkeeper, func(w uint64) bool { return w != 0 })
+}
+
+// track accounts for a register moving from one value to another in the
+// occupancy and saturation counters.
+func (cml *Sketch) track(from, to uint16) {
+	if from == 0 && to != 0 {
+		cml.occupied++
+	} else if from != 0 && to == 0 {
+		cml.occupied--
+	}
+	if from != math.MaxUint16 && to == math.MaxUint16 {
+		if cml.saturated == 0 {
+			cml.saturatedAt = cml.total
+		}
+		cml.saturated++
+	} else if from == math.MaxUint16 && to != math.MaxUint16 {
+		cml.saturated--
+	}
+}
+
+// recount recomputes the occupancy and saturation counters from the store
+// after operations that rewrite it wholesale.
+func (cml *Sketch) recount() {
+	saturatedAt := cml.saturatedAt
+	cml.occupied, cml.saturated = 0, 0
+	for _, row := range cml.store {
+		for _, c := range row {
+			cml.track(0, c)
+		}
+	}
+	// Keep the point of the first saturation if the store still has it.
+	if cml.saturated != 0 && saturatedAt != 0 {
+		cml.saturatedAt = saturatedAt
+	}
+}
@@ -0,0 +1,121 @@
+package cml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	if stats := sk.Stats(); stats != (SketchStats{Width: 1000, Depth: 4, Exp: 1.00026, StoreBytes: 8000}) {
+		t.Errorf("unexpected stats for an empty sketch: %+v", stats)
+	}
+
+	for i := 0; i < 10; i++ {
+		sk.Update([]byte(fmt.Sprint(i)))
+	}
+	sk.BulkUpdate([]byte("heavy"), 100000)
+	stats := sk.Stats()
+	if stats.TotalUpdates != 100010 {
+		t.Errorf("expected 100010 updates, got %d", stats.TotalUpdates)
+	}
+	if stats.RejectedUpdates == 0 || stats.RejectedUpdates >= stats.TotalUpdates {
+		t.Errorf("expected some but not all updates rejected, got %d", stats.RejectedUpdates)
+	}
+	if expected := 100 * float64(scanOccupied(sk)) / 4000; stats.FillRatePct != expected || expected == 0 {
+		t.Errorf("expected fill rate %f, got %f", expected, stats.FillRatePct)
+	}
+
+	sk.store[0][0] = math.MaxUint16
+	sk.recount()
+	if stats := sk.Stats(); stats.SaturatedRegisters != 1 {
+		t.Errorf("expected 1 saturated register, got %d", stats.SaturatedRegisters)
+	}
+
+	other, _ := NewSketch(1000, 4, 1.00026)
+	other.BulkUpdate([]byte("other"), 10)
+	if err := sk.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	stats = sk.Stats()
+	if stats.TotalUpdates != 100020 {
+		t.Errorf("expected 100020 updates after merge, got %d", stats.TotalUpdates)
+	}
+	if expected := 100 * float64(scanOccupied(sk)) / 4000; stats.FillRatePct != expected {
+		t.Errorf("expected fill rate %f after merge, got %f", expected, stats.FillRatePct)
+	}
+
+	data, _ := sk.MarshalBinary()
+	restored := &Sketch{}
+	restored.UnmarshalBinary(data)
+	if got := restored.Stats(); got.FillRatePct != stats.FillRatePct || got.SaturatedRegisters != 1 || got.TotalUpdates != 0 {
+		t.Errorf("unexpected stats after unmarshal: %+v", got)
+	}
+
+	if _, err := json.Marshal(stats); err != nil {
+		t.Error(err)
+	}
+}
+
+func scanOccupied(sk *Sketch) int {
+	n := 0
+	for _, row := range sk.store {
+		for _, c := range row {
+			if c != 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestIsEmpty(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	if !sk.IsEmpty() || sk.Occupied() != 0 {
+		t.Error("expected a new sketch to be empty")
+	}
+	sk.Update([]byte("a"))
+	if sk.IsEmpty() || sk.Occupied() != 4 {
+		t.Errorf("expected 4 occupied registers after one update, got %d", sk.Occupied())
+	}
+
+	data, _ := sk.MarshalBinary()
+	restored := &Sketch{}
+	restored.UnmarshalBinary(data)
+	if restored.Occupied() != 4 {
+		t.Errorf("expected occupancy to be recomputed on load, got %d", restored.Occupied())
+	}
+
+	empty, _ := NewSketch(1000, 4, 1.00026)
+	if err := empty.Merge(sk); err != nil {
+		t.Fatal(err)
+	}
+	if empty.IsEmpty() || empty.Occupied() != 4 {
+		t.Errorf("expected merging a non-empty sketch to occupy 4 registers, got %d", empty.Occupied())
+	}
+	other, _ := NewSketch(1000, 4, 1.00026)
+	sk.Merge(other)
+	if sk.Occupied() != 4 {
+		t.Errorf("expected merging an empty sketch to change nothing, got %d", sk.Occupied())
+	}
+
+	sk.Reset()
+	if !sk.IsEmpty() || sk.Occupied() != 0 {
+		t.Error("expected a reset sketch to be empty")
+	}
+	if !(&Sketch{}).IsEmpty() {
+		t.Error("expected a zero Sketch to be empty")
+	}
+
+	dk, _ := NewSketch(1000, 4, 1.00026, WithDoorkeeper(1<<10))
+	dk.Update([]byte("a"))
+	if dk.IsEmpty() || dk.Query([]byte("a")) != 1 {
+		t.Error("expected a key held by the doorkeeper to make the sketch non-empty")
+	}
+	dk.Reset()
+	if !dk.IsEmpty() {
+		t.Error("expected a reset doorkeeper sketch to be empty")
+	}
+}
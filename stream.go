@@ -0,0 +1,215 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+var (
+	_ io.WriterTo   = (*Sketch)(nil)
+	_ io.ReaderFrom = (*Sketch)(nil)
+)
+
+/*
+WriteTo writes the MarshalBinary encoding of the sketch to w, streaming the registers through a
+small buffer instead of encoding the whole sketch in memory first. It returns the number of
+bytes written.
+*/
+func (cml *Sketch) WriteTo(w io.Writer) (int64, error) {
+	if !cml.initialized() {
+		return 0, ErrUninitialized
+	}
+	var written int64
+	write := func(b []byte) error {
+		n, err := w.Write(b)
+		written += int64(n)
+		return err
+	}
+	if err := write(cml.appendPrefix(nil, byteOrderLittle)); err != nil {
+		return written, err
+	}
+	var buf, zeros []byte
+	for i, row := range cml.store {
+		switch {
+		case row == nil:
+			if zeros == nil {
+				zeros = make([]byte, 2*pageRegisters)
+			}
+			if err := write(zeros[:2*cml.chunkLen(i)]); err != nil {
+				return written, err
+			}
+		case hostOrder == binary.LittleEndian:
+			if err := write(registerBytes(row)); err != nil {
+				return written, err
+			}
+		default:
+			if buf == nil {
+				buf = make([]byte, 0, mergeChunkSize)
+			}
+			for len(row) > 0 {
+				n := min(len(row), cap(buf)/2)
+				buf = buf[:0]
+				for _, c := range row[:n] {
+					buf = binary.LittleEndian.AppendUint16(buf, c)
+				}
+				if err := write(buf); err != nil {
+					return written, err
+				}
+				row = row[n:]
+			}
+		}
+	}
+	return written, nil
+}
+
+/*
+ReadFrom replaces the sketch with the MarshalBinary encoding read from r, like UnmarshalBinary,
+reading the registers into the new store in chunks instead of holding the whole encoding in
+memory. r must hold exactly one encoding: a stream ending early fails with ErrTruncated and one
+continuing past it with ErrTrailingData. The sketch is left as it was on failure. It returns the
+number of bytes read.
+*/
+func (cml *Sketch) ReadFrom(r io.Reader) (int64, error) {
+	sr := &streamReader{r: r}
+	err := cml.readFrom(sr)
+	return sr.n, err
+}
+
+func (cml *Sketch) readFrom(r *streamReader) error {
+	var hdr [headerSize]byte
+	if err := r.read(hdr[:]); err != nil {
+		return err
+	}
+	order, err := sketchHeader(hdr[:])
+	if err != nil {
+		return err
+	}
+	var (
+		w     = order.Uint64(hdr[8:])
+		d     = order.Uint64(hdr[16:])
+		exp   = math.Float64frombits(order.Uint64(hdr[24:]))
+		hash  = hashing(hdr[1])
+		flags = hdr[2]
+	)
+	if err := validateParams(w, d, exp, hash, flags); err != nil {
+		return err
+	}
+	if w > math.MaxInt/2/d {
+		return errors.New("sketch dimensions too large")
+	}
+
+	var md map[string]string
+	if flags&flagMetadata != 0 {
+		var size [4]byte
+		if err := r.read(size[:]); err != nil {
+			return err
+		}
+		n := order.Uint32(size[:])
+		if uint64(n) > uint64(cml.metadataLimit()) {
+			return ErrMetadataTooLarge
+		}
+		block := append(size[:], make([]byte, n)...)
+		if err := r.read(block[4:]); err != nil {
+			return err
+		}
+		if md, _, err = parseMetadata(block, order, cml.metadataLimit()); err != nil {
+			return err
+		}
+	}
+
+	// The doorkeeper and the store grow as their words arrive rather than being
+	// allocated from the header, so a truncated stream fails before a huge allocation.
+	words, err := doorkeeperHeader(hdr[:])
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, min(mergeChunkSize, max(2*w*d, 8*uint64(words))))
+	var dk []uint64
+	for len(dk) < words {
+		m := min(words-len(dk), len(buf)/8)
+		if err := r.read(buf[:8*m]); err != nil {
+			return err
+		}
+		for j := range m {
+			dk = append(dk, order.Uint64(buf[8*j:]))
+		}
+	}
+
+	dec := cml.decoding(w, d, exp, hash, flags)
+	total := int(w * d)
+	if dec.paged {
+		for i := 0; i*pageRegisters < total; i++ {
+			// A page fits the buffer.
+			n := 2 * min(pageRegisters, total-i*pageRegisters)
+			if err := r.read(buf[:n]); err != nil {
+				return err
+			}
+			dec.store = append(dec.store, nil)
+			dec.decodeChunk(i, buf[:n], order)
+		}
+	} else {
+		var registers []uint16
+		for len(registers) < total {
+			m := min(total-len(registers), len(buf)/2)
+			registers = growRegisters(registers, m, total)
+			chunk := registers[len(registers)-m:]
+			if order == hostOrder {
+				if err := r.read(registerBytes(chunk)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := r.read(buf[:2*m]); err != nil {
+				return err
+			}
+			for j := range chunk {
+				chunk[j] = order.Uint16(buf[2*j:])
+			}
+		}
+		dec.store = rowsOf(registers, dec.w, dec.d)
+	}
+
+	var extra [1]byte
+	n, err := io.ReadFull(r.r, extra[:])
+	r.n += int64(n)
+	if n > 0 {
+		return ErrTrailingData
+	}
+	if err != io.EOF {
+		return err
+	}
+	if err := cml.adopt(dec); err != nil {
+		return err
+	}
+	cml.metadata = md
+	cml.doorkeeper = dk
+	return nil
+}
+
+// growRegisters extends registers by n, doubling its capacity up to limit.
+func growRegisters(registers []uint16, n, limit int) []uint16 {
+	if len(registers)+n > cap(registers) {
+		grown := make([]uint16, len(registers), min(limit, max(2*cap(registers), len(registers)+n)))
+		copy(grown, registers)
+		registers = grown
+	}
+	return registers[:len(registers)+n]
+}
+
+// streamReader reads an encoding from r, counting the bytes read.
+type streamReader struct {
+	r io.Reader
+	n int64
+}
+
+// read fills b, failing with ErrTruncated if r ends first.
+func (sr *streamReader) read(b []byte) error {
+	n, err := io.ReadFull(sr.r, b)
+	sr.n += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
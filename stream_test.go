@@ -0,0 +1,127 @@
+package cml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"plain":      nil,
+		"doorkeeper": {WithDoorkeeper(1 << 10)},
+		"banded":     {WithBandedLayout(true)},
+		"paged":      {WithPagedStore()},
+	} {
+		sk, _ := NewSketch(50000, 4, 1.00026, opts...)
+		for i := 0; i < 1000; i++ {
+			sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%10+1))
+		}
+		sk.SetMetadata("name", name)
+
+		var buf bytes.Buffer
+		n, err := sk.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := sk.MarshalBinary()
+		if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: expected WriteTo to write the %d bytes of MarshalBinary, got %d", name, len(data), n)
+		}
+
+		restored := &Sketch{}
+		if n, err := restored.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+			t.Fatalf("%s: expected to read %d bytes, got %d and %v", name, len(data), n, err)
+		}
+		if again, _ := restored.MarshalBinary(); !bytes.Equal(again, data) {
+			t.Errorf("%s: expected ReadFrom to restore the sketch", name)
+		}
+		be, _ := sk.MarshalBinaryBigEndian()
+		if _, err := restored.ReadFrom(bytes.NewReader(be)); err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := restored.MarshalBinary(); !bytes.Equal(again, data) {
+			t.Errorf("%s: expected ReadFrom to restore a big-endian encoding", name)
+		}
+	}
+}
+
+func TestWriteToGolden(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "sketch_le.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := goldenSketch().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("expected the golden encoding:\n got %x\nwant %x", buf.Bytes(), golden)
+	}
+	restored := &Sketch{}
+	if _, err := restored.ReadFrom(bytes.NewReader(golden)); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := restored.MarshalBinary(); !bytes.Equal(again, golden) {
+		t.Error("expected ReadFrom to decode the golden encoding")
+	}
+}
+
+func TestReadFromInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 2, 1.00026)
+	sk.Update([]byte("a"))
+	data, _ := sk.MarshalBinary()
+	// A header claiming 2^40 registers followed by a few of them.
+	huge := append([]byte(nil), data[:headerSize+64]...)
+	hostOrder.PutUint64(huge[8:], 1<<40)
+	hostOrder.PutUint64(huge[16:], 1)
+	// A header declaring the largest doorkeeper followed by a few of its words.
+	hugeDoorkeeper := append([]byte(nil), data[:headerSize+64]...)
+	hugeDoorkeeper[2] |= flagDoorkeeper
+	hugeDoorkeeper[5], hugeDoorkeeper[6], hugeDoorkeeper[7] = 0xff, 0xff, 0xff
+
+	tests := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"empty":           {nil, ErrTruncated},
+		"short header":    {data[:10], ErrTruncated},
+		"short registers": {data[:len(data)-3], ErrTruncated},
+		"trailing data":   {append(append([]byte(nil), data...), 0), ErrTrailingData},
+		"huge dimensions": {huge, ErrTruncated},
+		"huge doorkeeper": {hugeDoorkeeper, ErrTruncated},
+	}
+	for name, tc := range tests {
+		restored, _ := NewSketch(10, 1, 1.00026)
+		restored.Update([]byte("b"))
+		if _, err := restored.ReadFrom(bytes.NewReader(tc.data)); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+		if restored.w != 10 || restored.Query([]byte("b")) == 0 {
+			t.Errorf("%s: expected a failed read to leave the sketch as it was", name)
+		}
+	}
+}
+
+func BenchmarkMarshalBinaryToWriter(b *testing.B) {
+	sk, _ := NewSketch(1000000, 8, 1.00026)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, _ := sk.MarshalBinary()
+		io.Discard.Write(data)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	sk, _ := NewSketch(1000000, 8, 1.00026)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk.WriteTo(io.Discard)
+	}
+}
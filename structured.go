@@ -0,0 +1,94 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+)
+
+/*
+SketchDTO is a plain representation of a sketch for structured codecs such as CBOR,
+msgpack or JSON, letting consumers inspect the dimensions without decoding the store.
+Store holds the registers as little-endian values of RegisterBits bits in the order of the
+binary encoding: row by row, or column by column if Flags has the banded bit set. Hash
+identifies the hashing scheme and Flags the modes as in the binary encoding. Total and Seed,
+which the binary encoding leaves out, carry the number of updates counted and the seed set
+with WithSeed, if any. Metadata holds the metadata entries and Doorkeeper the doorkeeper's
+bits as 64-bit words, as in the binary encoding.
+*/
+type SketchDTO struct {
+	W            uint64  `json:"w" cbor:"w" msgpack:"w"`
+	D            uint64  `json:"d" cbor:"d" msgpack:"d"`
+	Exp          float64 `json:"exp" cbor:"exp" msgpack:"exp"`
+	RegisterBits uint8   `json:"registerBits" cbor:"registerBits" msgpack:"registerBits"`
+	Hash         uint8   `json:"hash" cbor:"hash" msgpack:"hash"`
+	Flags        uint8   `json:"flags,omitempty" cbor:"flags,omitempty" msgpack:"flags,omitempty"`
+	Store        []byte  `json:"store" cbor:"store" msgpack:"store"`
+	Total        uint64  `json:"total,omitempty" cbor:"total,omitempty" msgpack:"total,omitempty"`
+	Seed         *uint64 `json:"seed,omitempty" cbor:"seed,omitempty" msgpack:"seed,omitempty"`
+
+	Metadata   map[string]string `json:"metadata,omitempty" cbor:"metadata,omitempty" msgpack:"metadata,omitempty"`
+	Doorkeeper []uint64          `json:"doorkeeper,omitempty" cbor:"doorkeeper,omitempty" msgpack:"doorkeeper,omitempty"`
+}
+
+/*
+MarshalStructured returns the sketch as a SketchDTO
+*/
+func (cml *Sketch) MarshalStructured() SketchDTO {
+	store := make([]byte, 0, 2*cml.w*cml.d)
+	for i, row := range cml.store {
+		if row == nil {
+			store = append(store, make([]byte, 2*cml.chunkLen(i))...)
+		}
+		for _, c := range row {
+			store = binary.LittleEndian.AppendUint16(store, c)
+		}
+	}
+	dto := SketchDTO{
+		W:            uint64(cml.w),
+		D:            uint64(cml.d),
+		Exp:          cml.exp,
+		RegisterBits: 16,
+		Hash:         uint8(cml.hashing),
+		Flags:        cml.flags(),
+		Store:        store,
+		Total:        cml.total,
+		Metadata:     maps.Clone(cml.metadata),
+		Doorkeeper:   slices.Clone(cml.doorkeeper),
+	}
+	if cml.rnd.seeded {
+		seed := cml.rnd.seed
+		dto.Seed = &seed
+	}
+	return dto
+}
+
+/*
+FromStructured returns the sketch described by dto, validated like UnmarshalBinary. A seed
+restarts the sketch's random increments from it.
+*/
+func FromStructured(dto SketchDTO) (*Sketch, error) {
+	if dto.RegisterBits != 16 {
+		return nil, errors.New("sketch registers must be 16 bits wide")
+	}
+	if err := checkMetadata(dto.Metadata, DefaultMaxMetadataSize); err != nil {
+		return nil, err
+	}
+	if len(dto.Doorkeeper) > maxDoorkeeperWords {
+		return nil, errors.New("sketch doorkeeper too large")
+	}
+	cml := &Sketch{}
+	if err := cml.decode(dto.W, dto.D, dto.Exp, hashing(dto.Hash), dto.Flags, binary.LittleEndian, dto.Store); err != nil {
+		return nil, err
+	}
+	cml.total = dto.Total
+	if len(dto.Metadata) > 0 {
+		cml.metadata = maps.Clone(dto.Metadata)
+	}
+	cml.doorkeeper = slices.Clone(dto.Doorkeeper)
+	if dto.Seed != nil {
+		cml.rnd = newRNG(*dto.Seed)
+	}
+	return cml, nil
+}
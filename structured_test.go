@@ -0,0 +1,107 @@
+package cml
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestStructuredCBOR(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 100)
+
+	data, err := cbor.Marshal(sk.MarshalStructured())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dto SketchDTO
+	if err := cbor.Unmarshal(data, &dto); err != nil {
+		t.Fatal(err)
+	}
+	if dto.W != 100 || dto.D != 3 || dto.RegisterBits != 16 {
+		t.Errorf("expected 100x3 uint16 sketch, got %dx%d with %d bits", dto.W, dto.D, dto.RegisterBits)
+	}
+	restored, err := FromStructured(dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := sk.MarshalBinary()
+	if got, _ := restored.MarshalBinary(); !bytes.Equal(got, expected) {
+		t.Error("expected identical sketch after round trip")
+	}
+}
+
+func TestStructuredTotalAndSeed(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026, WithSeed(42))
+	sk.BulkUpdate([]byte("a"), 100)
+	dto := sk.MarshalStructured()
+	if dto.Total != 100 || dto.Seed == nil || *dto.Seed != 42 {
+		t.Fatalf("expected total 100 and seed 42, got %d and %v", dto.Total, dto.Seed)
+	}
+	restored, err := FromStructured(dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Stats().TotalUpdates != 100 || !restored.rnd.seeded || restored.rnd.seed != 42 {
+		t.Error("expected the total and seed to be restored")
+	}
+
+	unseeded, _ := NewSketch(100, 3, 1.00026)
+	if dto := unseeded.MarshalStructured(); dto.Seed != nil {
+		t.Errorf("expected no seed for an unseeded sketch, got %d", *dto.Seed)
+	}
+}
+
+func TestStructuredDoorkeeperAndMetadata(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026, WithDoorkeeper(1<<10))
+	sk.SetMetadata("tenant", "a")
+	sk.Update([]byte("once"))
+
+	data, err := cbor.Marshal(sk.MarshalStructured())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dto SketchDTO
+	if err := cbor.Unmarshal(data, &dto); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := FromStructured(dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := sk.MarshalBinary()
+	if got, _ := restored.MarshalBinary(); !bytes.Equal(got, expected) {
+		t.Error("expected the doorkeeper and metadata to survive the round trip")
+	}
+	if got := restored.Query([]byte("once")); got != 1 {
+		t.Errorf("expected the doorkept key to estimate 1, got %f", got)
+	}
+
+	dto.Doorkeeper = make([]uint64, maxDoorkeeperWords+1)
+	if _, err := FromStructured(dto); err == nil {
+		t.Error("expected error for an oversized doorkeeper")
+	}
+	dto.Doorkeeper = nil
+	dto.Metadata = map[string]string{"big": string(make([]byte, DefaultMaxMetadataSize))}
+	if _, err := FromStructured(dto); err != ErrMetadataTooLarge {
+		t.Errorf("expected ErrMetadataTooLarge, got %v", err)
+	}
+}
+
+func TestFromStructuredInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	for name, fn := range map[string]func(dto *SketchDTO){
+		"register bits": func(dto *SketchDTO) { dto.RegisterBits = 8 },
+		"width":         func(dto *SketchDTO) { dto.W = 101 },
+		"zero depth":    func(dto *SketchDTO) { dto.D = 0 },
+		"exp":           func(dto *SketchDTO) { dto.Exp = 0.5 },
+		"store":         func(dto *SketchDTO) { dto.Store = dto.Store[1:] },
+	} {
+		dto := sk.MarshalStructured()
+		fn(&dto)
+		if _, err := FromStructured(dto); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
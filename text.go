@@ -0,0 +1,53 @@
+package cml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+/*
+The text encoding is "cml1:<register type>:" followed by the standard base64 of the MarshalBinary encoding
+*/
+const textPrefix = "cml1:uint16:"
+
+var (
+	_ encoding.TextMarshaler   = (*Sketch)(nil)
+	_ encoding.TextUnmarshaler = (*Sketch)(nil)
+)
+
+/*
+MarshalText encodes the sketch as text, e.g. for config snapshots or JSON values
+*/
+func (cml *Sketch) MarshalText() ([]byte, error) {
+	data, err := cml.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, len(textPrefix)+base64.StdEncoding.EncodedLen(len(data)))
+	copy(b, textPrefix)
+	base64.StdEncoding.Encode(b[len(textPrefix):], data)
+	return b, nil
+}
+
+/*
+UnmarshalText restores a sketch encoded by MarshalText, ignoring surrounding whitespace
+*/
+func (cml *Sketch) UnmarshalText(text []byte) error {
+	text = bytes.TrimSpace(text)
+	parts := bytes.SplitN(text, []byte(":"), 3)
+	if len(parts) != 3 || string(parts[0]) != "cml1" {
+		return errors.New("not a text-encoded sketch")
+	}
+	if string(parts[1]) != "uint16" {
+		return fmt.Errorf("text-encoded sketch has %q registers, expected uint16", parts[1])
+	}
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(parts[2])))
+	n, err := base64.StdEncoding.Decode(data, parts[2])
+	if err != nil {
+		return err
+	}
+	return cml.UnmarshalBinary(data[:n])
+}
@@ -0,0 +1,63 @@
+package cml
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalText(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 100)
+
+	text, err := sk.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(text), "cml1:uint16:") {
+		t.Errorf("expected cml1:uint16: prefix, got %.20s", text)
+	}
+
+	other := &Sketch{}
+	if err := other.UnmarshalText(append(append([]byte(" \n"), text...), '\n')); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := sk.MarshalBinary()
+	if got, _ := other.MarshalBinary(); !bytes.Equal(got, expected) {
+		t.Error("expected identical sketch after round trip")
+	}
+
+	for _, bad := range []string{
+		"",
+		"cml2:uint16:" + string(text[len("cml1:uint16:"):]),
+		"cml1:uint8:" + string(text[len("cml1:uint16:"):]),
+		"cml1:uint16:!!!",
+		"cml1:uint16:AAAA",
+	} {
+		if err := other.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("expected error for %.20q", bad)
+		}
+	}
+}
+
+func TestMarshalTextJSON(t *testing.T) {
+	type snapshot struct {
+		Name   string  `json:"name"`
+		Sketch *Sketch `json:"sketch"`
+	}
+	sk, _ := NewSketch(100, 3, 1.00026)
+	sk.BulkUpdate([]byte("a"), 100)
+
+	data, err := json.Marshal(snapshot{Name: "tenant", Sketch: sk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored snapshot
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Sketch == nil || restored.Sketch.Query([]byte("a")) != sk.Query([]byte("a")) {
+		t.Error("expected the sketch to survive a JSON round trip")
+	}
+}
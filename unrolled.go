@@ -0,0 +1,84 @@
+package cml
+
+import "math"
+
+// maxUnrolledDepth is the largest depth whose probes are unrolled rather than
+// looped over, which covers most sketches.
+const maxUnrolledDepth = 4
+
+// maxProbes is the largest depth whose probes fit the caller's array rather
+// than an allocation, covering the depths the capacity constructors pick.
+const maxProbes = 16
+
+// probe returns the registers probed for the hashed key, one per row, and
+// their minimum. Depths up to maxProbes fill p instead of allocating.
+func (cml *Sketch) probe(h keyHash, p *[maxProbes]*uint16) ([]*uint16, uint16) {
+	switch cml.d {
+	case 1:
+		p[0] = cml.cell(0, cml.column(h, 0))
+		return p[:1], *p[0]
+	case 2:
+		p[0] = cml.cell(0, cml.column(h, 0))
+		p[1] = cml.cell(1, cml.column(h, 1))
+		return p[:2], min(*p[0], *p[1])
+	case 3:
+		p[0] = cml.cell(0, cml.column(h, 0))
+		p[1] = cml.cell(1, cml.column(h, 1))
+		p[2] = cml.cell(2, cml.column(h, 2))
+		return p[:3], min(*p[0], *p[1], *p[2])
+	case 4:
+		p[0] = cml.cell(0, cml.column(h, 0))
+		p[1] = cml.cell(1, cml.column(h, 1))
+		p[2] = cml.cell(2, cml.column(h, 2))
+		p[3] = cml.cell(3, cml.column(h, 3))
+		return p[:4], min(*p[0], *p[1], *p[2], *p[3])
+	}
+	return cml.probeLoop(h, p)
+}
+
+// probeLoop is probe for any depth.
+func (cml *Sketch) probeLoop(h keyHash, p *[maxProbes]*uint16) ([]*uint16, uint16) {
+	var sk []*uint16
+	if cml.d <= maxProbes {
+		sk = p[:cml.d]
+	} else {
+		sk = make([]*uint16, cml.d)
+	}
+	c := uint16(math.MaxUint16)
+	for i := range sk {
+		if sk[i] = cml.cell(uint(i), cml.column(h, i)); *sk[i] < c {
+			c = *sk[i]
+		}
+	}
+	return sk, c
+}
+
+// minRegister returns the smallest register probed for the hashed key, or 0
+// for a zero Sketch. Depths up to maxUnrolledDepth are unrolled.
+func (cml *Sketch) minRegister(h keyHash) uint16 {
+	if !cml.initialized() {
+		return 0
+	}
+	switch cml.d {
+	case 1:
+		return cml.at(0, cml.column(h, 0))
+	case 2:
+		return min(cml.at(0, cml.column(h, 0)), cml.at(1, cml.column(h, 1)))
+	case 3:
+		return min(cml.at(0, cml.column(h, 0)), cml.at(1, cml.column(h, 1)), cml.at(2, cml.column(h, 2)))
+	case 4:
+		return min(cml.at(0, cml.column(h, 0)), cml.at(1, cml.column(h, 1)), cml.at(2, cml.column(h, 2)), cml.at(3, cml.column(h, 3)))
+	}
+	return cml.minRegisterLoop(h)
+}
+
+// minRegisterLoop is minRegister for any depth.
+func (cml *Sketch) minRegisterLoop(h keyHash) uint16 {
+	c := uint16(math.MaxUint16)
+	for i := range cml.d {
+		if sk := cml.at(i, cml.column(h, int(i))); sk < c {
+			c = sk
+		}
+	}
+	return c
+}
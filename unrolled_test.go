@@ -0,0 +1,101 @@
+package cml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func benchmarkDepth(b *testing.B, d uint, query bool) {
+	sk, _ := NewSketch(100000, d, 1.00026)
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if query {
+			sk.Query(keys[i%len(keys)])
+		} else {
+			sk.Update(keys[i%len(keys)])
+		}
+	}
+}
+
+func BenchmarkUpdateDepth2(b *testing.B) { benchmarkDepth(b, 2, false) }
+func BenchmarkUpdateDepth4(b *testing.B) { benchmarkDepth(b, 4, false) }
+func BenchmarkQueryDepth2(b *testing.B)  { benchmarkDepth(b, 2, true) }
+func BenchmarkQueryDepth4(b *testing.B)  { benchmarkDepth(b, 4, true) }
+
+func TestUnrolledProbes(t *testing.T) {
+	for d := uint(1); d <= maxUnrolledDepth+1; d++ {
+		for _, opts := range [][]Option{nil, {WithBandedLayout(true)}, {WithPagedStore()}} {
+			sk, _ := NewSketch(500, d, 1.00026, opts...)
+			for i := 0; i < 3000; i++ {
+				sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%20+1))
+			}
+			for i := 0; i < 4000; i++ {
+				h := sk.hash([]byte(fmt.Sprint(i)))
+				if a, b := sk.minRegister(h), sk.minRegisterLoop(h); a != b {
+					t.Fatalf("d=%d: expected register %d for %d, got %d", d, b, i, a)
+				}
+				var p, q [maxProbes]*uint16
+				fast, fc := sk.probe(h, &p)
+				slow, sc := sk.probeLoop(h, &q)
+				if fc != sc || len(fast) != len(slow) {
+					t.Fatalf("d=%d: expected %d probes at %d for %d, got %d at %d", d, len(slow), sc, i, len(fast), fc)
+				}
+				for j := range fast {
+					if fast[j] != slow[j] {
+						t.Fatalf("d=%d: expected the same register in row %d for %d", d, j, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestUnrolledUpdatesReproducible(t *testing.T) {
+	for d := uint(1); d <= maxUnrolledDepth; d++ {
+		a, _ := NewSketch(500, d, 1.00026, WithDeterministic(true))
+		b, _ := NewSketch(500, d, 1.00026, WithDeterministic(true))
+		for i := 0; i < 3000; i++ {
+			key := []byte(fmt.Sprint(i % 700))
+			a.Update(key)
+			// Update b through the loop's probes, as it was done before unrolling.
+			var p [maxProbes]*uint16
+			sk, _ := b.probeLoop(b.hash(key), &p)
+			c := *sk[0]
+			for _, r := range sk {
+				c = min(c, *r)
+			}
+			if b.increaseDecision(c) {
+				for _, r := range sk {
+					if *r == c {
+						b.track(c, c+1)
+						*r = c + 1
+					}
+				}
+			}
+		}
+		for row := uint(0); row < d; row++ {
+			for col := uint(0); col < 500; col++ {
+				x, _ := a.GetRegister(row, col)
+				y, _ := b.GetRegister(row, col)
+				if x != y {
+					t.Fatalf("d=%d: expected register %d,%d to be %d, got %d", d, row, col, y, x)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdateAllocs(t *testing.T) {
+	k, _ := OptimalDepth(1000000, 0.01)
+	for _, d := range []uint{1, 4, k, maxProbes} {
+		sk, _ := NewSketch(1000, d, 1.00026)
+		key := []byte("key")
+		if allocs := testing.AllocsPerRun(100, func() { sk.Update(key) }); allocs != 0 {
+			t.Errorf("d=%d: expected no allocations per update, got %f", d, allocs)
+		}
+	}
+}
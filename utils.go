@@ -2,11 +2,55 @@ package cml
 
 import "github.com/dgryski/go-pcgr"
 
-var rnd = pcgr.Rand{
-	State: 0x0ddc0ffeebadf00d,
-	Inc:   0xcafebabe,
+// rngStream selects the PCG stream every generator draws from; seeds pick the
+// position in it.
+const rngStream = 0xcafebabe
+
+// rng is a sketch's own random number generator, so that sketches updated
+// from different goroutines share no state and each one's updates depend only
+// on its seed and its own stream of updates.
+type rng struct {
+	pcgr.Rand
+	seed   uint64
+	seeded bool
+}
+
+// newRNG returns a generator seeded with seed.
+func newRNG(seed uint64) rng {
+	g := rng{seed: seed, seeded: true}
+	g.SeedWithState(int64(seed), rngStream)
+	return g
+}
+
+// dimensionSeed is the seed of a sketch that was not given one, derived from
+// its dimensions so that equal sketches fed equal streams stay equal.
+func dimensionSeed(w, d uint) uint64 {
+	return fmix64(uint64(w)<<8 ^ uint64(d))
+}
+
+// uint32 returns a uniform 32-bit number, seeding the generator with seed
+// first if it has not been seeded.
+func (g *rng) uint32(seed uint64) uint32 {
+	if !g.seeded {
+		*g = newRNG(seed)
+	}
+	return g.Next()
+}
+
+// float returns a uniform number in [0, 1) with the full 53 bits of a
+// float64's precision, seeding the generator like uint32. Fewer bits would
+// round the tiny increment probabilities of high registers.
+func (g *rng) float(seed uint64) float64 {
+	x := uint64(g.uint32(seed))<<32 | uint64(g.Next())
+	return float64(x>>11) / (1 << 53)
+}
+
+// randFloat returns a uniform number in [0, 1) from the sketch's generator.
+func (cml *Sketch) randFloat() float64 {
+	return cml.rnd.float(dimensionSeed(cml.w, cml.d))
 }
 
-func randFloat() float64 {
-	return float64(rnd.Next()%10e5) / 10e5
+// randUint32 returns a uniform 32-bit number from the sketch's generator.
+func (cml *Sketch) randUint32() uint32 {
+	return cml.rnd.uint32(dimensionSeed(cml.w, cml.d))
 }
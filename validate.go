@@ -0,0 +1,121 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+InvariantError is returned by Validate and Repair for a sketch whose fields are inconsistent
+*/
+type InvariantError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("sketch invariant violated: %s %s", e.Field, e.Reason)
+}
+
+/*
+Validate checks the sketch's internal consistency: the store matches the dimensions, exp is
+valid and logExp derived from it, the hashing scheme is known, the modes are consistent and
+the occupancy counters match the registers. It scans the store once and is meant for
+sketches loaded from untrusted or old storage.
+*/
+func (cml *Sketch) Validate() error {
+	if err := cml.validateShape(); err != nil {
+		return err
+	}
+	if cml.logExp != math.Log1p(cml.exp-1) {
+		return &InvariantError{Field: "logExp", Reason: "does not match exp"}
+	}
+	if !cml.carryValid() {
+		return &InvariantError{Field: "carry", Reason: "is inconsistent with the deterministic mode"}
+	}
+	if occupied, saturated := cml.scanCounters(); occupied != cml.occupied || saturated != cml.saturated {
+		return &InvariantError{Field: "counters", Reason: "do not match the registers"}
+	}
+	return nil
+}
+
+/*
+Repair fixes the fields that can be derived from the rest of the sketch (logExp, the
+deterministic carry and the occupancy counters) and returns the names of those it changed.
+It fails, changing nothing, if the sketch is inconsistent beyond that.
+*/
+func (cml *Sketch) Repair() ([]string, error) {
+	if err := cml.validateShape(); err != nil {
+		return nil, err
+	}
+	if cml.readOnly {
+		return nil, ErrReadOnly
+	}
+	var changed []string
+	if logExp := math.Log1p(cml.exp - 1); cml.logExp != logExp {
+		cml.logExp = logExp
+		changed = append(changed, "logExp")
+	}
+	if !cml.carryValid() {
+		cml.carry = 0
+		changed = append(changed, "carry")
+	}
+	if occupied, saturated := cml.scanCounters(); occupied != cml.occupied || saturated != cml.saturated {
+		cml.recount()
+		changed = append(changed, "counters")
+	}
+	return changed, nil
+}
+
+// validateShape checks the invariants Repair cannot restore.
+func (cml *Sketch) validateShape() error {
+	if !cml.initialized() {
+		return ErrUninitialized
+	}
+	chunks := cml.d
+	if cml.paged {
+		chunks = (cml.w*cml.d + pageRegisters - 1) / pageRegisters
+	}
+	if cml.w == 0 || uint(len(cml.store)) != chunks {
+		return &InvariantError{Field: "store", Reason: "does not match the depth"}
+	}
+	for i, row := range cml.store {
+		if uint(len(row)) != cml.chunkLen(i) && !(cml.paged && row == nil) {
+			return &InvariantError{Field: "store", Reason: "does not match the width"}
+		}
+	}
+	if !(cml.exp > 1) || math.IsInf(cml.exp, 1) {
+		return &InvariantError{Field: "exp", Reason: "is not > 1 and finite"}
+	}
+	if cml.hashing > hashFNV128 {
+		return &InvariantError{Field: "hashing", Reason: "is unknown"}
+	}
+	if cml.maxKeyLength < 0 {
+		return &InvariantError{Field: "maxKeyLength", Reason: "is negative"}
+	}
+	return nil
+}
+
+// carryValid reports whether the deterministic carry is a fraction of a step,
+// and zero outside deterministic mode.
+func (cml *Sketch) carryValid() bool {
+	if !cml.deterministic {
+		return cml.carry == 0
+	}
+	return cml.carry >= 0 && cml.carry < 1
+}
+
+// scanCounters counts the occupied and saturated registers.
+func (cml *Sketch) scanCounters() (occupied, saturated uint64) {
+	for _, row := range cml.store {
+		for _, c := range row {
+			if c != 0 {
+				occupied++
+			}
+			if c == math.MaxUint16 {
+				saturated++
+			}
+		}
+	}
+	return occupied, saturated
+}
@@ -0,0 +1,73 @@
+package cml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026, WithDeterministic(true))
+	for i := 0; i < 500; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%7+1))
+	}
+	if err := sk.Validate(); err != nil {
+		t.Fatalf("expected a valid sketch, got %v", err)
+	}
+
+	for name, corrupt := range map[string]func(*Sketch){
+		"logExp":   func(s *Sketch) { s.logExp *= 2 },
+		"carry":    func(s *Sketch) { s.carry = 1.5 },
+		"counters": func(s *Sketch) { s.store[1][2], s.store[2][3] = 0, math.MaxUint16 },
+		"store":    func(s *Sketch) { s.store = s.store[:2] },
+		"exp":      func(s *Sketch) { s.exp = math.Inf(1) },
+		"hashing":  func(s *Sketch) { s.hashing = 42 },
+	} {
+		c := sk.Clone()
+		corrupt(c)
+		var ie *InvariantError
+		if err := c.Validate(); !errors.As(err, &ie) || ie.Field != name {
+			t.Errorf("%s: expected an invariant error, got %v", name, err)
+		}
+	}
+
+	if err := (&Sketch{}).Validate(); err != ErrUninitialized {
+		t.Errorf("expected ErrUninitialized, got %v", err)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	sk, _ := NewSketch(100, 3, 1.00026)
+	for i := 0; i < 500; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%7+1))
+	}
+	if changed, err := sk.Repair(); err != nil || len(changed) != 0 {
+		t.Errorf("expected nothing to repair, got %v, %v", changed, err)
+	}
+
+	broken := sk.Clone()
+	broken.logExp = 0
+	broken.carry = 0.5
+	broken.occupied += 3
+	broken.store[0][0] = math.MaxUint16
+	changed, err := broken.Repair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"logExp", "carry", "counters"}) {
+		t.Errorf("expected logExp, carry and counters to be repaired, got %v", changed)
+	}
+	if err := broken.Validate(); err != nil {
+		t.Errorf("expected the repaired sketch to be valid, got %v", err)
+	}
+	if broken.Query([]byte("1")) != sk.Query([]byte("1")) {
+		t.Error("expected queries to work after repair")
+	}
+
+	broken.store[1] = broken.store[1][:10]
+	if _, err := broken.Repair(); err == nil {
+		t.Error("expected a malformed store to be beyond repair")
+	}
+}
@@ -0,0 +1,60 @@
+package cml
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestValuePrecision(t *testing.T) {
+	for _, exp := range []float64{1.000001, 1.00017, 1.00026, 1.01, 1.08, 2} {
+		sk, _ := NewSketch(1, 1, exp)
+		// power holds exp^c, accumulated exactly enough at 256 bits.
+		base := new(big.Float).SetPrec(256).SetFloat64(exp)
+		denom := new(big.Float).SetPrec(256).Sub(base, big.NewFloat(1))
+		power := new(big.Float).SetPrec(256).SetFloat64(1)
+		ref := new(big.Float).SetPrec(256)
+		for c := 0; c <= math.MaxUint16; c++ {
+			ref.Sub(power, big.NewFloat(1))
+			ref.Quo(ref, denom)
+			power.Mul(power, base)
+
+			want, acc := ref.Float64()
+			if math.IsInf(want, 0) || acc == big.Above && want == math.MaxFloat64 {
+				break
+			}
+			got := sk.value(uint16(c))
+			if want == 0 {
+				if got != 0 {
+					t.Errorf("exp=%v: expected value(0) = 0, got %v", exp, got)
+				}
+				continue
+			}
+			if rel := math.Abs(got-want) / want; rel > 1e-9 {
+				t.Errorf("exp=%v: value(%d) = %v, expected %v (relative error %g)", exp, c, got, want, rel)
+				break
+			}
+		}
+	}
+}
+
+func TestDecodeRegister(t *testing.T) {
+	for _, exp := range []float64{1.000001, 1.00026, 1.08, 2} {
+		sk, _ := NewSketch(1, 1, exp)
+		logExp := math.Log1p(exp - 1)
+		for c := 0; c <= math.MaxUint16; c++ {
+			// The decoding as Sketch did it before it was shared.
+			want := math.Expm1(float64(c)*logExp) / (exp - 1)
+			if c <= 1 {
+				want = 0
+				if c == 1 {
+					want = math.Exp(float64(c-1) * logExp)
+				}
+			}
+			got := DecodeRegister(uint16(c), exp)
+			if math.Float64bits(got) != math.Float64bits(want) || sk.ValueOf(uint16(c)) != got {
+				t.Fatalf("exp=%v: expected register %d to decode to %v, got %v", exp, c, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package cml
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"unsafe"
+)
+
+/*
+ErrReadOnly is returned when modifying a sketch created by NewSketchFromBuffer
+*/
+var ErrReadOnly = errors.New("sketch is read-only")
+
+// hostOrder is the byte order of the machine.
+var hostOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+/*
+NewSketchFromBuffer returns a read-only sketch over the MarshalBinary encoding in data without
+copying its registers: the sketch's store aliases data, e.g. a memory-mapped checkpoint file.
+data must outlive the sketch and must not be modified while the sketch is in use.
+
+The encoding's byte order must be the machine's and its registers must be 2-byte aligned in
+memory. Updates, merges and other changes to the sketch fail with ErrReadOnly, or do nothing
+where they return no error; UnmarshalBinary replaces the view with a writable sketch.
+*/
+func NewSketchFromBuffer(data []byte) (*Sketch, error) {
+	if len(data) < headerSize {
+		return nil, errors.New("sketch data too short")
+	}
+	order, err := sketchHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if order != hostOrder {
+		return nil, errors.New("sketch byte order differs from the machine's")
+	}
+	var (
+		w     = order.Uint64(data[8:])
+		d     = order.Uint64(data[16:])
+		exp   = math.Float64frombits(order.Uint64(data[24:]))
+		hash  = hashing(data[1])
+		flags = data[2]
+	)
+	if err := validateParams(w, d, exp, hash, flags); err != nil {
+		return nil, err
+	}
+	rest := data[headerSize:]
+	var md map[string]string
+	if flags&flagMetadata != 0 {
+		if md, rest, err = parseMetadata(rest, order, DefaultMaxMetadataSize); err != nil {
+			return nil, err
+		}
+	}
+	dk, rest, err := decodeDoorkeeper(data, rest, order)
+	if err != nil {
+		return nil, err
+	}
+	if n := uint64(len(rest)); n%2 != 0 || w > n/2/d || w*d != n/2 {
+		return nil, errors.New("sketch data size does not match its dimensions")
+	}
+	if uintptr(unsafe.Pointer(unsafe.SliceData(rest)))%unsafe.Alignof(uint16(0)) != 0 {
+		return nil, errors.New("sketch registers are not aligned in the buffer")
+	}
+
+	flat := unsafe.Slice((*uint16)(unsafe.Pointer(unsafe.SliceData(rest))), w*d)
+	chunk := w
+	if flags&flagPaged != 0 {
+		chunk = pageRegisters
+	}
+	store := make([][]uint16, (w*d+chunk-1)/chunk)
+	for i := range store {
+		lo := uint64(i) * chunk
+		hi := min(lo+chunk, w*d)
+		store[i] = flat[lo:hi:hi]
+	}
+	cml := &Sketch{
+		w:             uint(w),
+		d:             uint(d),
+		exp:           exp,
+		logExp:        math.Log1p(exp - 1),
+		store:         store,
+		hashing:       hash,
+		banded:        flags&flagBanded != 0,
+		paged:         flags&flagPaged != 0,
+		deterministic: flags&flagDeterministic != 0,
+		doorkeeper:    dk,
+		metadata:      md,
+		readOnly:      true,
+	}
+	cml.recount()
+	if err := cml.Validate(); err != nil {
+		return nil, err
+	}
+	return cml, nil
+}
+
+// writable returns the error of modifying the sketch, if any.
+func (cml *Sketch) writable() error {
+	if !cml.initialized() {
+		return ErrUninitialized
+	}
+	if cml.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package cml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestNewSketchFromBuffer(t *testing.T) {
+	sk, _ := NewSketch(1000, 4, 1.00026)
+	for i := 0; i < 2000; i++ {
+		sk.BulkUpdate([]byte(fmt.Sprint(i)), uint(i%100+1))
+	}
+	data, _ := sk.MarshalBinary()
+
+	view, err := NewSketchFromBuffer(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &Sketch{}
+	restored.UnmarshalBinary(data)
+	for i := 0; i < 2500; i++ {
+		key := []byte(fmt.Sprint(i))
+		if a, b := view.Query(key), restored.Query(key); a != b {
+			t.Errorf("expected %f for %s, got %f", b, key, a)
+		}
+	}
+	if view.Stats() != restored.Stats() {
+		t.Errorf("expected stats %+v, got %+v", restored.Stats(), view.Stats())
+	}
+
+	// The view aliases the buffer.
+	binary.LittleEndian.PutUint16(data[headerSize:], 1234)
+	if c, _ := view.GetRegister(0, 0); c != 1234 {
+		t.Errorf("expected the store to alias the buffer, got %d", c)
+	}
+}
+
+func TestNewSketchFromBufferReadOnly(t *testing.T) {
+	sk, _ := NewSketch(100, 2, 1.00026)
+	sk.BulkUpdate([]byte("a"), 10)
+	data, _ := sk.MarshalBinary()
+	view, _ := NewSketchFromBuffer(data)
+	before := string(data)
+
+	if _, err := view.UpdateResult([]byte("a")); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if view.Update([]byte("a")) || view.UpdatePair([]byte("a"), []byte("b")) {
+		t.Error("expected updates to fail")
+	}
+	if err := view.Merge(sk); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly from Merge, got %v", err)
+	}
+	if err := view.SetRegister(0, 0, 1); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly from SetRegister, got %v", err)
+	}
+	view.Reset()
+	view.ForgetKey([]byte("a"), 0)
+	view.Trim(100)
+	if string(data) != before {
+		t.Error("expected the buffer to be left untouched")
+	}
+
+	// A clone is an ordinary sketch.
+	clone := view.Clone()
+	if !clone.Update([]byte("a")) {
+		t.Error("expected a clone to be writable")
+	}
+}
+
+func TestNewSketchFromBufferInvalid(t *testing.T) {
+	sk, _ := NewSketch(100, 2, 1.00026)
+	data, _ := sk.MarshalBinary()
+
+	misaligned := make([]byte, len(data)+1)[1:]
+	copy(misaligned, data)
+	if _, err := NewSketchFromBuffer(misaligned); err == nil {
+		t.Error("expected a misaligned buffer to be rejected")
+	}
+
+	foreign, _ := sk.MarshalBinaryBigEndian()
+	if hostOrder == binary.BigEndian {
+		foreign, _ = sk.MarshalBinary()
+	}
+	if _, err := NewSketchFromBuffer(foreign); err == nil {
+		t.Error("expected an encoding in the other byte order to be rejected")
+	}
+	if _, err := NewSketchFromBuffer(data[:len(data)-2]); err == nil {
+		t.Error("expected a truncated buffer to be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+package cml
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+/*
+A WAL record is a 4-byte payload length, the payload and a 4-byte CRC-32 of the
+payload, all little-endian. The payload is the 8-byte key hash and 8-byte frequency,
+or the two 8-byte halves of the key hash and the frequency for 128-bit hashing.
+*/
+const (
+	walPayloadSize    = 16
+	walPayloadSize128 = 24
+	walRecordSize     = 4 + walPayloadSize + 4
+	walMaxRecordSize  = 4 + walPayloadSize128 + 4
+)
+
+/*
+ErrWALCorrupt is returned by ReplayWAL when a record other than the last one is damaged
+*/
+var ErrWALCorrupt = errors.New("corrupt WAL record")
+
+func (cml *Sketch) logWAL(h keyHash, freq uint) {
+	if cml.wal == nil || cml.walErr != nil {
+		return
+	}
+	var rec [walMaxRecordSize]byte
+	payload := binary.LittleEndian.AppendUint64(rec[4:4], h.lo)
+	if cml.hashing&hashWide != 0 {
+		payload = binary.LittleEndian.AppendUint64(payload, h.hi)
+	}
+	payload = binary.LittleEndian.AppendUint64(payload, uint64(freq))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(len(payload)))
+	end := 4 + len(payload)
+	binary.LittleEndian.PutUint32(rec[end:], crc32.ChecksumIEEE(payload))
+	_, cml.walErr = cml.wal.Write(rec[:end+4])
+}
+
+/*
+WALErr returns the first error encountered while writing the write-ahead log.
+Once an error occurred no further records are written until RotateWAL.
+*/
+func (cml *Sketch) WALErr() error {
+	return cml.walErr
+}
+
+/*
+RotateWAL switches the write-ahead log to w, typically a fresh file created right after a successful checkpoint.
+A nil w disables the log.
+*/
+func (cml *Sketch) RotateWAL(w io.Writer) {
+	cml.wal = w
+	cml.walErr = nil
+}
+
+/*
+ReplayWAL re-applies the records read from r, typically onto a sketch restored from the checkpoint the log was rotated at.
+A torn final record is skipped; any other damaged record stops the replay with ErrWALCorrupt.
+It returns the number of records applied.
+*/
+func (cml *Sketch) ReplayWAL(r io.Reader) (uint64, error) {
+	if err := cml.writable(); err != nil {
+		return 0, err
+	}
+	br := bufio.NewReader(r)
+	size := walPayloadSize
+	if cml.hashing&hashWide != 0 {
+		size = walPayloadSize128
+	}
+	var (
+		rec [walMaxRecordSize]byte
+		n   uint64
+	)
+	for {
+		if _, err := io.ReadFull(br, rec[:4]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if binary.LittleEndian.Uint32(rec[0:]) != uint32(size) {
+			return n, ErrWALCorrupt
+		}
+		if _, err := io.ReadFull(br, rec[4:4+size+4]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		payload := rec[4 : 4+size]
+		if binary.LittleEndian.Uint32(rec[4+size:]) != crc32.ChecksumIEEE(payload) {
+			if _, err := br.Peek(1); err == io.EOF {
+				return n, nil
+			}
+			return n, ErrWALCorrupt
+		}
+		h := keyHash{lo: binary.LittleEndian.Uint64(payload)}
+		if cml.hashing&hashWide != 0 {
+			h.hi = binary.LittleEndian.Uint64(payload[8:])
+		}
+		cml.add(h, uint(binary.LittleEndian.Uint64(payload[size-8:])))
+		n++
+	}
+}
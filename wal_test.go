@@ -0,0 +1,76 @@
+package cml
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestWALReplay(t *testing.T) {
+	keys := make([][]byte, 500)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+	}
+	feed := func(sk *Sketch, from, to int) {
+		for i := from; i < to; i++ {
+			sk.BulkUpdate(keys[i%len(keys)], uint(i%7+1))
+		}
+	}
+
+	// The generator's state is not encoded, so the recovered sketch takes it
+	// from the uninterrupted one at the checkpoint to draw the same numbers.
+	uninterrupted, _ := NewSketch(1000, 4, 1.00026)
+	feed(uninterrupted, 0, 1000)
+	atCheckpoint := uninterrupted.rnd
+	feed(uninterrupted, 1000, 3000)
+
+	var wal bytes.Buffer
+	crashed, _ := NewSketch(1000, 4, 1.00026, WithWAL(&bytes.Buffer{}))
+	feed(crashed, 0, 1000)
+	checkpoint, _ := crashed.MarshalBinary()
+	crashed.RotateWAL(&wal)
+	feed(crashed, 1000, 3000)
+	if err := crashed.WALErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a torn write of the next record.
+	wal.Write([]byte{16, 0, 0, 0, 1, 2, 3})
+
+	restored := &Sketch{}
+	if err := restored.UnmarshalBinary(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	restored.rnd = atCheckpoint
+	n, err := restored.ReplayWAL(&wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2000 {
+		t.Errorf("expected 2000 records, got %d", n)
+	}
+	for _, key := range keys {
+		if got, expected := restored.Query(key), uninterrupted.Query(key); got != expected {
+			t.Errorf("expected %f for %s, got %f", expected, key, got)
+		}
+	}
+}
+
+func TestWALCorrupt(t *testing.T) {
+	var wal bytes.Buffer
+	sk, _ := NewSketch(100, 2, 1.00026, WithWAL(&wal))
+	sk.Update([]byte("a"))
+	sk.Update([]byte("b"))
+
+	data := wal.Bytes()
+	data[walRecordSize-1] ^= 0xff
+	if _, err := sk.ReplayWAL(bytes.NewReader(data)); err != ErrWALCorrupt {
+		t.Errorf("expected ErrWALCorrupt, got %v", err)
+	}
+
+	// A damaged final record is treated as torn and skipped.
+	n, err := sk.ReplayWAL(bytes.NewReader(data[walRecordSize : len(data)-1]))
+	if err != nil || n != 0 {
+		t.Errorf("expected torn record to be skipped, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,110 @@
+package cml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestZeroSketch(t *testing.T) {
+	var zero Sketch
+	key := []byte("a")
+
+	if zero.Update(key) || zero.BulkUpdate(key, 10) || zero.Insert(key) || zero.InsertN(key, 10) {
+		t.Error("expected updates on a zero sketch to report false")
+	}
+	if applied, saturated := zero.BulkUpdateSaturating(key, 10); applied != 0 || saturated {
+		t.Errorf("expected nothing consumed, got %d and %v", applied, saturated)
+	}
+	if zero.Query(key) != 0 || zero.Estimate(key) != 0 || zero.DebugQuery(key).Estimate != 0 {
+		t.Error("expected a zero sketch to estimate 0")
+	}
+	if zero.CompareFrequency(key, []byte("b")) != 0 {
+		t.Error("expected all keys to compare equal")
+	}
+	if zero.CountsAbove([][]byte{key}, 0) != nil {
+		t.Error("expected no counts above 0")
+	}
+	if top := zero.TopAmongCandidates([][]byte{key}, 1); len(top) != 1 || top[0].Count != 0 {
+		t.Errorf("expected a zero estimate, got %v", top)
+	}
+	if _, ok := zero.EstimateEntropy(); ok {
+		t.Error("expected no entropy estimate")
+	}
+	if _, ok := zero.EstimateSkew(); ok {
+		t.Error("expected no skew estimate")
+	}
+	if stats := zero.Stats(); stats.FillRatePct != 0 || stats.StoreBytes != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+	if w, d, counters := zero.ExportRedisCMS(); w != 0 || d != 0 || len(counters) != 0 {
+		t.Error("expected an empty export")
+	}
+	zero.Reset()
+	zero.Clear()
+	zero.ResetCount()
+
+	if _, err := zero.MarshalBinary(); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from MarshalBinary, got %v", err)
+	}
+	if _, err := zero.AppendBinary(nil); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from AppendBinary, got %v", err)
+	}
+	if _, err := zero.MarshalText(); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from MarshalText, got %v", err)
+	}
+	if err := zero.WriteFramed(&bytes.Buffer{}); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from WriteFramed, got %v", err)
+	}
+	if _, err := FromStructured(zero.MarshalStructured()); err == nil {
+		t.Error("expected the structured form of a zero sketch to be rejected")
+	}
+	if _, err := zero.LoadFromReader(strings.NewReader("a\n")); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from LoadFromReader, got %v", err)
+	}
+	if _, err := zero.ReplayWAL(&bytes.Buffer{}); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from ReplayWAL, got %v", err)
+	}
+	if _, err := Rebase(&zero, 2); err == nil {
+		t.Error("expected rebasing a zero sketch to fail")
+	}
+	if clone := zero.Clone(); clone.Query(key) != 0 || clone.Update(key) {
+		t.Error("expected the clone of a zero sketch to be uninitialized")
+	}
+
+	sk, _ := NewSketch(100, 4, 1.00026)
+	sk.Update(key)
+	if err := zero.Merge(sk); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized merging into a zero sketch, got %v", err)
+	}
+	if err := sk.Merge(&zero); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized merging a zero sketch, got %v", err)
+	}
+	if err := sk.MergeMin(&zero); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from MergeMin, got %v", err)
+	}
+	if err := sk.MergeWithDecay(&zero, 0.5); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from MergeWithDecay, got %v", err)
+	}
+	if _, err := MinOf(&zero, sk); !errors.Is(err, ErrUninitialized) {
+		t.Errorf("expected ErrUninitialized from MinOf, got %v", err)
+	}
+
+	data, _ := sk.MarshalBinary()
+	if err := zero.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !zero.Update(key) || zero.Query(key) <= sk.Query(key) {
+		t.Error("expected UnmarshalBinary to initialize a zero sketch")
+	}
+}
+
+func TestNewSketchZeroDimensions(t *testing.T) {
+	if _, err := NewSketch(0, 4, 1.00026); err == nil {
+		t.Error("expected error for zero width")
+	}
+	if _, err := NewSketch(100, 0, 1.00026); err == nil {
+		t.Error("expected error for zero depth")
+	}
+}